@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/rmrfslashbin/tndx/pkg/faketwitter"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	RootCmd = &cobra.Command{
+		Use:   "tndx-fake-twitter",
+		Short: "serve canned Twitter API responses for offline testing",
+		Run: func(cmd *cobra.Command, args []string) {
+			run()
+		},
+	}
+	fixtures string
+	listen   string
+	loglevel string
+	log      *logrus.Logger
+)
+
+func init() {
+	log = logrus.New()
+	log.SetLevel(logrus.InfoLevel)
+	log.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+
+	RootCmd.Flags().StringVarP(&fixtures, "fixtures", "f", "", "path to fixtures json file")
+	RootCmd.Flags().StringVarP(&listen, "listen", "l", "127.0.0.1:8080", "listen address")
+	RootCmd.Flags().StringVarP(&loglevel, "loglevel", "", "info", "[error|warn|info|debug|trace]")
+}
+
+func main() {
+	if err := RootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func run() {
+	if level, err := logrus.ParseLevel(loglevel); err == nil {
+		log.SetLevel(level)
+	}
+
+	server := faketwitter.New(
+		faketwitter.SetLogger(log),
+	)
+
+	if fixtures != "" {
+		fqpn := path.Clean(fixtures)
+		fp, err := os.Open(fqpn)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error": err,
+				"file":  fqpn,
+			}).Fatal("failed opening fixtures")
+		}
+		if err := server.Load(fp); err != nil {
+			log.WithFields(logrus.Fields{
+				"error": err,
+				"file":  fqpn,
+			}).Fatal("failed loading fixtures")
+		}
+		fp.Close()
+	}
+
+	log.WithFields(logrus.Fields{
+		"listen": listen,
+	}).Info("serving fake twitter api; set TwitterBaseUrl to http://" + listen)
+
+	if err := http.ListenAndServe(listen, server); err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err,
+		}).Fatal("server failed")
+	}
+}
@@ -2,23 +2,28 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
-	"github.com/aws/aws-sdk-go/aws"
+	"github.com/rmrfslashbin/tndx/pkg/analyzer"
 	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/media"
 	"github.com/rmrfslashbin/tndx/pkg/rekognition"
 	"github.com/rmrfslashbin/tndx/pkg/ssmparams"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
 	"github.com/sirupsen/logrus"
 )
 
 var (
 	aws_region string
 	log        *logrus.Logger
+	quarantine *media.QuarantinePolicy
+	settings   []func(*rekognition.Config)
+	budget     int64
 )
 
 func init() {
@@ -26,6 +31,75 @@ func init() {
 	log.SetLevel(logrus.InfoLevel)
 	log.SetFormatter(&logrus.JSONFormatter{})
 	aws_region = os.Getenv("AWS_REGION")
+
+	// REKOGNITION_DETECTORS, _MIN_CONFIDENCE and _MAX_LABELS tune the Rekognition calls;
+	// unset keeps the defaults.
+	if value := os.Getenv("REKOGNITION_DETECTORS"); value != "" {
+		detectors, err := rekognition.ParseDetectors(value)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"action": "init",
+				"error":  err.Error(),
+				"value":  value,
+			}).Fatal("invalid REKOGNITION_DETECTORS")
+		}
+		settings = append(settings, rekognition.SetDetectors(detectors))
+	}
+	if value := os.Getenv("REKOGNITION_MIN_CONFIDENCE"); value != "" {
+		confidence, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"action": "init",
+				"error":  err.Error(),
+				"value":  value,
+			}).Fatal("invalid REKOGNITION_MIN_CONFIDENCE")
+		}
+		settings = append(settings, rekognition.SetMinConfidence(float32(confidence)))
+	}
+	if value := os.Getenv("REKOGNITION_MAX_LABELS"); value != "" {
+		maxLabels, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"action": "init",
+				"error":  err.Error(),
+				"value":  value,
+			}).Fatal("invalid REKOGNITION_MAX_LABELS")
+		}
+		settings = append(settings, rekognition.SetMaxLabels(int32(maxLabels)))
+	}
+	// REKOGNITION_DAILY_BUDGET caps Rekognition calls per UTC day; 0 or unset is unlimited.
+	if value := os.Getenv("REKOGNITION_DAILY_BUDGET"); value != "" {
+		daily, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"action": "init",
+				"error":  err.Error(),
+				"value":  value,
+			}).Fatal("invalid REKOGNITION_DAILY_BUDGET")
+		}
+		budget = daily
+	}
+
+	// QUARANTINE_LABELS is a comma separated list of moderation labels; empty disables quarantine.
+	if value := os.Getenv("QUARANTINE_LABELS"); value != "" {
+		quarantine = &media.QuarantinePolicy{MinConfidence: 80}
+		for _, label := range strings.Split(value, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				quarantine.Labels = append(quarantine.Labels, label)
+			}
+		}
+		if value := os.Getenv("QUARANTINE_MIN_CONFIDENCE"); value != "" {
+			confidence, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"action": "init",
+					"error":  err.Error(),
+					"value":  value,
+				}).Fatal("invalid QUARANTINE_MIN_CONFIDENCE")
+			}
+			quarantine.MinConfidence = float32(confidence)
+		}
+	}
 }
 
 func main() {
@@ -64,81 +138,58 @@ func handler(ctx context.Context, event events.S3Event) error {
 		return err
 	}
 
-	rk := rekognition.NewImageProcessor(
-		rekognition.SetRegion(aws_region),
-		rekognition.SetLogger(log),
-	)
-
 	ddb := database.NewDDB(
 		database.SetDDBLogger(log),
 		database.SetDDBTablePrefix(outputs.Params[os.Getenv("DDB_TABLE_PREFIX")].(string)),
 	)
 
 	for _, record := range event.Records {
-		parts := strings.Split(record.S3.Object.Key, "/")
-		userID, err := strconv.ParseInt(parts[1], 10, 64)
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"error": err,
-			}).Error("failed to parse userID from S3 key")
-			return err
-		}
-		tweetID, err := strconv.ParseInt(parts[2], 10, 64)
-		if err != nil {
+		store := storage.NewS3Storage(
+			storage.SetLogger(log),
+			storage.SetS3Bucket(record.S3.Bucket.Name),
+			storage.SetS3Region(aws_region),
+		)
+
+		// ANALYZER=local describes media without calling Rekognition.
+		var mediaAnalyzer analyzer.Analyzer
+		switch os.Getenv("ANALYZER") {
+		case "local":
+			mediaAnalyzer = analyzer.NewLocal(
+				analyzer.SetLocalLogger(log),
+				analyzer.SetLocalStorage(store),
+			)
+		case "", "rekognition":
+			// FACE_COLLECTION enables face clustering; empty leaves it off.
+			opts := append([]func(*rekognition.Config){
+				rekognition.SetRegion(aws_region),
+				rekognition.SetLogger(log),
+				rekognition.SetCollection(os.Getenv("FACE_COLLECTION")),
+				rekognition.SetBudget(ddb, budget),
+			}, settings...)
+			mediaAnalyzer = rekognition.NewImageProcessor(opts...)
+		default:
 			log.WithFields(logrus.Fields{
-				"error": err,
-			}).Error("failed to parse tweetID from S3 key")
-			return err
+				"analyzer": os.Getenv("ANALYZER"),
+			}).Error("unknown ANALYZER")
+			return errors.New("unknown ANALYZER " + os.Getenv("ANALYZER"))
 		}
 
-		if strings.HasPrefix(record.EventName, "ObjectCreated") {
-			output, err := rk.Process(&types.S3Object{
-				Bucket: aws.String(record.S3.Bucket.Name),
-				Name:   aws.String(record.S3.Object.Key),
-			})
-			if err != nil {
-				log.WithFields(logrus.Fields{
-					"error": err,
-				}).Error("error processing media")
-				return err
-			}
+		m := media.New(
+			media.SetLogger(log),
+			media.SetDatabase(ddb),
+			media.SetAnalyzer(mediaAnalyzer),
+			media.SetStorage(store),
+			media.SetQuarantine(quarantine),
+		)
 
-			if err := ddb.PutMedia(&database.MediaItem{
-				Bucket:          record.S3.Bucket.Name,
-				S3Key:           record.S3.Object.Key,
-				UserID:          userID,
-				TweetID:         tweetID,
-				Faces:           output.Faces,
-				Labels:          output.Labels,
-				Moderation:      output.Moderation,
-				Text:            output.Text,
-				FacesCount:      len(output.Faces),
-				LabelsCount:     len(output.Labels),
-				ModerationCount: len(output.Moderation),
-				TextCount:       len(output.Text),
-			}); err != nil {
-				log.WithFields(logrus.Fields{
-					"error": err,
-				}).Error("error saving media item")
+		if strings.HasPrefix(record.EventName, "ObjectCreated") {
+			if err := m.Created(record.S3.Bucket.Name, record.S3.Object.Key); err != nil {
 				return err
 			}
-			log.WithFields(logrus.Fields{
-				"output": output,
-				"record": record,
-			}).Info("media processed and added to ddb")
 		} else if strings.HasPrefix(record.EventName, "ObjectRemoved") {
-			if err := ddb.DeleteMedia(&database.MediaItem{
-				TweetID: tweetID,
-				S3Key:   record.S3.Object.Key,
-			}); err != nil {
-				log.WithFields(logrus.Fields{
-					"error": err,
-				}).Error("error deleting media item")
+			if err := m.Removed(record.S3.Bucket.Name, record.S3.Object.Key); err != nil {
 				return err
 			}
-			log.WithFields(logrus.Fields{
-				"record": record,
-			}).Info("image removed from ddb")
 		} else {
 			log.WithFields(logrus.Fields{
 				"record": record,
@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/rmrfslashbin/tndx/pkg/database"
 	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/runner"
 	"github.com/rmrfslashbin/tndx/pkg/ssmparams"
 	"github.com/sirupsen/logrus"
 )
@@ -15,7 +16,7 @@ import (
 var (
 	aws_region string
 	log        *logrus.Logger
-	db         *database.DDBDriver
+	db         database.Database
 )
 
 type Message struct {
@@ -117,15 +118,6 @@ func handler(ctx context.Context, message Message) error {
 		queue.SetSQSURL(outputs.Params[message.SQSRunnerURL].(string)),
 	)
 
-	users, err := db.GetRunnerUsers(&database.RunnerItem{RunnerName: message.RunnerName})
-	if err != nil {
-		log.WithFields(logrus.Fields{
-			"action": "getRunnerUsers",
-			"error":  err.Error(),
-		}).Error("error getting runner users.")
-		return err
-	}
-
 	bootstrap := &queue.Bootstrap{
 		S3Bucket:         message.S3Bucket,
 		DDBTablePrefix:   message.DDBTablePrefix,
@@ -135,129 +127,9 @@ func handler(ctx context.Context, message Message) error {
 		SQSRunnerURL:     message.SQSRunnerURL,
 	}
 
-	switch message.Function {
-	case "favorites":
-		bootstrap.Function = "favorites"
-		for _, user := range users {
-			if database.Has(user.Flags, database.F_favorites) {
-				params := &queue.SendMessage{
-					Bootstrap: bootstrap,
-					Message: &queue.ProcessorMessage{
-						UserID: user.UserID,
-					},
-				}
-				if err := q.SendRunnerMessage(params); err != nil {
-					log.WithFields(logrus.Fields{
-						"action": "sendRunnerMessage",
-						"error":  err.Error(),
-						"params": params,
-					}).Error("error sending runner message.")
-					return err
-				}
-				log.WithFields(logrus.Fields{
-					"params": params,
-				}).Info("favorites sent.")
-			}
-		}
-
-	case "followers":
-		bootstrap.Function = "followers"
-		for _, user := range users {
-			if database.Has(user.Flags, database.F_followers) {
-				params := &queue.SendMessage{
-					Bootstrap: bootstrap,
-					Message: &queue.ProcessorMessage{
-						UserID: user.UserID,
-					},
-				}
-				if err := q.SendRunnerMessage(params); err != nil {
-					log.WithFields(logrus.Fields{
-						"action": "sendRunnerMessage",
-						"error":  err.Error(),
-						"params": params,
-					}).Error("error sending runner message.")
-					return err
-				}
-				log.WithFields(logrus.Fields{
-					"params": params,
-				}).Info("followers sent.")
-			}
-		}
-	case "friends":
-		bootstrap.Function = "friends"
-		for _, user := range users {
-			if database.Has(user.Flags, database.F_friends) {
-				params := &queue.SendMessage{
-					Bootstrap: bootstrap,
-					Message: &queue.ProcessorMessage{
-						UserID: user.UserID,
-					},
-				}
-				if err := q.SendRunnerMessage(params); err != nil {
-					log.WithFields(logrus.Fields{
-						"action": "sendRunnerMessage",
-						"error":  err.Error(),
-						"params": params,
-					}).Error("error sending runner message.")
-					return err
-				}
-				log.WithFields(logrus.Fields{
-					"params": params,
-				}).Info("friends sent.")
-			}
-		}
-	case "timeline":
-		bootstrap.Function = "timeline"
-		for _, user := range users {
-			if database.Has(user.Flags, database.F_timeline) {
-				params := &queue.SendMessage{
-					Bootstrap: bootstrap,
-					Message: &queue.ProcessorMessage{
-						UserID: user.UserID,
-					},
-				}
-				if err := q.SendRunnerMessage(params); err != nil {
-					log.WithFields(logrus.Fields{
-						"action": "sendRunnerMessage",
-						"error":  err.Error(),
-						"params": params,
-					}).Error("error sending runner message.")
-					return err
-				}
-				log.WithFields(logrus.Fields{
-					"params": params,
-				}).Info("timeline sent.")
-			}
-		}
-	case "user":
-		bootstrap.Function = "user"
-		for _, user := range users {
-			if database.Has(user.Flags, database.F_user) {
-				params := &queue.SendMessage{
-					Bootstrap: bootstrap,
-					Message: &queue.ProcessorMessage{
-						UserID: user.UserID,
-					},
-				}
-				if err := q.SendRunnerMessage(params); err != nil {
-					log.WithFields(logrus.Fields{
-						"action": "sendRunnerMessage",
-						"error":  err.Error(),
-						"params": params,
-					}).Error("error sending runner message.")
-					return err
-				}
-				log.WithFields(logrus.Fields{
-					"params": params,
-				}).Info("user sent.")
-			}
-		}
-	default:
-		logrus.WithFields(logrus.Fields{
-			"function": message.Function,
-		}).Error("invalid function; should be one of user, friend, followers, favorites, timeline")
-		return errors.New("invalid function; should be one of user, friend, followers, favorites, timeline")
-	}
-
-	return nil
+	return runner.New(
+		runner.SetLogger(log),
+		runner.SetDatabase(db),
+		runner.SetQueue(q),
+	).Run(message.RunnerName, message.Function, bootstrap)
 }
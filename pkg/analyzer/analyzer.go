@@ -0,0 +1,42 @@
+// Package analyzer describes stored media. Analyzers differ in what they can detect, but all
+// return a Detection, so the media table looks the same whichever one produced it.
+package analyzer
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
+)
+
+// ErrDeferred is returned by analyzers that have run out of budget. The media should be
+// analyzed again later.
+var ErrDeferred = errors.New("analysis deferred")
+
+// Analyzer analyzes the object stored under key in bucket.
+type Analyzer interface {
+	Analyze(bucket string, key string) (*Detection, error)
+	GetAnalyzerName() string
+}
+
+// FaceMatch is a face indexed into a face collection and the ids of previously indexed
+// faces that matched it, most similar first.
+type FaceMatch struct {
+	FaceID  string
+	Matches []string
+}
+
+// Detection is everything an analyzer found in a media object. Fields an analyzer doesn't
+// support are left empty. Colors are "#rrggbb", most common first.
+type Detection struct {
+	Faces       []types.FaceDetail
+	Labels      []types.Label
+	Moderation  []types.ModerationLabel
+	Text        []types.TextDetection
+	Celebrities []types.Celebrity
+	Width       int
+	Height      int
+	Format      string
+	Colors      []string
+	PHash       string
+	FaceMatches []FaceMatch
+}
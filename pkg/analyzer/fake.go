@@ -0,0 +1,64 @@
+package analyzer
+
+import (
+	"sync"
+)
+
+type FakeOption func(config *Fake)
+
+// Fake returns canned detections without looking at the object, and records every key it
+// was asked to analyze.
+type Fake struct {
+	mu         sync.Mutex
+	detections map[string]*Detection
+	err        error
+	calls      []string
+}
+
+func NewFake(opts ...func(*Fake)) *Fake {
+	config := &Fake{detections: map[string]*Detection{}}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// SetFakeDetection returns detection for key. Other keys get an empty Detection.
+func SetFakeDetection(key string, detection *Detection) FakeOption {
+	return func(config *Fake) {
+		config.detections[key] = detection
+	}
+}
+
+// SetFakeError makes every Analyze call fail with err.
+func SetFakeError(err error) FakeOption {
+	return func(config *Fake) {
+		config.err = err
+	}
+}
+
+func (config *Fake) Analyze(bucket string, key string) (*Detection, error) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.calls = append(config.calls, key)
+	if config.err != nil {
+		return nil, config.err
+	}
+	if detection, ok := config.detections[key]; ok {
+		return detection, nil
+	}
+	return &Detection{}, nil
+}
+
+// Calls returns the keys analyzed so far, in order.
+func (config *Fake) Calls() []string {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	return append([]string(nil), config.calls...)
+}
+
+func (config *Fake) GetAnalyzerName() string {
+	return "fake"
+}
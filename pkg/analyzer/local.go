@@ -0,0 +1,152 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rmrfslashbin/tndx/pkg/phash"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultLocalColors is how many dominant colors Local reports.
+const DefaultLocalColors = 5
+
+type LocalOption func(config *Local)
+
+// Local analyzes images in-process, without any cloud service: dimensions, format, dominant
+// colors and perceptual hash. Videos only get their format.
+type Local struct {
+	log     *logrus.Logger
+	storage storage.Storage
+	colors  int
+}
+
+func NewLocal(opts ...func(*Local)) *Local {
+	config := &Local{colors: DefaultLocalColors}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.log == nil {
+		config.log = logrus.New()
+	}
+	return config
+}
+
+func SetLocalLogger(log *logrus.Logger) LocalOption {
+	return func(config *Local) {
+		config.log = log
+	}
+}
+
+// SetLocalStorage sets where objects are read from. The bucket passed to Analyze is ignored.
+func SetLocalStorage(storage storage.Storage) LocalOption {
+	return func(config *Local) {
+		config.storage = storage
+	}
+}
+
+// SetLocalColors sets how many dominant colors are reported.
+func SetLocalColors(colors int) LocalOption {
+	return func(config *Local) {
+		config.colors = colors
+	}
+}
+
+func (config *Local) Analyze(bucket string, key string) (*Detection, error) {
+	if config.storage == nil {
+		return nil, errors.New("local analyzer storage is required")
+	}
+	if !phash.Supported(key) {
+		return &Detection{Format: strings.TrimPrefix(strings.ToLower(path.Ext(key)), ".")}, nil
+	}
+
+	body, err := config.storage.Get(key)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "analyzer::Local::Analyze",
+			"error":  err.Error(),
+			"key":    key,
+		}).Error("error reading media")
+		return nil, err
+	}
+	defer body.Close()
+
+	img, format, err := image.Decode(body)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "analyzer::Local::Analyze",
+			"error":  err.Error(),
+			"key":    key,
+		}).Error("error decoding image")
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	return &Detection{
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+		Format: format,
+		Colors: dominantColors(img, config.colors),
+		PHash:  phash.DHash(img).String(),
+	}, nil
+}
+
+func (config *Local) GetAnalyzerName() string {
+	return "local"
+}
+
+// dominantColors returns the n most common colors of img. Colors are quantized to 4 bits per
+// channel so near-identical shades count together, and large images are sampled on a grid.
+func dominantColors(img image.Image, n int) []string {
+	const maxSamples = 256 * 256
+	if n < 0 {
+		n = 0
+	}
+
+	bounds := img.Bounds()
+	step := 1
+	for (bounds.Dx()/step)*(bounds.Dy()/step) > maxSamples {
+		step++
+	}
+
+	counts := map[uint16]int{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			counts[uint16(r>>12)<<8|uint16(g>>12)<<4|uint16(b>>12)]++
+		}
+	}
+
+	buckets := make([]uint16, 0, len(counts))
+	for bucket := range counts {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if counts[buckets[i]] != counts[buckets[j]] {
+			return counts[buckets[i]] > counts[buckets[j]]
+		}
+		return buckets[i] < buckets[j]
+	})
+	if len(buckets) > n {
+		buckets = buckets[:n]
+	}
+
+	colors := make([]string, len(buckets))
+	for i, bucket := range buckets {
+		// Report the middle of each quantized bucket.
+		r, g, b := bucket>>8&0xf, bucket>>4&0xf, bucket&0xf
+		colors[i] = fmt.Sprintf("#%02x%02x%02x", r<<4|8, g<<4|8, b<<4|8)
+	}
+	return colors
+}
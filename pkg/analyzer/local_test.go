@@ -0,0 +1,49 @@
+package analyzer
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// fill returns a w x h image split vertically: the first split columns are left, the rest right.
+func fill(w int, h int, split int, left color.Color, right color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < split {
+				img.Set(x, y, left)
+			} else {
+				img.Set(x, y, right)
+			}
+		}
+	}
+	return img
+}
+
+func TestDominantColors(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	transparent := color.RGBA{}
+
+	tests := []struct {
+		name string
+		img  image.Image
+		n    int
+		want []string
+	}{
+		{"solid", fill(10, 10, 10, red, red), 5, []string{"#f80808"}},
+		{"most common first", fill(10, 10, 3, red, blue), 5, []string{"#0808f8", "#f80808"}},
+		{"limited to n", fill(10, 10, 3, red, blue), 1, []string{"#0808f8"}},
+		{"transparent ignored", fill(10, 10, 8, transparent, red), 5, []string{"#f80808"}},
+		{"large image sampled", fill(1000, 1000, 1000, red, red), 5, []string{"#f80808"}},
+		{"zero", fill(10, 10, 10, red, red), 0, []string{}},
+		{"negative", fill(10, 10, 10, red, red), -1, []string{}},
+	}
+	for _, test := range tests {
+		if got := dominantColors(test.img, test.n); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: dominantColors = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
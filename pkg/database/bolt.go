@@ -0,0 +1,1050 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bolt bucket names. They mirror the DynamoDB tables and GSIs created by the CloudFormation template.
+const (
+	boltConversationsTable           = "conversations"
+	boltConversationsTableGSITweetid = "conversations-gsi-tweetid"
+	boltFavoritesTable               = "favorites"
+	boltFavoritesTableGSITweetid     = "favorites-gsi-tweetid"
+	boltFriendsTable                 = "friends"
+	boltFriendsTableGSIFriendid      = "friends-gsi-friendid"
+	boltFollowersTable               = "followers"
+	boltFollowersTableGSIFollowerid  = "followers-gsi-followerid"
+	boltHistoryTable                 = "history"
+	boltRunnerTable                  = "runners"
+	boltMediaTable                   = "media"
+	boltMediaTableGSIUserid          = "media-gsi-userid"
+	boltMediaTableGSIClusterid       = "media-gsi-clusterid"
+	boltFacesTable                   = "faces"
+	boltMediaRetriesTable            = "mediaretries"
+	boltMediaRefsTable               = "mediarefs"
+	boltMediaRefsTableGSIHash        = "mediarefs-gsi-hash"
+	boltParamsTable                  = "parameters"
+	boltRateLimitsTable              = "ratelimits"
+	boltBudgetsTable                 = "budgets"
+	boltSeenTweetsTable              = "seentweets"
+	boltHydratedUsersTable           = "hydratedusers"
+)
+
+type BoltOption func(config *BoltDriver)
+
+// BoltDriver stores every table in a single embedded bolt file.
+// Each table is a bucket holding one sub-bucket per hash key; items are keyed by range key and stored as JSON.
+// GSIs are maintained as separate buckets with the same layout.
+type BoltDriver struct {
+	log        *logrus.Logger
+	driverName string
+	path       string
+	db         *bolt.DB
+}
+
+func NewBolt(opts ...func(*BoltDriver)) *BoltDriver {
+	cfg := &BoltDriver{}
+	cfg.driverName = "bolt"
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.path == "" {
+		panic(errors.New("bolt path is required"))
+	}
+
+	db, err := bolt.Open(cfg.path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		panic(err)
+	}
+	cfg.db = db
+
+	return cfg
+}
+
+func SetBoltPath(path string) func(*BoltDriver) {
+	return func(config *BoltDriver) {
+		config.path = path
+	}
+}
+
+func SetBoltLogger(logger *logrus.Logger) func(*BoltDriver) {
+	return func(config *BoltDriver) {
+		config.log = logger
+	}
+}
+
+// Close releases the bolt file lock.
+func (config *BoltDriver) Close() error {
+	return config.db.Close()
+}
+
+// numKey encodes a numeric key so bolt's byte ordering matches numeric ordering.
+func numKey(n int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n)^(1<<63))
+	return b
+}
+
+func strKey(s string) []byte {
+	return []byte(s)
+}
+
+func boltPut(tx *bolt.Tx, table string, hash []byte, rng []byte, item interface{}) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(table))
+	if err != nil {
+		return err
+	}
+	sub, err := b.CreateBucketIfNotExists(hash)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return sub.Put(rng, data)
+}
+
+// boltGet unmarshals the item at hash/rng into item and reports whether it was found.
+func boltGet(tx *bolt.Tx, table string, hash []byte, rng []byte, item interface{}) (bool, error) {
+	b := tx.Bucket([]byte(table))
+	if b == nil {
+		return false, nil
+	}
+	sub := b.Bucket(hash)
+	if sub == nil {
+		return false, nil
+	}
+	data := sub.Get(rng)
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, item)
+}
+
+// boltQuery calls fn for every item under hash, in range key order.
+func boltQuery(tx *bolt.Tx, table string, hash []byte, fn func(data []byte) error) error {
+	b := tx.Bucket([]byte(table))
+	if b == nil {
+		return nil
+	}
+	sub := b.Bucket(hash)
+	if sub == nil {
+		return nil
+	}
+	return sub.ForEach(func(k, v []byte) error {
+		return fn(v)
+	})
+}
+
+func boltDelete(tx *bolt.Tx, table string, hash []byte, rng []byte) error {
+	b := tx.Bucket([]byte(table))
+	if b == nil {
+		return nil
+	}
+	sub := b.Bucket(hash)
+	if sub == nil {
+		return nil
+	}
+	return sub.Delete(rng)
+}
+
+func (config *BoltDriver) DeleteFollower(link *UserToFollowerLink) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		if err := boltDelete(tx, boltFollowersTableGSIFollowerid, numKey(link.FollowerID), numKey(link.UserID)); err != nil {
+			return err
+		}
+		return boltDelete(tx, boltFollowersTable, numKey(link.UserID), numKey(link.FollowerID))
+	})
+}
+
+func (config *BoltDriver) DeleteFriend(link *UserToFriendLink) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		if err := boltDelete(tx, boltFriendsTableGSIFriendid, numKey(link.FriendID), numKey(link.UserID)); err != nil {
+			return err
+		}
+		return boltDelete(tx, boltFriendsTable, numKey(link.UserID), numKey(link.FriendID))
+	})
+}
+
+func (config *BoltDriver) DeleteGap(userID int64, kind string, sinceID int64) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		return boltDelete(tx, boltParamsTable, numKey(userID), strKey(gapDomain(kind, sinceID)))
+	})
+}
+
+func (config *BoltDriver) DeleteMedia(mediaItem *MediaItem) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		item := &MediaItem{}
+		found, err := boltGet(tx, boltMediaTable, numKey(mediaItem.TweetID), strKey(mediaItem.S3Key), item)
+		if err != nil {
+			return err
+		}
+		if found {
+			if err := boltDelete(tx, boltMediaTableGSIUserid, numKey(item.UserID), strKey(item.S3Key)); err != nil {
+				return err
+			}
+			for _, clusterID := range item.FaceClusters {
+				key := MediaClusterKey(item.S3Key, clusterID)
+				if err := boltDelete(tx, boltMediaTable, numKey(mediaItem.TweetID), strKey(key)); err != nil {
+					return err
+				}
+				if err := boltDelete(tx, boltMediaTableGSIClusterid, strKey(clusterID), append(numKey(mediaItem.TweetID), key...)); err != nil {
+					return err
+				}
+			}
+		}
+		return boltDelete(tx, boltMediaTable, numKey(mediaItem.TweetID), strKey(mediaItem.S3Key))
+	})
+}
+
+func (config *BoltDriver) DeleteMediaRetry(entityURL string) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		return boltDelete(tx, boltMediaRetriesTable, strKey(entityURL), strKey(entityURL))
+	})
+}
+
+func (config *BoltDriver) DeleteRunnerUser(params *RunnerItem) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		return boltDelete(tx, boltRunnerTable, strKey(params.RunnerName), numKey(params.UserID))
+	})
+}
+
+func (config *BoltDriver) GetConversation(conversationID int64) ([]*ConversationItem, error) {
+	results := []*ConversationItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltConversationsTable, numKey(conversationID), func(data []byte) error {
+			item := &ConversationItem{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return err
+			}
+			results = append(results, item)
+			return nil
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetConversationsByTweetId(tweetID int64) ([]*ConversationItem, error) {
+	results := []*ConversationItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltConversationsTableGSITweetid, numKey(tweetID), func(data []byte) error {
+			item := &ConversationItem{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return err
+			}
+			results = append(results, item)
+			return nil
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetDeferredMedia() ([]*MediaItem, error) {
+	results := []*MediaItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(boltMediaTable))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return boltQuery(tx, boltMediaTable, k, func(data []byte) error {
+				item := &MediaItem{}
+				if err := json.Unmarshal(data, item); err != nil {
+					return err
+				}
+				if item.Deferred {
+					results = append(results, item)
+				}
+				return nil
+			})
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetDriverName() string {
+	return config.driverName
+}
+
+func (config *BoltDriver) GetFace(faceID string) (*FaceItem, error) {
+	item := &FaceItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		_, err := boltGet(tx, boltFacesTable, strKey(faceID), strKey(faceID), item)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (config *BoltDriver) GetFavoritesByTweetId(tweetID int64) ([]*UserToTweetLink, error) {
+	results := []*UserToTweetLink{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltFavoritesTableGSITweetid, numKey(tweetID), func(data []byte) error {
+			link := &UserToTweetLink{}
+			if err := json.Unmarshal(data, link); err != nil {
+				return err
+			}
+			results = append(results, link)
+			return nil
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetFavoritesByUserId(userID int64) ([]*UserToTweetLink, error) {
+	results := []*UserToTweetLink{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltFavoritesTable, numKey(userID), func(data []byte) error {
+			link := &UserToTweetLink{}
+			if err := json.Unmarshal(data, link); err != nil {
+				return err
+			}
+			results = append(results, link)
+			return nil
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetFollowersByFollowId(followID int64) ([]*UserToFollowerLink, error) {
+	results := []*UserToFollowerLink{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltFollowersTableGSIFollowerid, numKey(followID), func(data []byte) error {
+			link := &UserToFollowerLink{}
+			if err := json.Unmarshal(data, link); err != nil {
+				return err
+			}
+			results = append(results, link)
+			return nil
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetFollowersByUserId(userID int64) ([]*UserToFollowerLink, error) {
+	results := []*UserToFollowerLink{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltFollowersTable, numKey(userID), func(data []byte) error {
+			link := &UserToFollowerLink{}
+			if err := json.Unmarshal(data, link); err != nil {
+				return err
+			}
+			results = append(results, link)
+			return nil
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetFriendsByFriendId(friendID int64) ([]*UserToFriendLink, error) {
+	results := []*UserToFriendLink{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltFriendsTableGSIFriendid, numKey(friendID), func(data []byte) error {
+			link := &UserToFriendLink{}
+			if err := json.Unmarshal(data, link); err != nil {
+				return err
+			}
+			results = append(results, link)
+			return nil
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetFriendsByUserId(userID int64) ([]*UserToFriendLink, error) {
+	results := []*UserToFriendLink{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltFriendsTable, numKey(userID), func(data []byte) error {
+			link := &UserToFriendLink{}
+			if err := json.Unmarshal(data, link); err != nil {
+				return err
+			}
+			results = append(results, link)
+			return nil
+		})
+	})
+	return results, err
+}
+
+// getParams loads the parameters item for userID/domain into item; a missing item leaves item untouched.
+func (config *BoltDriver) getParams(userID int64, domain string, item interface{}) error {
+	return config.db.View(func(tx *bolt.Tx) error {
+		_, err := boltGet(tx, boltParamsTable, numKey(userID), strKey(domain), item)
+		return err
+	})
+}
+
+func (config *BoltDriver) putParams(userID int64, domain string, item interface{}) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		return boltPut(tx, boltParamsTable, numKey(userID), strKey(domain), item)
+	})
+}
+
+func (config *BoltDriver) GetAccountStatus(userID int64) (*AccountItem, error) {
+	item := &AccountItem{}
+	if err := config.getParams(userID, "account", item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (config *BoltDriver) GetFavoritesConfig(userID int64) (*FavoritesItem, error) {
+	item := &FavoritesItem{}
+	if err := config.getParams(userID, "favorites", item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (config *BoltDriver) GetFollowersConfig(userID int64) (*FollowersItem, error) {
+	item := &FollowersItem{}
+	if err := config.getParams(userID, "followers", item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (config *BoltDriver) GetFriendsConfig(userID int64) (*FriendsItem, error) {
+	item := &FriendsItem{}
+	if err := config.getParams(userID, "friends", item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (config *BoltDriver) GetGaps(userID int64, kind string) ([]*GapItem, error) {
+	results := []*GapItem{}
+	prefix := gapDomainPrefix(kind)
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltParamsTable, numKey(userID), func(data []byte) error {
+			item := &GapItem{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return err
+			}
+			if strings.HasPrefix(item.Domain, prefix) {
+				results = append(results, item)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (config *BoltDriver) GetHistory(userID int64, domain string, since int64) ([]*HistoryItem, error) {
+	results := []*HistoryItem{}
+	from := HistoryEventKey(domain, since, 0)
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltHistoryTable, numKey(userID), func(data []byte) error {
+			item := &HistoryItem{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return err
+			}
+			if item.Domain == domain && item.EventKey >= from {
+				results = append(results, item)
+			}
+			return nil
+		})
+	})
+	return results, err
+}
+
+// GetHydratedUsers returns the hydrated user marks stored for userIDs, expired or not.
+func (config *BoltDriver) GetHydratedUsers(userIDs []int64) ([]*HydratedUserItem, error) {
+	results := []*HydratedUserItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		for _, userID := range userIDs {
+			item := &HydratedUserItem{}
+			found, err := boltGet(tx, boltHydratedUsersTable, numKey(userID), numKey(userID), item)
+			if err != nil {
+				return err
+			}
+			if found {
+				results = append(results, item)
+			}
+		}
+		return nil
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetMedia(tweetID int64, s3Key string) (*MediaItem, error) {
+	item := &MediaItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		_, err := boltGet(tx, boltMediaTable, numKey(tweetID), strKey(s3Key), item)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (config *BoltDriver) GetMediaByClusterId(clusterID string) ([]*MediaClusterItem, error) {
+	results := []*MediaClusterItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltMediaTableGSIClusterid, strKey(clusterID), func(data []byte) error {
+			item := &MediaClusterItem{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return err
+			}
+			results = append(results, item)
+			return nil
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetMediaHashes() ([]*MediaItem, error) {
+	results := []*MediaItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(boltMediaTable))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return boltQuery(tx, boltMediaTable, k, func(data []byte) error {
+				item := &MediaItem{}
+				if err := json.Unmarshal(data, item); err != nil {
+					return err
+				}
+				if item.PHash == "" {
+					return nil
+				}
+				results = append(results, &MediaItem{
+					TweetID: item.TweetID,
+					S3Key:   item.S3Key,
+					UserID:  item.UserID,
+					Hash:    item.Hash,
+					PHash:   item.PHash,
+				})
+				return nil
+			})
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetMediaRefsByHash(hash string) ([]*MediaRefItem, error) {
+	results := []*MediaRefItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		return boltQuery(tx, boltMediaRefsTableGSIHash, strKey(hash), func(data []byte) error {
+			item := &MediaRefItem{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return err
+			}
+			results = append(results, item)
+			return nil
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetMediaRetries() ([]*MediaRetryItem, error) {
+	results := []*MediaRetryItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(boltMediaRetriesTable))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return boltQuery(tx, boltMediaRetriesTable, k, func(data []byte) error {
+				item := &MediaRetryItem{}
+				if err := json.Unmarshal(data, item); err != nil {
+					return err
+				}
+				results = append(results, item)
+				return nil
+			})
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetRateLimits() ([]*RateLimitItem, error) {
+	results := []*RateLimitItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(boltRateLimitsTable))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return boltQuery(tx, boltRateLimitsTable, k, func(data []byte) error {
+				item := &RateLimitItem{}
+				if err := json.Unmarshal(data, item); err != nil {
+					return err
+				}
+				results = append(results, item)
+				return nil
+			})
+		})
+	})
+	return results, err
+}
+
+func (config *BoltDriver) GetRunnerUsers(runnerUsers *RunnerItem) ([]*RunnerItem, error) {
+	results := []*RunnerItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		if runnerUsers.UserID != 0 {
+			item := &RunnerItem{}
+			found, err := boltGet(tx, boltRunnerTable, strKey(runnerUsers.RunnerName), numKey(runnerUsers.UserID), item)
+			if found {
+				results = append(results, item)
+			}
+			return err
+		}
+		return boltQuery(tx, boltRunnerTable, strKey(runnerUsers.RunnerName), func(data []byte) error {
+			item := &RunnerItem{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return err
+			}
+			results = append(results, item)
+			return nil
+		})
+	})
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error":  err,
+			"runner": runnerUsers.RunnerName,
+		}).Error("Error querying runner users")
+		return nil, err
+	}
+	return results, nil
+}
+
+func (config *BoltDriver) GetRunnerUsersByUserId(userID int64) ([]*RunnerItem, error) {
+	results := []*RunnerItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(boltRunnerTable))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(runnerName, _ []byte) error {
+			item := &RunnerItem{}
+			found, err := boltGet(tx, boltRunnerTable, runnerName, numKey(userID), item)
+			if found {
+				results = append(results, item)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error":  err,
+			"userid": userID,
+		}).Error("Error scanning runner users")
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetSeenTweet returns the seen mark for tweetID, expired or not. An empty item is returned
+// when there is none.
+func (config *BoltDriver) GetSeenTweet(tweetID int64) (*SeenTweetItem, error) {
+	item := &SeenTweetItem{}
+	err := config.db.View(func(tx *bolt.Tx) error {
+		_, err := boltGet(tx, boltSeenTweetsTable, numKey(tweetID), numKey(tweetID), item)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (config *BoltDriver) GetTimelineConfig(userID int64) (*TweetsItem, error) {
+	item := &TweetsItem{}
+	if err := config.getParams(userID, "tweets", item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (config *BoltDriver) GetTimelineBackfillConfig(userID int64) (*BackfillItem, error) {
+	item := &BackfillItem{}
+	if err := config.getParams(userID, "tweets_backfill", item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (config *BoltDriver) PutAccountStatus(query *AccountStatusQuery) error {
+	now := time.Now()
+	return config.putParams(query.UserID, "account", &AccountItem{
+		Domain:      "account",
+		UserID:      query.UserID,
+		Status:      query.Status,
+		StatusSince: query.StatusSince,
+		LastUpdate:  now.UnixMilli(),
+	})
+}
+
+func (config *BoltDriver) PutMediaClusters(items []*MediaClusterItem) error {
+	now := time.Now().UnixMilli()
+	return config.db.Update(func(tx *bolt.Tx) error {
+		for _, item := range items {
+			item.S3Key = MediaClusterKey(item.MediaKey, item.ClusterID)
+			item.LastUpdate = now
+			if err := boltPut(tx, boltMediaTable, numKey(item.TweetID), strKey(item.S3Key), item); err != nil {
+				return err
+			}
+			// The GSI range key must be unique within a hash, and S3Key only is within a tweet.
+			if err := boltPut(tx, boltMediaTableGSIClusterid, strKey(item.ClusterID), append(numKey(item.TweetID), item.S3Key...), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (config *BoltDriver) PutMediaRef(item *MediaRefItem) error {
+	item.RefKey = MediaRefKey(item.UserID, item.EntityURL)
+	item.LastUpdate = time.Now().UnixMilli()
+	return config.db.Update(func(tx *bolt.Tx) error {
+		if err := boltPut(tx, boltMediaRefsTable, numKey(item.TweetID), strKey(item.RefKey), item); err != nil {
+			return err
+		}
+		// The GSI range key must be unique within a hash, and RefKey only is within a tweet.
+		return boltPut(tx, boltMediaRefsTableGSIHash, strKey(item.Hash), append(numKey(item.TweetID), item.RefKey...), item)
+	})
+}
+
+// PutMediaRetry records a failed media download. Attempts is incremented on every call; the
+// caller's value is ignored.
+func (config *BoltDriver) PutMediaRetry(item *MediaRetryItem) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		existing := &MediaRetryItem{}
+		if _, err := boltGet(tx, boltMediaRetriesTable, strKey(item.EntityURL), strKey(item.EntityURL), existing); err != nil {
+			return err
+		}
+		return boltPut(tx, boltMediaRetriesTable, strKey(item.EntityURL), strKey(item.EntityURL), &MediaRetryItem{
+			EntityURL:  item.EntityURL,
+			UserID:     item.UserID,
+			TweetID:    item.TweetID,
+			MediaType:  item.MediaType,
+			Status:     item.Status,
+			LastError:  item.LastError,
+			Attempts:   existing.Attempts + 1,
+			LastUpdate: time.Now().UnixMilli(),
+		})
+	})
+}
+
+func (config *BoltDriver) PutConversation(items []*ConversationItem) error {
+	now := time.Now().UnixMilli()
+	return config.db.Update(func(tx *bolt.Tx) error {
+		for _, item := range items {
+			item.LastUpdate = now
+			if err := boltPut(tx, boltConversationsTable, numKey(item.ConversationID), numKey(item.TweetID), item); err != nil {
+				return err
+			}
+			if err := boltPut(tx, boltConversationsTableGSITweetid, numKey(item.TweetID), numKey(item.ConversationID), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (config *BoltDriver) PutFace(item *FaceItem) error {
+	item.LastUpdate = time.Now().UnixMilli()
+	return config.db.Update(func(tx *bolt.Tx) error {
+		return boltPut(tx, boltFacesTable, strKey(item.FaceID), strKey(item.FaceID), item)
+	})
+}
+
+func (config *BoltDriver) PutFavorites(links []*UserToTweetLink) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		for _, link := range links {
+			if err := boltPut(tx, boltFavoritesTable, numKey(link.UserID), numKey(link.TweetID), link); err != nil {
+				return err
+			}
+			if err := boltPut(tx, boltFavoritesTableGSITweetid, numKey(link.TweetID), numKey(link.UserID), link); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (config *BoltDriver) PutFollowers(links []*UserToFollowerLink) error {
+	now := time.Now().UnixMilli()
+	return config.db.Update(func(tx *bolt.Tx) error {
+		for _, link := range links {
+			item := &UserToFollowerLink{}
+			found, err := boltGet(tx, boltFollowersTable, numKey(link.UserID), numKey(link.FollowerID), item)
+			if err != nil {
+				return err
+			}
+			if !found {
+				item = &UserToFollowerLink{UserID: link.UserID, FollowerID: link.FollowerID, FirstSeen: now}
+			}
+			item.LastSeen = now
+			if err := boltPut(tx, boltFollowersTable, numKey(item.UserID), numKey(item.FollowerID), item); err != nil {
+				return err
+			}
+			if err := boltPut(tx, boltFollowersTableGSIFollowerid, numKey(item.FollowerID), numKey(item.UserID), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (config *BoltDriver) PutFriends(links []*UserToFriendLink) error {
+	now := time.Now().UnixMilli()
+	return config.db.Update(func(tx *bolt.Tx) error {
+		for _, link := range links {
+			item := &UserToFriendLink{}
+			found, err := boltGet(tx, boltFriendsTable, numKey(link.UserID), numKey(link.FriendID), item)
+			if err != nil {
+				return err
+			}
+			if !found {
+				item = &UserToFriendLink{UserID: link.UserID, FriendID: link.FriendID, FirstSeen: now}
+			}
+			item.LastSeen = now
+			if err := boltPut(tx, boltFriendsTable, numKey(item.UserID), numKey(item.FriendID), item); err != nil {
+				return err
+			}
+			if err := boltPut(tx, boltFriendsTableGSIFriendid, numKey(item.FriendID), numKey(item.UserID), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (config *BoltDriver) PutFavoritesConfig(query *TweetConfigQuery) error {
+	now := time.Now()
+	return config.putParams(query.UserID, "favorites", &FavoritesItem{
+		Domain:       "favorites",
+		UserID:       query.UserID,
+		MaxID:        query.MaxID,
+		SinceID:      query.SinceID,
+		PendingMaxID: query.PendingMaxID,
+		LastUpdate:   now.UnixMilli(),
+	})
+}
+
+func (config *BoltDriver) PutFollowersConfig(query *CursoredTweetConfigQuery) error {
+	now := time.Now()
+	return config.putParams(query.UserID, "followers", &FollowersItem{
+		Domain:         "followers",
+		UserID:         query.UserID,
+		PreviousCursor: query.PreviousCursor,
+		NextCursor:     query.NextCursor,
+		PassStarted:    query.PassStarted,
+		PassCompleted:  query.PassCompleted,
+		Passes:         query.Passes,
+		LastUpdate:     now.UnixMilli(),
+	})
+}
+
+func (config *BoltDriver) PutFriendsConfig(query *CursoredTweetConfigQuery) error {
+	now := time.Now()
+	return config.putParams(query.UserID, "friends", &FriendsItem{
+		Domain:         "friends",
+		UserID:         query.UserID,
+		PreviousCursor: query.PreviousCursor,
+		NextCursor:     query.NextCursor,
+		PassStarted:    query.PassStarted,
+		PassCompleted:  query.PassCompleted,
+		Passes:         query.Passes,
+		LastUpdate:     now.UnixMilli(),
+	})
+}
+
+func (config *BoltDriver) PutGap(query *GapQuery) error {
+	now := time.Now()
+	domain := gapDomain(query.Kind, query.SinceID)
+	return config.putParams(query.UserID, domain, &GapItem{
+		Domain:     domain,
+		UserID:     query.UserID,
+		Kind:       query.Kind,
+		SinceID:    query.SinceID,
+		MaxID:      query.MaxID,
+		Count:      query.Count,
+		LastUpdate: now.UnixMilli(),
+	})
+}
+
+func (config *BoltDriver) PutHistory(items []*HistoryItem) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		for _, item := range items {
+			if err := boltPut(tx, boltHistoryTable, numKey(item.UserID), strKey(item.EventKey), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (config *BoltDriver) PutMedia(mediaItem *MediaItem) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		if err := boltPut(tx, boltMediaTable, numKey(mediaItem.TweetID), strKey(mediaItem.S3Key), mediaItem); err != nil {
+			return err
+		}
+		return boltPut(tx, boltMediaTableGSIUserid, numKey(mediaItem.UserID), strKey(mediaItem.S3Key), mediaItem)
+	})
+}
+
+// PutRateLimit seeds endpoint's bucket; a newer window always wins and within a window the
+// lowest Remaining wins.
+func (config *BoltDriver) PutRateLimit(endpoint string, limit int, remaining int, reset time.Time) error {
+	return config.db.Update(func(tx *bolt.Tx) error {
+		item := &RateLimitItem{}
+		found, err := boltGet(tx, boltRateLimitsTable, strKey(endpoint), strKey(endpoint), item)
+		if err != nil {
+			return err
+		}
+		if found && (item.Reset > reset.Unix() || (item.Reset == reset.Unix() && item.Remaining <= int64(remaining))) {
+			return nil
+		}
+		return boltPut(tx, boltRateLimitsTable, strKey(endpoint), strKey(endpoint), &RateLimitItem{
+			Endpoint:   endpoint,
+			Limit:      int64(limit),
+			Remaining:  int64(remaining),
+			Reset:      reset.Unix(),
+			LastUpdate: time.Now().UnixMilli(),
+		})
+	})
+}
+
+// PutSeenTweet marks tweetID as seen until now+ttl. ok is false when it was already seen and
+// the mark hasn't expired.
+func (config *BoltDriver) PutSeenTweet(tweetID int64, ttl time.Duration) (ok bool, err error) {
+	now := time.Now()
+	err = config.db.Update(func(tx *bolt.Tx) error {
+		item := &SeenTweetItem{}
+		found, err := boltGet(tx, boltSeenTweetsTable, numKey(tweetID), numKey(tweetID), item)
+		if err != nil {
+			return err
+		}
+		if found && item.Expires >= now.Unix() {
+			return nil
+		}
+		ok = true
+		return boltPut(tx, boltSeenTweetsTable, numKey(tweetID), numKey(tweetID), &SeenTweetItem{
+			TweetID:    tweetID,
+			Expires:    now.Add(ttl).Unix(),
+			LastUpdate: now.UnixMilli(),
+		})
+	})
+	return ok, err
+}
+
+// PutHydratedUsers marks userIDs as hydrated until now+ttl.
+func (config *BoltDriver) PutHydratedUsers(userIDs []int64, ttl time.Duration) error {
+	now := time.Now()
+	return config.db.Update(func(tx *bolt.Tx) error {
+		for _, userID := range userIDs {
+			if err := boltPut(tx, boltHydratedUsersTable, numKey(userID), numKey(userID), &HydratedUserItem{
+				UserID:     userID,
+				Expires:    now.Add(ttl).Unix(),
+				LastUpdate: now.UnixMilli(),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (config *BoltDriver) PutTimelineConfig(query *TweetConfigQuery) error {
+	now := time.Now()
+	return config.putParams(query.UserID, "tweets", &TweetsItem{
+		Domain:     "tweets",
+		UserID:     query.UserID,
+		MaxID:      query.MaxID,
+		SinceID:    query.SinceID,
+		LastUpdate: now.UnixMilli(),
+	})
+}
+
+func (config *BoltDriver) PutTimelineBackfillConfig(query *BackfillConfigQuery) error {
+	now := time.Now()
+	return config.putParams(query.UserID, "tweets_backfill", &BackfillItem{
+		Domain:     "tweets_backfill",
+		UserID:     query.UserID,
+		NextMaxID:  query.NextMaxID,
+		Count:      query.Count,
+		Complete:   query.Complete,
+		LastUpdate: now.UnixMilli(),
+	})
+}
+
+func (config *BoltDriver) PutRunnerFlags(params *RunnerItem) error {
+	now := time.Now()
+	return config.db.Update(func(tx *bolt.Tx) error {
+		return boltPut(tx, boltRunnerTable, strKey(params.RunnerName), numKey(params.UserID), &RunnerItem{
+			RunnerName: params.RunnerName,
+			UserID:     params.UserID,
+			Flags:      params.Flags,
+			Paused:     params.Paused,
+			LastUpdate: now.UnixMilli(),
+		})
+	})
+}
+
+// SpendBudget adds calls to the amount spent from budget on day, unless that would take it
+// over limit. ok is false when the budget can't cover calls.
+func (config *BoltDriver) SpendBudget(budget string, day string, calls int64, limit int64) (ok bool, err error) {
+	err = config.db.Update(func(tx *bolt.Tx) error {
+		item := &BudgetItem{}
+		if _, err := boltGet(tx, boltBudgetsTable, strKey(budget), strKey(day), item); err != nil {
+			return err
+		}
+		if item.Spent+calls > limit {
+			return nil
+		}
+		ok = true
+		return boltPut(tx, boltBudgetsTable, strKey(budget), strKey(day), &BudgetItem{
+			Budget:     budget,
+			Day:        day,
+			Limit:      limit,
+			Spent:      item.Spent + calls,
+			LastUpdate: time.Now().UnixMilli(),
+		})
+	})
+	return ok, err
+}
+
+// TakeRateLimit takes one call from endpoint's bucket. An unknown or expired window lets the
+// call through.
+func (config *BoltDriver) TakeRateLimit(endpoint string, now time.Time) (reset time.Time, ok bool, err error) {
+	err = config.db.Update(func(tx *bolt.Tx) error {
+		item := &RateLimitItem{}
+		found, err := boltGet(tx, boltRateLimitsTable, strKey(endpoint), strKey(endpoint), item)
+		if err != nil {
+			return err
+		}
+		if !found || item.Reset <= now.Unix() {
+			ok = true
+			return nil
+		}
+		if item.Remaining <= 0 {
+			reset = time.Unix(item.Reset, 0)
+			return nil
+		}
+		ok = true
+		item.Remaining--
+		return boltPut(tx, boltRateLimitsTable, strKey(endpoint), strKey(endpoint), item)
+	})
+	return reset, ok, err
+}
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestBolt returns a bolt database in a temp dir, closed when the test ends.
+func newTestBolt(t *testing.T) *BoltDriver {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	db := NewBolt(SetBoltPath(filepath.Join(t.TempDir(), "tndx.db")), SetBoltLogger(log))
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestBoltSpendBudget(t *testing.T) {
+	db := newTestBolt(t)
+
+	tests := []struct {
+		name  string
+		day   string
+		calls int64
+		want  bool
+	}{
+		{"first spend", "2026-10-17", 3, true},
+		{"up to the limit", "2026-10-17", 2, true},
+		{"over the limit", "2026-10-17", 1, false},
+		{"next day", "2026-10-18", 5, true},
+		{"larger than the limit", "2026-10-19", 6, false},
+	}
+	for _, test := range tests {
+		ok, err := db.SpendBudget("rekognition", test.day, test.calls, 5)
+		if err != nil || ok != test.want {
+			t.Errorf("%s: SpendBudget(%s, %d) = %v, %v; want %v", test.name, test.day, test.calls, ok, err, test.want)
+		}
+	}
+
+	// A refused spend leaves the day's total alone.
+	if ok, err := db.SpendBudget("rekognition", "2026-10-19", 5, 5); err != nil || !ok {
+		t.Errorf("SpendBudget after a refusal = %v, %v; want true", ok, err)
+	}
+	// Budgets are tracked separately.
+	if ok, err := db.SpendBudget("other", "2026-10-17", 5, 5); err != nil || !ok {
+		t.Errorf("SpendBudget(other) = %v, %v; want true", ok, err)
+	}
+}
+
+func TestBoltRateLimit(t *testing.T) {
+	db := newTestBolt(t)
+	now := time.Unix(1_800_000_000, 0)
+	reset := now.Add(15 * time.Minute)
+
+	// An endpoint nobody has reported on lets calls through.
+	if _, ok, err := db.TakeRateLimit("users/show", now); err != nil || !ok {
+		t.Fatalf("TakeRateLimit on an unknown endpoint = %v, %v; want true", ok, err)
+	}
+
+	if err := db.PutRateLimit("users/show", 900, 2, reset); err != nil {
+		t.Fatalf("PutRateLimit: %v", err)
+	}
+	// A stale report with more calls left doesn't refill the window.
+	if err := db.PutRateLimit("users/show", 900, 800, reset); err != nil {
+		t.Fatalf("PutRateLimit: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok, err := db.TakeRateLimit("users/show", now); err != nil || !ok {
+			t.Fatalf("TakeRateLimit %d = %v, %v; want true", i, ok, err)
+		}
+	}
+	got, ok, err := db.TakeRateLimit("users/show", now)
+	if err != nil || ok || !got.Equal(reset) {
+		t.Fatalf("TakeRateLimit once exhausted = %v, %v, %v; want false until %v", got, ok, err, reset)
+	}
+
+	// Another endpoint has its own budget.
+	if _, ok, err := db.TakeRateLimit("users/lookup", now); err != nil || !ok {
+		t.Errorf("TakeRateLimit on another endpoint = %v, %v; want true", ok, err)
+	}
+
+	// Once the window resets, calls go through again until a new report arrives.
+	later := reset.Add(time.Second)
+	if _, ok, err := db.TakeRateLimit("users/show", later); err != nil || !ok {
+		t.Errorf("TakeRateLimit after reset = %v, %v; want true", ok, err)
+	}
+	if err := db.PutRateLimit("users/show", 900, 0, later.Add(15*time.Minute)); err != nil {
+		t.Fatalf("PutRateLimit: %v", err)
+	}
+	if _, ok, err := db.TakeRateLimit("users/show", later); err != nil || ok {
+		t.Errorf("TakeRateLimit after a new exhausted window = %v, %v; want false", ok, err)
+	}
+}
+
+func TestBoltParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tndx.db")
+	db := NewBolt(SetBoltPath(path))
+
+	// An unknown user has empty configs rather than an error.
+	if item, err := db.GetTimelineConfig(1); err != nil || item.MaxID != 0 {
+		t.Fatalf("GetTimelineConfig before any sync = %+v, %v; want an empty item", item, err)
+	}
+
+	if err := db.PutTimelineConfig(&TweetConfigQuery{UserID: 1, SinceID: 10, MaxID: 20}); err != nil {
+		t.Fatalf("PutTimelineConfig: %v", err)
+	}
+	if err := db.PutFavoritesConfig(&TweetConfigQuery{UserID: 1, SinceID: 30, MaxID: 40}); err != nil {
+		t.Fatalf("PutFavoritesConfig: %v", err)
+	}
+	if err := db.PutFollowersConfig(&CursoredTweetConfigQuery{UserID: 1, NextCursor: 5, PassStarted: 100, Passes: 2}); err != nil {
+		t.Fatalf("PutFollowersConfig: %v", err)
+	}
+	if err := db.PutFriendsConfig(&CursoredTweetConfigQuery{UserID: 2, NextCursor: 6}); err != nil {
+		t.Fatalf("PutFriendsConfig: %v", err)
+	}
+
+	// The configs outlive the process.
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	db = NewBolt(SetBoltPath(path))
+	defer db.Close()
+
+	timeline, err := db.GetTimelineConfig(1)
+	if err != nil || timeline.Domain != "tweets" || timeline.SinceID != 10 || timeline.MaxID != 20 || timeline.LastUpdate == 0 {
+		t.Errorf("GetTimelineConfig = %+v, %v; want tweets 10..20", timeline, err)
+	}
+	favorites, err := db.GetFavoritesConfig(1)
+	if err != nil || favorites.Domain != "favorites" || favorites.SinceID != 30 || favorites.MaxID != 40 {
+		t.Errorf("GetFavoritesConfig = %+v, %v; want favorites 30..40", favorites, err)
+	}
+	followers, err := db.GetFollowersConfig(1)
+	if err != nil || followers.NextCursor != 5 || followers.PassStarted != 100 || followers.Passes != 2 {
+		t.Errorf("GetFollowersConfig = %+v, %v; want cursor 5 of pass 100", followers, err)
+	}
+	// Each domain and user is stored separately.
+	if friends, err := db.GetFriendsConfig(1); err != nil || friends.NextCursor != 0 {
+		t.Errorf("GetFriendsConfig(1) = %+v, %v; want an empty item", friends, err)
+	}
+	if friends, err := db.GetFriendsConfig(2); err != nil || friends.NextCursor != 6 {
+		t.Errorf("GetFriendsConfig(2) = %+v, %v; want cursor 6", friends, err)
+	}
+}
+
+func TestBoltFollowersAndFriends(t *testing.T) {
+	db := newTestBolt(t)
+
+	if err := db.PutFollowers([]*UserToFollowerLink{{UserID: 1, FollowerID: 10}, {UserID: 1, FollowerID: 11}, {UserID: 2, FollowerID: 10}}); err != nil {
+		t.Fatalf("PutFollowers: %v", err)
+	}
+	if err := db.PutFriends([]*UserToFriendLink{{UserID: 1, FriendID: 20}}); err != nil {
+		t.Fatalf("PutFriends: %v", err)
+	}
+
+	followers, err := db.GetFollowersByUserId(1)
+	if err != nil || len(followers) != 2 || followers[0].FollowerID != 10 || followers[1].FollowerID != 11 {
+		t.Fatalf("GetFollowersByUserId(1) = %+v, %v; want followers 10 and 11", followers, err)
+	}
+	if followers[0].FirstSeen == 0 || followers[0].LastSeen < followers[0].FirstSeen {
+		t.Errorf("follower edge = %+v, want FirstSeen and LastSeen set", followers[0])
+	}
+	if followedBy, err := db.GetFollowersByFollowId(10); err != nil || len(followedBy) != 2 {
+		t.Errorf("GetFollowersByFollowId(10) = %+v, %v; want users 1 and 2", followedBy, err)
+	}
+	if friends, err := db.GetFriendsByUserId(1); err != nil || len(friends) != 1 || friends[0].FriendID != 20 {
+		t.Errorf("GetFriendsByUserId(1) = %+v, %v; want friend 20", friends, err)
+	}
+	if friendOf, err := db.GetFriendsByFriendId(20); err != nil || len(friendOf) != 1 || friendOf[0].UserID != 1 {
+		t.Errorf("GetFriendsByFriendId(20) = %+v, %v; want user 1", friendOf, err)
+	}
+
+	// Deleting an edge removes it from both directions.
+	if err := db.DeleteFollower(&UserToFollowerLink{UserID: 1, FollowerID: 10}); err != nil {
+		t.Fatalf("DeleteFollower: %v", err)
+	}
+	if err := db.DeleteFriend(&UserToFriendLink{UserID: 1, FriendID: 20}); err != nil {
+		t.Fatalf("DeleteFriend: %v", err)
+	}
+	if followers, err := db.GetFollowersByUserId(1); err != nil || len(followers) != 1 || followers[0].FollowerID != 11 {
+		t.Errorf("GetFollowersByUserId(1) after delete = %+v, %v; want follower 11", followers, err)
+	}
+	if followedBy, err := db.GetFollowersByFollowId(10); err != nil || len(followedBy) != 1 || followedBy[0].UserID != 2 {
+		t.Errorf("GetFollowersByFollowId(10) after delete = %+v, %v; want user 2", followedBy, err)
+	}
+	if friendOf, err := db.GetFriendsByFriendId(20); err != nil || len(friendOf) != 0 {
+		t.Errorf("GetFriendsByFriendId(20) after delete = %+v, %v; want none", friendOf, err)
+	}
+}
+
+func TestBoltFavorites(t *testing.T) {
+	db := newTestBolt(t)
+
+	if err := db.PutFavorites([]*UserToTweetLink{{UserID: 1, TweetID: 100}, {UserID: 1, TweetID: 101}, {UserID: 2, TweetID: 100}}); err != nil {
+		t.Fatalf("PutFavorites: %v", err)
+	}
+	if favorites, err := db.GetFavoritesByUserId(1); err != nil || len(favorites) != 2 {
+		t.Errorf("GetFavoritesByUserId(1) = %+v, %v; want 2", favorites, err)
+	}
+	if favorites, err := db.GetFavoritesByTweetId(100); err != nil || len(favorites) != 2 {
+		t.Errorf("GetFavoritesByTweetId(100) = %+v, %v; want 2", favorites, err)
+	}
+	if favorites, err := db.GetFavoritesByUserId(3); err != nil || len(favorites) != 0 {
+		t.Errorf("GetFavoritesByUserId(3) = %+v, %v; want none", favorites, err)
+	}
+}
+
+func TestBoltRunners(t *testing.T) {
+	db := newTestBolt(t)
+
+	for _, item := range []*RunnerItem{
+		{RunnerName: "hourly", UserID: 1, Flags: Set(0, F_timeline)},
+		{RunnerName: "hourly", UserID: 2, Flags: Set(0, F_user)},
+		{RunnerName: "daily", UserID: 1, Flags: Set(0, F_followers)},
+	} {
+		if err := db.PutRunnerFlags(item); err != nil {
+			t.Fatalf("PutRunnerFlags: %v", err)
+		}
+	}
+
+	if users, err := db.GetRunnerUsers(&RunnerItem{RunnerName: "hourly"}); err != nil || len(users) != 2 {
+		t.Errorf("GetRunnerUsers(hourly) = %+v, %v; want 2", users, err)
+	}
+	users, err := db.GetRunnerUsers(&RunnerItem{RunnerName: "hourly", UserID: 1})
+	if err != nil || len(users) != 1 || !Has(users[0].Flags, F_timeline) || users[0].LastUpdate == 0 {
+		t.Errorf("GetRunnerUsers(hourly, 1) = %+v, %v; want user 1 with the timeline flag", users, err)
+	}
+	if users, err := db.GetRunnerUsersByUserId(1); err != nil || len(users) != 2 {
+		t.Errorf("GetRunnerUsersByUserId(1) = %+v, %v; want both runners", users, err)
+	}
+
+	if err := db.DeleteRunnerUser(&RunnerItem{RunnerName: "hourly", UserID: 1}); err != nil {
+		t.Fatalf("DeleteRunnerUser: %v", err)
+	}
+	if users, err := db.GetRunnerUsersByUserId(1); err != nil || len(users) != 1 || users[0].RunnerName != "daily" {
+		t.Errorf("GetRunnerUsersByUserId(1) after delete = %+v, %v; want the daily runner", users, err)
+	}
+}
+
+func TestBoltMedia(t *testing.T) {
+	db := newTestBolt(t)
+
+	item := &MediaItem{TweetID: 100, UserID: 1, Bucket: "bucket", S3Key: "media/ab/abc.jpg", Hash: "abc"}
+	if err := db.PutMedia(item); err != nil {
+		t.Fatalf("PutMedia: %v", err)
+	}
+	got, err := db.GetMedia(100, "media/ab/abc.jpg")
+	if err != nil || got.UserID != 1 || got.Hash != "abc" || got.Bucket != "bucket" {
+		t.Fatalf("GetMedia = %+v, %v; want the stored item", got, err)
+	}
+
+	if err := db.DeleteMedia(item); err != nil {
+		t.Fatalf("DeleteMedia: %v", err)
+	}
+	if got, err := db.GetMedia(100, "media/ab/abc.jpg"); err != nil || got.S3Key != "" {
+		t.Errorf("GetMedia after delete = %+v, %v; want an empty item", got, err)
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import "time"
+
+// Database is implemented by every table store backend (DynamoDB, local bolt file).
+// Table exports are DynamoDB specific and live on DDBDriver only.
+type Database interface {
+	DeleteFollower(link *UserToFollowerLink) error
+	DeleteFriend(link *UserToFriendLink) error
+	DeleteGap(userID int64, kind string, sinceID int64) error
+	DeleteMedia(mediaItem *MediaItem) error
+	DeleteMediaRetry(entityURL string) error
+	DeleteRunnerUser(params *RunnerItem) error
+	GetAccountStatus(userID int64) (*AccountItem, error)
+	GetConversation(conversationID int64) ([]*ConversationItem, error)
+	GetConversationsByTweetId(tweetID int64) ([]*ConversationItem, error)
+	GetDeferredMedia() ([]*MediaItem, error)
+	GetDriverName() string
+	GetFace(faceID string) (*FaceItem, error)
+	GetFavoritesByTweetId(tweetID int64) ([]*UserToTweetLink, error)
+	GetFavoritesByUserId(userID int64) ([]*UserToTweetLink, error)
+	GetFollowersByFollowId(followID int64) ([]*UserToFollowerLink, error)
+	GetFollowersByUserId(userID int64) ([]*UserToFollowerLink, error)
+	GetFriendsByFriendId(friendID int64) ([]*UserToFriendLink, error)
+	GetFriendsByUserId(userID int64) ([]*UserToFriendLink, error)
+	GetFavoritesConfig(userID int64) (*FavoritesItem, error)
+	GetFollowersConfig(userID int64) (*FollowersItem, error)
+	GetFriendsConfig(userID int64) (*FriendsItem, error)
+	GetGaps(userID int64, kind string) ([]*GapItem, error)
+	GetHistory(userID int64, domain string, since int64) ([]*HistoryItem, error)
+	GetHydratedUsers(userIDs []int64) ([]*HydratedUserItem, error)
+	GetMedia(tweetID int64, s3Key string) (*MediaItem, error)
+	GetMediaByClusterId(clusterID string) ([]*MediaClusterItem, error)
+	GetMediaHashes() ([]*MediaItem, error)
+	GetMediaRefsByHash(hash string) ([]*MediaRefItem, error)
+	GetMediaRetries() ([]*MediaRetryItem, error)
+	GetRateLimits() ([]*RateLimitItem, error)
+	GetRunnerUsers(runnerUsers *RunnerItem) ([]*RunnerItem, error)
+	GetRunnerUsersByUserId(userID int64) ([]*RunnerItem, error)
+	GetSeenTweet(tweetID int64) (*SeenTweetItem, error)
+	GetTimelineConfig(userID int64) (*TweetsItem, error)
+	GetTimelineBackfillConfig(userID int64) (*BackfillItem, error)
+	PutAccountStatus(query *AccountStatusQuery) error
+	PutConversation(items []*ConversationItem) error
+	PutFace(item *FaceItem) error
+	PutFavorites(links []*UserToTweetLink) error
+	PutFollowers(links []*UserToFollowerLink) error
+	PutFriends(links []*UserToFriendLink) error
+	PutFavoritesConfig(query *TweetConfigQuery) error
+	PutFollowersConfig(query *CursoredTweetConfigQuery) error
+	PutFriendsConfig(query *CursoredTweetConfigQuery) error
+	PutGap(query *GapQuery) error
+	PutHistory(items []*HistoryItem) error
+	PutHydratedUsers(userIDs []int64, ttl time.Duration) error
+	PutMedia(mediaItem *MediaItem) error
+	PutMediaClusters(items []*MediaClusterItem) error
+	PutMediaRef(item *MediaRefItem) error
+	PutMediaRetry(item *MediaRetryItem) error
+	PutTimelineConfig(query *TweetConfigQuery) error
+	PutTimelineBackfillConfig(query *BackfillConfigQuery) error
+	PutRateLimit(endpoint string, limit int, remaining int, reset time.Time) error
+	PutRunnerFlags(params *RunnerItem) error
+	PutSeenTweet(tweetID int64, ttl time.Duration) (ok bool, err error)
+	SpendBudget(budget string, day string, calls int64, limit int64) (ok bool, err error)
+	TakeRateLimit(endpoint string, now time.Time) (reset time.Time, ok bool, err error)
+}
+
+var (
+	_ Database = (*DDBDriver)(nil)
+	_ Database = (*BoltDriver)(nil)
+)
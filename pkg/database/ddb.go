@@ -2,6 +2,8 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -21,33 +23,49 @@ type Bits uint8
 type DDBOption func(config *DDBDriver)
 
 type DDBDriver struct {
-	log                         *logrus.Logger
-	driverName                  string
-	tablePrefix                 string
-	region                      string
-	profile                     string
-	favoritesTable              string
-	favoritesTableGSITweetid    string
-	friendsTable                string
-	friendsTableGSIFriendid     string
-	followersTable              string
-	followersTableGSIFollowerid string
-	runnerTable                 string
-	mediaTable                  string
-	paramsTable                 string
-	db                          *dynamodb.Client
+	log                          *logrus.Logger
+	driverName                   string
+	tablePrefix                  string
+	region                       string
+	profile                      string
+	conversationsTable           string
+	conversationsTableGSITweetid string
+	favoritesTable               string
+	favoritesTableGSITweetid     string
+	friendsTable                 string
+	friendsTableGSIFriendid      string
+	followersTable               string
+	followersTableGSIFollowerid  string
+	runnerTable                  string
+	historyTable                 string
+	rateLimitsTable              string
+	budgetsTable                 string
+	seenTweetsTable              string
+	hydratedUsersTable           string
+	mediaTable                   string
+	mediaTableGSIClusterid       string
+	facesTable                   string
+	mediaRetriesTable            string
+	mediaRefsTable               string
+	mediaRefsTableGSIHash        string
+	paramsTable                  string
+	db                           *dynamodb.Client
 }
 type TweetConfigQuery struct {
-	UserID     int64
-	SinceID    int64
-	MaxID      int64
-	LastUpdate int64
+	UserID       int64
+	SinceID      int64
+	MaxID        int64
+	PendingMaxID int64
+	LastUpdate   int64
 }
 
 type CursoredTweetConfigQuery struct {
 	UserID         int64
 	PreviousCursor int64
 	NextCursor     int64
+	PassStarted    int64
+	PassCompleted  int64
+	Passes         int64
 }
 type TweetsItem struct {
 	Domain              string    `json:"Domain" yaml:"Domain"`
@@ -58,26 +76,152 @@ type TweetsItem struct {
 	LastUpdateTimestamp time.Time `json:"LastUpdateTimestamp" yaml:"LastUpdateTimestamp"`
 }
 
+type BackfillConfigQuery struct {
+	UserID    int64
+	NextMaxID int64
+	Count     int64
+	Complete  bool
+}
+
+// BackfillItem tracks a walk backwards through a user's history. NextMaxID is the
+// max_id for the next page; Complete is set once Twitter stops returning tweets.
+type BackfillItem struct {
+	Domain              string    `json:"Domain" yaml:"Domain"`
+	UserID              int64     `json:"UserID" yaml:"UserID"`
+	NextMaxID           int64     `json:"NextMaxID" yaml:"NextMaxID"`
+	Count               int64     `json:"Count" yaml:"Count"`
+	Complete            bool      `json:"Complete" yaml:"Complete"`
+	LastUpdate          int64     `json:"LastUpdate" yaml:"LastUpdate"`
+	LastUpdateTimestamp time.Time `json:"LastUpdateTimestamp" yaml:"LastUpdateTimestamp"`
+}
+
+type GapQuery struct {
+	UserID  int64
+	Kind    string
+	SinceID int64
+	MaxID   int64
+	Count   int64
+}
+
+// GapItem is an open range of tweets skipped by an incremental timeline sync. Kind is the
+// params domain the gap belongs to ("tweets"). Tweets with IDs in (SinceID, MaxID] are
+// still missing; the gap closes from the top down.
+type GapItem struct {
+	Domain              string    `json:"Domain" yaml:"Domain"`
+	UserID              int64     `json:"UserID" yaml:"UserID"`
+	Kind                string    `json:"Kind" yaml:"Kind"`
+	SinceID             int64     `json:"SinceID" yaml:"SinceID"`
+	MaxID               int64     `json:"MaxID" yaml:"MaxID"`
+	Count               int64     `json:"Count" yaml:"Count"`
+	LastUpdate          int64     `json:"LastUpdate" yaml:"LastUpdate"`
+	LastUpdateTimestamp time.Time `json:"LastUpdateTimestamp" yaml:"LastUpdateTimestamp"`
+}
+
+// gapDomain returns the params range key for a gap. Gaps are keyed by their lower
+// bound, which stays fixed while the gap is filled.
+func gapDomain(kind string, sinceID int64) string {
+	return gapDomainPrefix(kind) + strconv.FormatInt(sinceID, 10)
+}
+
+func gapDomainPrefix(kind string) string {
+	return kind + "_gap#"
+}
+
 type UserToTweetLink struct {
 	UserID  int64 `json:"UserID"`
 	TweetID int64 `json:"TweetID"`
 }
 
+// UserToFollowerLink is a follower edge. FirstSeen and LastSeen (unix ms) are maintained
+// by PutFollowers; callers don't need to set them.
 type UserToFollowerLink struct {
 	UserID     int64 `json:"UserID"`
 	FollowerID int64 `json:"FollowerID"`
+	FirstSeen  int64 `json:"FirstSeen"`
+	LastSeen   int64 `json:"LastSeen"`
 }
 
+// UserToFriendLink is a friend edge. FirstSeen and LastSeen (unix ms) are maintained
+// by PutFriends; callers don't need to set them.
 type UserToFriendLink struct {
-	UserID   int64 `json:"UserID"`
-	FriendID int64 `json:"FriendID"`
+	UserID    int64 `json:"UserID"`
+	FriendID  int64 `json:"FriendID"`
+	FirstSeen int64 `json:"FirstSeen"`
+	LastSeen  int64 `json:"LastSeen"`
+}
+
+// HistoryItem is a dated change to a user's followers or friends. Domain is "followers"
+// or "friends", Event is "followed" or "unfollowed" and OtherID is the user on the other
+// end of the edge.
+type HistoryItem struct {
+	UserID    int64  `json:"UserID" yaml:"UserID"`
+	EventKey  string `json:"EventKey" yaml:"EventKey"`
+	Domain    string `json:"Domain" yaml:"Domain"`
+	Event     string `json:"Event" yaml:"Event"`
+	OtherID   int64  `json:"OtherID" yaml:"OtherID"`
+	Timestamp int64  `json:"Timestamp" yaml:"Timestamp"`
+}
+
+// HistoryEventKey returns the history range key, which sorts events by domain then time.
+func HistoryEventKey(domain string, timestamp int64, otherID int64) string {
+	return fmt.Sprintf("%s#%013d#%d", domain, timestamp, otherID)
+}
+
+// RateLimitItem is the token bucket shared by every client calling a Twitter endpoint with the
+// same app credentials. Remaining counts the calls left until Reset (unix seconds).
+type RateLimitItem struct {
+	Endpoint   string `json:"Endpoint" yaml:"Endpoint"`
+	Limit      int64  `json:"Limit" yaml:"Limit"`
+	Remaining  int64  `json:"Remaining" yaml:"Remaining"`
+	Reset      int64  `json:"Reset" yaml:"Reset"`
+	LastUpdate int64  `json:"LastUpdate" yaml:"LastUpdate"`
+}
+
+// BudgetItem counts the calls spent from a daily budget. Day is a UTC date (2006-01-02).
+type BudgetItem struct {
+	Budget     string `json:"Budget" yaml:"Budget"`
+	Day        string `json:"Day" yaml:"Day"`
+	Limit      int64  `json:"Limit" yaml:"Limit"`
+	Spent      int64  `json:"Spent" yaml:"Spent"`
+	LastUpdate int64  `json:"LastUpdate" yaml:"LastUpdate"`
+}
+
+// ConversationItem places a tweet in a reply thread. ConversationID is the oldest ancestor the
+// thread walk reached and ParentID is the tweet this one replies to (0 for a true root). A root
+// with a non-zero ParentID was cut off by the depth limit or a missing parent.
+type ConversationItem struct {
+	ConversationID int64 `json:"ConversationID" yaml:"ConversationID"`
+	TweetID        int64 `json:"TweetID" yaml:"TweetID"`
+	ParentID       int64 `json:"ParentID" yaml:"ParentID"`
+	UserID         int64 `json:"UserID" yaml:"UserID"`
+	LastUpdate     int64 `json:"LastUpdate" yaml:"LastUpdate"`
 }
 
+// SeenTweetItem records that get_tweet was queued for a tweet. Expires (unix seconds) is the
+// table's TTL attribute; the tweet may be fetched again once it has passed.
+type SeenTweetItem struct {
+	TweetID    int64 `json:"TweetID" yaml:"TweetID"`
+	Expires    int64 `json:"Expires" yaml:"Expires"`
+	LastUpdate int64 `json:"LastUpdate" yaml:"LastUpdate"`
+}
+
+// HydratedUserItem records that hydrate_users stored a user's profile. Expires (unix
+// seconds) is the table's TTL attribute; the profile is looked up again once it has passed.
+type HydratedUserItem struct {
+	UserID     int64 `json:"UserID" yaml:"UserID"`
+	Expires    int64 `json:"Expires" yaml:"Expires"`
+	LastUpdate int64 `json:"LastUpdate" yaml:"LastUpdate"`
+}
+
+// FavoritesItem tracks the incremental favorites sync. PendingMaxID holds the newest tweet
+// seen while older pages are still being walked back to MaxID; it becomes MaxID once the
+// walk is done.
 type FavoritesItem struct {
 	Domain              string    `json:"Domain" yaml:"Domain"`
 	UserID              int64     `json:"UserID" yaml:"UserID"`
 	MaxID               int64     `json:"MaxID" yaml:"MaxID"`
 	SinceID             int64     `json:"SinceID" yaml:"SinceID"`
+	PendingMaxID        int64     `json:"PendingMaxID" yaml:"PendingMaxID"`
 	LastUpdate          int64     `json:"LastUpdate" yaml:"LastUpdate"`
 	LastUpdateTimestamp time.Time `json:"LastUpdateTimestamp" yaml:"LastUpdateTimestamp"`
 }
@@ -87,6 +231,9 @@ type FollowersItem struct {
 	UserID              int64     `json:"UserID" yaml:"UserID"`
 	NextCursor          int64     `json:"NextCursor" yaml:"NextCursor"`
 	PreviousCursor      int64     `json:"PreviousCursor" yaml:"PreviousCursor"`
+	PassStarted         int64     `json:"PassStarted" yaml:"PassStarted"`
+	PassCompleted       int64     `json:"PassCompleted" yaml:"PassCompleted"`
+	Passes              int64     `json:"Passes" yaml:"Passes"`
 	LastUpdate          int64     `json:"LastUpdate" yaml:"LastUpdate"`
 	LastUpdateTimestamp time.Time `json:"LastUpdateTimestamp" yaml:"LastUpdateTimestamp"`
 }
@@ -96,30 +243,137 @@ type FriendsItem struct {
 	UserID              int64     `json:"UserID" yaml:"UserID"`
 	NextCursor          int64     `json:"NextCursor" yaml:"NextCursor"`
 	PreviousCursor      int64     `json:"PreviousCursor" yaml:"PreviousCursor"`
+	PassStarted         int64     `json:"PassStarted" yaml:"PassStarted"`
+	PassCompleted       int64     `json:"PassCompleted" yaml:"PassCompleted"`
+	Passes              int64     `json:"Passes" yaml:"Passes"`
 	LastUpdate          int64     `json:"LastUpdate" yaml:"LastUpdate"`
 	LastUpdateTimestamp time.Time `json:"LastUpdateTimestamp" yaml:"LastUpdateTimestamp"`
 }
 
+// RunnerItem enables scheduled functions for a user. Paused holds the flags the processor
+// cleared because the account became unavailable; they are set again once it is active.
 type RunnerItem struct {
 	RunnerName string `json:"RunnerName"`
 	UserID     int64  `json:"UserID"`
 	Flags      Bits   `json:"Flags"`
+	Paused     Bits   `json:"Paused"`
 	LastUpdate int64  `json:"LastUpdate"`
 }
 
+// Account statuses recorded by the processor.
+const (
+	AccountActive    = "active"
+	AccountDeleted   = "deleted"
+	AccountProtected = "protected"
+	AccountSuspended = "suspended"
+)
+
+type AccountStatusQuery struct {
+	UserID      int64
+	Status      string
+	StatusSince int64
+}
+
+// AccountItem is the last known state of a user's Twitter account. StatusSince (unix ms)
+// is when Status last changed.
+type AccountItem struct {
+	Domain              string    `json:"Domain" yaml:"Domain"`
+	UserID              int64     `json:"UserID" yaml:"UserID"`
+	Status              string    `json:"Status" yaml:"Status"`
+	StatusSince         int64     `json:"StatusSince" yaml:"StatusSince"`
+	LastUpdate          int64     `json:"LastUpdate" yaml:"LastUpdate"`
+	LastUpdateTimestamp time.Time `json:"LastUpdateTimestamp" yaml:"LastUpdateTimestamp"`
+}
+
+// MediaItem holds the analysis of a stored media object for one tweet. Content-addressed
+// objects are shared, so every tweet referencing one gets its own item with the same Hash.
+// PHash is the image's perceptual hash (see package phash); it is empty for videos. Which
+// detections are filled in depends on the analyzer that produced the item. Quarantined items
+// matched the moderation policy; their object was moved to QuarantineKey and consumers should
+// blur or skip them. Deferred items were stored without detections because the analyzer's
+// budget was spent; they are analyzed again when the object is.
 type MediaItem struct {
-	Bucket          string                             `json:"Bucket"`
-	S3Key           string                             `json:"S3Key"`
-	UserID          int64                              `json:"UserID"`
-	TweetID         int64                              `json:"TweetID"`
-	Faces           []rekognitionTypes.FaceDetail      `json:"Faces"`
-	Labels          []rekognitionTypes.Label           `json:"Labels"`
-	Moderation      []rekognitionTypes.ModerationLabel `json:"Moderation"`
-	Text            []rekognitionTypes.TextDetection   `json:"Text"`
-	FacesCount      int                                `json:"FacesCount"`
-	LabelsCount     int                                `json:"LabelsCount"`
-	ModerationCount int                                `json:"ModerationCount"`
-	TextCount       int                                `json:"TextCount"`
+	Bucket           string                             `json:"Bucket"`
+	S3Key            string                             `json:"S3Key"`
+	Hash             string                             `json:"Hash,omitempty"`
+	PHash            string                             `json:"PHash,omitempty"`
+	Width            int                                `json:"Width,omitempty"`
+	Height           int                                `json:"Height,omitempty"`
+	Format           string                             `json:"Format,omitempty"`
+	Colors           []string                           `json:"Colors,omitempty"`
+	UserID           int64                              `json:"UserID"`
+	TweetID          int64                              `json:"TweetID"`
+	Faces            []rekognitionTypes.FaceDetail      `json:"Faces"`
+	Labels           []rekognitionTypes.Label           `json:"Labels"`
+	Moderation       []rekognitionTypes.ModerationLabel `json:"Moderation"`
+	Text             []rekognitionTypes.TextDetection   `json:"Text"`
+	Celebrities      []rekognitionTypes.Celebrity       `json:"Celebrities,omitempty"`
+	FacesCount       int                                `json:"FacesCount"`
+	LabelsCount      int                                `json:"LabelsCount"`
+	ModerationCount  int                                `json:"ModerationCount"`
+	TextCount        int                                `json:"TextCount"`
+	CelebritiesCount int                                `json:"CelebritiesCount,omitempty"`
+	FaceClusters     []string                           `json:"FaceClusters,omitempty"`
+	Quarantined      bool                               `json:"Quarantined,omitempty"`
+	QuarantineKey    string                             `json:"QuarantineKey,omitempty"`
+	QuarantineLabel  string                             `json:"QuarantineLabel,omitempty"`
+	Deferred         bool                               `json:"Deferred,omitempty"`
+}
+
+// MediaClusterItem records that a face cluster appears in a media object. Cluster items live in
+// the media table next to the media item, keyed by MediaClusterKey, so they can be listed per
+// cluster through the ClusterID GSI. They carry no UserID and so stay out of the UserID GSI.
+type MediaClusterItem struct {
+	TweetID    int64  `json:"TweetID" yaml:"TweetID"`
+	S3Key      string `json:"S3Key" yaml:"S3Key"`
+	ClusterID  string `json:"ClusterID" yaml:"ClusterID"`
+	MediaKey   string `json:"MediaKey" yaml:"MediaKey"`
+	LastUpdate int64  `json:"LastUpdate" yaml:"LastUpdate"`
+}
+
+// MediaClusterKey returns the media table range key of a cluster item for mediaKey.
+func MediaClusterKey(mediaKey string, clusterID string) string {
+	return mediaKey + "#cluster#" + clusterID
+}
+
+// FaceItem maps a face indexed into the Rekognition face collection to its cluster. S3Key is
+// the object the face was first indexed from.
+type FaceItem struct {
+	FaceID     string `json:"FaceID" yaml:"FaceID"`
+	ClusterID  string `json:"ClusterID" yaml:"ClusterID"`
+	S3Key      string `json:"S3Key" yaml:"S3Key"`
+	LastUpdate int64  `json:"LastUpdate" yaml:"LastUpdate"`
+}
+
+// MediaRefItem maps a media URL seen in a tweet, for the user it was crawled for, to the
+// content-addressed object holding it. RefKey is built with MediaRefKey.
+type MediaRefItem struct {
+	TweetID    int64  `json:"TweetID" yaml:"TweetID"`
+	RefKey     string `json:"RefKey" yaml:"RefKey"`
+	UserID     int64  `json:"UserID" yaml:"UserID"`
+	EntityURL  string `json:"EntityURL" yaml:"EntityURL"`
+	MediaType  string `json:"MediaType" yaml:"MediaType"`
+	Hash       string `json:"Hash" yaml:"Hash"`
+	S3Key      string `json:"S3Key" yaml:"S3Key"`
+	LastUpdate int64  `json:"LastUpdate" yaml:"LastUpdate"`
+}
+
+// MediaRefKey returns the media refs range key, unique per user and URL within a tweet.
+func MediaRefKey(userID int64, entityURL string) string {
+	return strconv.FormatInt(userID, 10) + "#" + entityURL
+}
+
+// MediaRetryItem is a media download that failed after the fetcher's own retries. Status is
+// the last HTTP status (0 for a transport error) and Attempts counts failed entities runs.
+type MediaRetryItem struct {
+	EntityURL  string `json:"EntityURL" yaml:"EntityURL"`
+	UserID     int64  `json:"UserID" yaml:"UserID"`
+	TweetID    string `json:"TweetID" yaml:"TweetID"`
+	MediaType  string `json:"MediaType" yaml:"MediaType"`
+	Status     int    `json:"Status" yaml:"Status"`
+	LastError  string `json:"LastError" yaml:"LastError"`
+	Attempts   int64  `json:"Attempts" yaml:"Attempts"`
+	LastUpdate int64  `json:"LastUpdate" yaml:"LastUpdate"`
 }
 
 const (
@@ -180,14 +434,26 @@ func SetDDBRegion(region string) func(*DDBDriver) {
 func SetDDBTablePrefix(tablePrefix string) func(*DDBDriver) {
 	return func(config *DDBDriver) {
 		config.tablePrefix = tablePrefix
+		config.conversationsTable = tablePrefix + "conversations"
+		config.conversationsTableGSITweetid = tablePrefix + "conversations-gsi-tweetid"
 		config.favoritesTable = tablePrefix + "favorites"
 		config.favoritesTableGSITweetid = tablePrefix + "favorites-gsi-tweetid"
 		config.friendsTable = tablePrefix + "friends"
 		config.friendsTableGSIFriendid = tablePrefix + "friends-gsi-friendid"
 		config.followersTable = tablePrefix + "followers"
 		config.followersTableGSIFollowerid = tablePrefix + "followers-gsi-followerid"
+		config.historyTable = tablePrefix + "history"
+		config.rateLimitsTable = tablePrefix + "ratelimits"
+		config.budgetsTable = tablePrefix + "budgets"
 		config.runnerTable = tablePrefix + "runners"
+		config.seenTweetsTable = tablePrefix + "seentweets"
+		config.hydratedUsersTable = tablePrefix + "hydratedusers"
 		config.mediaTable = tablePrefix + "media"
+		config.mediaTableGSIClusterid = tablePrefix + "media-gsi-clusterid"
+		config.facesTable = tablePrefix + "faces"
+		config.mediaRetriesTable = tablePrefix + "mediaretries"
+		config.mediaRefsTable = tablePrefix + "mediarefs"
+		config.mediaRefsTableGSIHash = tablePrefix + "mediarefs-gsi-hash"
 		config.paramsTable = tablePrefix + "parameters"
 	}
 }
@@ -198,6 +464,43 @@ func SetDDBLogger(logger *logrus.Logger) func(*DDBDriver) {
 	}
 }
 
+func (config *DDBDriver) DeleteFollower(link *UserToFollowerLink) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(config.followersTable),
+		Key: map[string]types.AttributeValue{
+			"UserID":     &types.AttributeValueMemberN{Value: strconv.FormatInt(link.UserID, 10)},
+			"FollowerID": &types.AttributeValueMemberN{Value: strconv.FormatInt(link.FollowerID, 10)},
+		},
+	}
+	_, err := config.db.DeleteItem(context.TODO(), input)
+	return err
+}
+
+func (config *DDBDriver) DeleteFriend(link *UserToFriendLink) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(config.friendsTable),
+		Key: map[string]types.AttributeValue{
+			"UserID":   &types.AttributeValueMemberN{Value: strconv.FormatInt(link.UserID, 10)},
+			"FriendID": &types.AttributeValueMemberN{Value: strconv.FormatInt(link.FriendID, 10)},
+		},
+	}
+	_, err := config.db.DeleteItem(context.TODO(), input)
+	return err
+}
+
+func (config *DDBDriver) DeleteGap(userID int64, kind string, sinceID int64) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(config.paramsTable),
+		Key: map[string]types.AttributeValue{
+			"UserID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
+			"Domain": &types.AttributeValueMemberS{Value: gapDomain(kind, sinceID)},
+		},
+	}
+	_, err := config.db.DeleteItem(context.TODO(), input)
+	return err
+}
+
+// DeleteMedia deletes a media item along with its face cluster items.
 func (config *DDBDriver) DeleteMedia(mediaItem *MediaItem) error {
 	input := &dynamodb.DeleteItemInput{
 		TableName: aws.String(config.mediaTable),
@@ -205,6 +508,37 @@ func (config *DDBDriver) DeleteMedia(mediaItem *MediaItem) error {
 			"TweetID": &types.AttributeValueMemberN{Value: strconv.FormatInt(mediaItem.TweetID, 10)},
 			"S3Key":   &types.AttributeValueMemberS{Value: mediaItem.S3Key},
 		},
+		ReturnValues: types.ReturnValueAllOld,
+	}
+	result, err := config.db.DeleteItem(context.TODO(), input)
+	if err != nil || result.Attributes == nil {
+		return err
+	}
+
+	old := &MediaItem{}
+	if err := attributevalue.UnmarshalMap(result.Attributes, old); err != nil {
+		return err
+	}
+	for _, clusterID := range old.FaceClusters {
+		if _, err := config.db.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
+			TableName: aws.String(config.mediaTable),
+			Key: map[string]types.AttributeValue{
+				"TweetID": &types.AttributeValueMemberN{Value: strconv.FormatInt(mediaItem.TweetID, 10)},
+				"S3Key":   &types.AttributeValueMemberS{Value: MediaClusterKey(mediaItem.S3Key, clusterID)},
+			},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (config *DDBDriver) DeleteMediaRetry(entityURL string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(config.mediaRetriesTable),
+		Key: map[string]types.AttributeValue{
+			"EntityURL": &types.AttributeValueMemberS{Value: entityURL},
+		},
 	}
 	_, err := config.db.DeleteItem(context.TODO(), input)
 	return err
@@ -222,10 +556,93 @@ func (config *DDBDriver) DeleteRunnerUser(params *RunnerItem) error {
 	return err
 }
 
+// GetConversation returns every tweet recorded in a conversation. Children are the items
+// whose ParentID points at a tweet.
+func (config *DDBDriver) GetConversation(conversationID int64) ([]*ConversationItem, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(config.conversationsTable),
+		KeyConditionExpression: aws.String("ConversationID = :ConversationID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ConversationID": &types.AttributeValueMemberN{Value: strconv.FormatInt(conversationID, 10)},
+		},
+	}
+
+	results := []*ConversationItem{}
+	paginator := dynamodb.NewQueryPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error querying conversation")
+			return nil, err
+		}
+
+		page := []*ConversationItem{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
+
+func (config *DDBDriver) GetConversationsByTweetId(tweetID int64) ([]*ConversationItem, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(config.conversationsTable),
+		IndexName:              aws.String(config.conversationsTableGSITweetid),
+		KeyConditionExpression: aws.String("TweetID = :TweetID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":TweetID": &types.AttributeValueMemberN{Value: strconv.FormatInt(tweetID, 10)},
+		},
+	}
+	result, err := config.db.Query(context.TODO(), input)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+			"input": input,
+		}).Error("Error querying conversation/tweets")
+		return nil, err
+	}
+
+	results := []*ConversationItem{}
+	attributevalue.UnmarshalListOfMaps(result.Items, &results)
+
+	return results, nil
+}
+
 func (config *DDBDriver) GetDriverName() string {
 	return config.driverName
 }
 
+// GetFace returns the face collection entry for faceID. An empty item is returned when there
+// is none.
+func (config *DDBDriver) GetFace(faceID string) (*FaceItem, error) {
+	result, err := config.db.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(config.facesTable),
+		Key: map[string]types.AttributeValue{
+			"FaceID": &types.AttributeValueMemberS{Value: faceID},
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := &FaceItem{}
+
+	if result.Item == nil {
+		return item, nil
+	}
+
+	err = attributevalue.UnmarshalMap(result.Item, item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
 func (config *DDBDriver) GetFavoritesByTweetId(tweetID int64) ([]*UserToTweetLink, error) {
 	input := &dynamodb.QueryInput{
 		TableName:              aws.String(config.favoritesTable),
@@ -305,17 +722,24 @@ func (config *DDBDriver) GetFollowersByUserId(userID int64) ([]*UserToFollowerLi
 			":userID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
 		},
 	}
-	result, err := config.db.Query(context.TODO(), input)
-	if err != nil {
-		config.log.WithFields(logrus.Fields{
-			"error": err,
-			"input": input,
-		}).Error("Error querying user/followers")
-		return nil, err
-	}
 
+	// Page through every edge; change detection needs the complete set.
 	results := []*UserToFollowerLink{}
-	attributevalue.UnmarshalListOfMaps(result.Items, &results)
+	paginator := dynamodb.NewQueryPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error querying user/followers")
+			return nil, err
+		}
+
+		page := []*UserToFollowerLink{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
 
 	return results, nil
 }
@@ -352,19 +776,53 @@ func (config *DDBDriver) GetFriendsByUserId(userID int64) ([]*UserToFriendLink,
 			":userID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
 		},
 	}
-	result, err := config.db.Query(context.TODO(), input)
+
+	// Page through every edge; change detection needs the complete set.
+	results := []*UserToFriendLink{}
+	paginator := dynamodb.NewQueryPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error querying user/friends")
+			return nil, err
+		}
+
+		page := []*UserToFriendLink{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
+
+func (config *DDBDriver) GetAccountStatus(userID int64) (*AccountItem, error) {
+	result, err := config.db.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(config.paramsTable),
+		Key: map[string]types.AttributeValue{
+			"UserID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
+			"Domain": &types.AttributeValueMemberS{Value: "account"},
+		},
+	})
+
 	if err != nil {
-		config.log.WithFields(logrus.Fields{
-			"error": err,
-			"input": input,
-		}).Error("Error querying user/friends")
 		return nil, err
 	}
 
-	results := []*UserToFriendLink{}
-	attributevalue.UnmarshalListOfMaps(result.Items, &results)
+	item := &AccountItem{}
 
-	return results, nil
+	if result.Item == nil {
+		return item, nil
+	}
+
+	err = attributevalue.UnmarshalMap(result.Item, item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
 }
 
 func (config *DDBDriver) GetFavoritesConfig(userID int64) (*FavoritesItem, error) {
@@ -447,26 +905,17 @@ func (config *DDBDriver) GetFriendsConfig(userID int64) (*FriendsItem, error) {
 	return item, nil
 }
 
-func (config *DDBDriver) GetRunnerUsers(runnerUsers *RunnerItem) ([]*RunnerItem, error) {
-	var input *dynamodb.QueryInput
-
-	if runnerUsers.UserID == 0 {
-		input = &dynamodb.QueryInput{
-			TableName:              aws.String(config.runnerTable),
-			KeyConditionExpression: aws.String("RunnerName = :RunnerName"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":RunnerName": &types.AttributeValueMemberS{Value: runnerUsers.RunnerName},
-			},
-		}
-	} else {
-		input = &dynamodb.QueryInput{
-			TableName:              aws.String(config.runnerTable),
-			KeyConditionExpression: aws.String("RunnerName = :RunnerName and UserID = :UserID"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":RunnerName": &types.AttributeValueMemberS{Value: runnerUsers.RunnerName},
-				":UserID":     &types.AttributeValueMemberN{Value: strconv.FormatInt(runnerUsers.UserID, 10)},
-			},
-		}
+func (config *DDBDriver) GetGaps(userID int64, kind string) ([]*GapItem, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(config.paramsTable),
+		KeyConditionExpression: aws.String("UserID = :UserID and begins_with(#Domain, :Domain)"),
+		ExpressionAttributeNames: map[string]string{
+			"#Domain": "Domain",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":UserID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
+			":Domain": &types.AttributeValueMemberS{Value: gapDomainPrefix(kind)},
+		},
 	}
 
 	result, err := config.db.Query(context.TODO(), input)
@@ -474,22 +923,96 @@ func (config *DDBDriver) GetRunnerUsers(runnerUsers *RunnerItem) ([]*RunnerItem,
 		config.log.WithFields(logrus.Fields{
 			"error": err,
 			"input": input,
-		}).Error("Error querying runner users")
+		}).Error("Error querying gaps")
 		return nil, err
 	}
 
-	results := []*RunnerItem{}
+	results := []*GapItem{}
 	attributevalue.UnmarshalListOfMaps(result.Items, &results)
 
 	return results, nil
 }
 
-func (config *DDBDriver) GetTimelineConfig(userID int64) (*TweetsItem, error) {
+func (config *DDBDriver) GetHistory(userID int64, domain string, since int64) ([]*HistoryItem, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(config.historyTable),
+		KeyConditionExpression: aws.String("UserID = :UserID and EventKey between :From and :To"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":UserID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
+			":From":   &types.AttributeValueMemberS{Value: HistoryEventKey(domain, since, 0)},
+			":To":     &types.AttributeValueMemberS{Value: domain + "#~"},
+		},
+	}
+
+	results := []*HistoryItem{}
+	paginator := dynamodb.NewQueryPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error querying history")
+			return nil, err
+		}
+
+		page := []*HistoryItem{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
+
+// GetHydratedUsers returns the hydrated user marks stored for userIDs, expired or not.
+// DynamoDB deletes expired items lazily, so callers check Expires.
+func (config *DDBDriver) GetHydratedUsers(userIDs []int64) ([]*HydratedUserItem, error) {
+	results := []*HydratedUserItem{}
+	// BatchGetItem takes at most 100 keys per call.
+	for start := 0; start < len(userIDs); start += 100 {
+		end := start + 100
+		if end > len(userIDs) {
+			end = len(userIDs)
+		}
+		keys := make([]map[string]types.AttributeValue, 0, end-start)
+		for _, userID := range userIDs[start:end] {
+			keys = append(keys, map[string]types.AttributeValue{
+				"UserID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
+			})
+		}
+
+		requests := map[string]types.KeysAndAttributes{
+			config.hydratedUsersTable: {Keys: keys},
+		}
+		for len(requests) > 0 {
+			result, err := config.db.BatchGetItem(context.TODO(), &dynamodb.BatchGetItemInput{
+				RequestItems: requests,
+			})
+			if err != nil {
+				config.log.WithFields(logrus.Fields{
+					"error": err,
+					"count": len(keys),
+				}).Error("Error getting hydrated users")
+				return nil, err
+			}
+
+			page := []*HydratedUserItem{}
+			attributevalue.UnmarshalListOfMaps(result.Responses[config.hydratedUsersTable], &page)
+			results = append(results, page...)
+			requests = result.UnprocessedKeys
+		}
+	}
+	return results, nil
+}
+
+// GetMedia returns the media item for tweetID and s3Key. An empty item is returned when
+// there is none.
+func (config *DDBDriver) GetMedia(tweetID int64, s3Key string) (*MediaItem, error) {
 	result, err := config.db.GetItem(context.TODO(), &dynamodb.GetItemInput{
-		TableName: aws.String(config.paramsTable),
+		TableName: aws.String(config.mediaTable),
 		Key: map[string]types.AttributeValue{
-			"UserID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
-			"Domain": &types.AttributeValueMemberS{Value: "tweets"},
+			"TweetID": &types.AttributeValueMemberN{Value: strconv.FormatInt(tweetID, 10)},
+			"S3Key":   &types.AttributeValueMemberS{Value: s3Key},
 		},
 	})
 
@@ -497,7 +1020,7 @@ func (config *DDBDriver) GetTimelineConfig(userID int64) (*TweetsItem, error) {
 		return nil, err
 	}
 
-	item := &TweetsItem{}
+	item := &MediaItem{}
 
 	if result.Item == nil {
 		return item, nil
@@ -511,15 +1034,361 @@ func (config *DDBDriver) GetTimelineConfig(userID int64) (*TweetsItem, error) {
 	return item, nil
 }
 
-func (config *DDBDriver) PutFavorites(links []*UserToTweetLink) error {
-	for _, link := range links {
-		kvp, err := attributevalue.MarshalMap(link)
+// GetMediaByClusterId returns every media object a face cluster appears in.
+func (config *DDBDriver) GetMediaByClusterId(clusterID string) ([]*MediaClusterItem, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(config.mediaTable),
+		IndexName:              aws.String(config.mediaTableGSIClusterid),
+		KeyConditionExpression: aws.String("ClusterID = :ClusterID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ClusterID": &types.AttributeValueMemberS{Value: clusterID},
+		},
+	}
+
+	results := []*MediaClusterItem{}
+	paginator := dynamodb.NewQueryPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error querying media clusters")
+			return nil, err
+		}
+
+		page := []*MediaClusterItem{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
+
+// GetDeferredMedia scans every media item whose analysis was deferred.
+func (config *DDBDriver) GetDeferredMedia() ([]*MediaItem, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(config.mediaTable),
+		FilterExpression: aws.String("Deferred = :True"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":True": &types.AttributeValueMemberBOOL{Value: true},
+		},
+	}
+
+	results := []*MediaItem{}
+	paginator := dynamodb.NewScanPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error scanning media")
+			return nil, err
+		}
+
+		page := []*MediaItem{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
+
+// GetMediaHashes scans every media item with a perceptual hash. Only the keys, UserID, Hash
+// and PHash are read; detections are left out.
+func (config *DDBDriver) GetMediaHashes() ([]*MediaItem, error) {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(config.mediaTable),
+		ProjectionExpression: aws.String("TweetID, S3Key, UserID, #Hash, PHash"),
+		FilterExpression:     aws.String("attribute_exists(PHash)"),
+		ExpressionAttributeNames: map[string]string{
+			"#Hash": "Hash",
+		},
+	}
+
+	results := []*MediaItem{}
+	paginator := dynamodb.NewScanPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error scanning media")
+			return nil, err
+		}
+
+		page := []*MediaItem{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
+
+// GetMediaRefsByHash returns every reference to the content-addressed object with hash.
+func (config *DDBDriver) GetMediaRefsByHash(hash string) ([]*MediaRefItem, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(config.mediaRefsTable),
+		IndexName:              aws.String(config.mediaRefsTableGSIHash),
+		KeyConditionExpression: aws.String("#Hash = :Hash"),
+		ExpressionAttributeNames: map[string]string{
+			"#Hash": "Hash",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":Hash": &types.AttributeValueMemberS{Value: hash},
+		},
+	}
+
+	results := []*MediaRefItem{}
+	paginator := dynamodb.NewQueryPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error querying media refs")
+			return nil, err
+		}
+
+		page := []*MediaRefItem{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
+
+func (config *DDBDriver) GetMediaRetries() ([]*MediaRetryItem, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(config.mediaRetriesTable),
+	}
+
+	results := []*MediaRetryItem{}
+	paginator := dynamodb.NewScanPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error scanning media retries")
+			return nil, err
+		}
+
+		page := []*MediaRetryItem{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
+
+func (config *DDBDriver) GetRateLimits() ([]*RateLimitItem, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(config.rateLimitsTable),
+	}
+
+	results := []*RateLimitItem{}
+	paginator := dynamodb.NewScanPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error scanning rate limits")
+			return nil, err
+		}
+
+		page := []*RateLimitItem{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
+
+func (config *DDBDriver) GetRunnerUsers(runnerUsers *RunnerItem) ([]*RunnerItem, error) {
+	var input *dynamodb.QueryInput
+
+	if runnerUsers.UserID == 0 {
+		input = &dynamodb.QueryInput{
+			TableName:              aws.String(config.runnerTable),
+			KeyConditionExpression: aws.String("RunnerName = :RunnerName"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":RunnerName": &types.AttributeValueMemberS{Value: runnerUsers.RunnerName},
+			},
+		}
+	} else {
+		input = &dynamodb.QueryInput{
+			TableName:              aws.String(config.runnerTable),
+			KeyConditionExpression: aws.String("RunnerName = :RunnerName and UserID = :UserID"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":RunnerName": &types.AttributeValueMemberS{Value: runnerUsers.RunnerName},
+				":UserID":     &types.AttributeValueMemberN{Value: strconv.FormatInt(runnerUsers.UserID, 10)},
+			},
+		}
+	}
+
+	result, err := config.db.Query(context.TODO(), input)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+			"input": input,
+		}).Error("Error querying runner users")
+		return nil, err
+	}
+
+	results := []*RunnerItem{}
+	attributevalue.UnmarshalListOfMaps(result.Items, &results)
+
+	return results, nil
+}
+
+// GetRunnerUsersByUserId returns the user's entry in every runner. The runner table is keyed
+// by runner name and holds a handful of users, so this scans it.
+func (config *DDBDriver) GetRunnerUsersByUserId(userID int64) ([]*RunnerItem, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(config.runnerTable),
+		FilterExpression: aws.String("UserID = :UserID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":UserID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
+		},
+	}
+
+	results := []*RunnerItem{}
+	paginator := dynamodb.NewScanPaginator(config.db, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"input": input,
+			}).Error("Error scanning runner users")
+			return nil, err
+		}
+
+		page := []*RunnerItem{}
+		attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
+
+// GetSeenTweet returns the seen mark for tweetID, expired or not. An empty item is returned
+// when there is none.
+func (config *DDBDriver) GetSeenTweet(tweetID int64) (*SeenTweetItem, error) {
+	result, err := config.db.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(config.seenTweetsTable),
+		Key: map[string]types.AttributeValue{
+			"TweetID": &types.AttributeValueMemberN{Value: strconv.FormatInt(tweetID, 10)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	item := &SeenTweetItem{}
+	if result.Item == nil {
+		return item, nil
+	}
+	if err := attributevalue.UnmarshalMap(result.Item, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (config *DDBDriver) GetTimelineConfig(userID int64) (*TweetsItem, error) {
+	result, err := config.db.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(config.paramsTable),
+		Key: map[string]types.AttributeValue{
+			"UserID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
+			"Domain": &types.AttributeValueMemberS{Value: "tweets"},
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := &TweetsItem{}
+
+	if result.Item == nil {
+		return item, nil
+	}
+
+	err = attributevalue.UnmarshalMap(result.Item, item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (config *DDBDriver) GetTimelineBackfillConfig(userID int64) (*BackfillItem, error) {
+	result, err := config.db.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(config.paramsTable),
+		Key: map[string]types.AttributeValue{
+			"UserID": &types.AttributeValueMemberN{Value: strconv.FormatInt(userID, 10)},
+			"Domain": &types.AttributeValueMemberS{Value: "tweets_backfill"},
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := &BackfillItem{}
+
+	if result.Item == nil {
+		return item, nil
+	}
+
+	err = attributevalue.UnmarshalMap(result.Item, item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (config *DDBDriver) PutAccountStatus(query *AccountStatusQuery) error {
+	now := time.Now()
+	kvp, err := attributevalue.MarshalMap(&AccountItem{
+		Domain:      "account",
+		UserID:      query.UserID,
+		Status:      query.Status,
+		StatusSince: query.StatusSince,
+		LastUpdate:  now.UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		Item:      kvp,
+		TableName: aws.String(config.paramsTable),
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (config *DDBDriver) PutConversation(items []*ConversationItem) error {
+	now := time.Now().UnixMilli()
+	for _, item := range items {
+		item.LastUpdate = now
+		kvp, err := attributevalue.MarshalMap(item)
 		if err != nil {
 			return err
 		}
 
 		if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
-			TableName: aws.String(config.favoritesTable),
+			TableName: aws.String(config.conversationsTable),
 			Item:      kvp,
 		}); err != nil {
 			return err
@@ -528,7 +1397,23 @@ func (config *DDBDriver) PutFavorites(links []*UserToTweetLink) error {
 	return nil
 }
 
-func (config *DDBDriver) PutFollowers(links []*UserToFollowerLink) error {
+func (config *DDBDriver) PutFace(item *FaceItem) error {
+	item.LastUpdate = time.Now().UnixMilli()
+	kvp, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+
+	if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		Item:      kvp,
+		TableName: aws.String(config.facesTable),
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (config *DDBDriver) PutFavorites(links []*UserToTweetLink) error {
 	for _, link := range links {
 		kvp, err := attributevalue.MarshalMap(link)
 		if err != nil {
@@ -536,7 +1421,7 @@ func (config *DDBDriver) PutFollowers(links []*UserToFollowerLink) error {
 		}
 
 		if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
-			TableName: aws.String(config.followersTable),
+			TableName: aws.String(config.favoritesTable),
 			Item:      kvp,
 		}); err != nil {
 			return err
@@ -545,16 +1430,41 @@ func (config *DDBDriver) PutFollowers(links []*UserToFollowerLink) error {
 	return nil
 }
 
-func (config *DDBDriver) PutFriends(links []*UserToFriendLink) error {
+// PutFollowers upserts edges, setting LastSeen to now and FirstSeen only on first sight.
+func (config *DDBDriver) PutFollowers(links []*UserToFollowerLink) error {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
 	for _, link := range links {
-		kvp, err := attributevalue.MarshalMap(link)
-		if err != nil {
+		if _, err := config.db.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+			TableName: aws.String(config.followersTable),
+			Key: map[string]types.AttributeValue{
+				"UserID":     &types.AttributeValueMemberN{Value: strconv.FormatInt(link.UserID, 10)},
+				"FollowerID": &types.AttributeValueMemberN{Value: strconv.FormatInt(link.FollowerID, 10)},
+			},
+			UpdateExpression: aws.String("SET LastSeen = :now, FirstSeen = if_not_exists(FirstSeen, :now)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now": &types.AttributeValueMemberN{Value: now},
+			},
+		}); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+// PutFriends upserts edges, setting LastSeen to now and FirstSeen only on first sight.
+func (config *DDBDriver) PutFriends(links []*UserToFriendLink) error {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	for _, link := range links {
+		if _, err := config.db.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
 			TableName: aws.String(config.friendsTable),
-			Item:      kvp,
+			Key: map[string]types.AttributeValue{
+				"UserID":   &types.AttributeValueMemberN{Value: strconv.FormatInt(link.UserID, 10)},
+				"FriendID": &types.AttributeValueMemberN{Value: strconv.FormatInt(link.FriendID, 10)},
+			},
+			UpdateExpression: aws.String("SET LastSeen = :now, FirstSeen = if_not_exists(FirstSeen, :now)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now": &types.AttributeValueMemberN{Value: now},
+			},
 		}); err != nil {
 			return err
 		}
@@ -566,11 +1476,12 @@ func (config *DDBDriver) PutFavoritesConfig(query *TweetConfigQuery) error {
 	now := time.Now()
 
 	kvp, err := attributevalue.MarshalMap(&FavoritesItem{
-		Domain:     "favorites",
-		UserID:     query.UserID,
-		MaxID:      query.MaxID,
-		SinceID:    query.SinceID,
-		LastUpdate: now.UnixMilli(),
+		Domain:       "favorites",
+		UserID:       query.UserID,
+		MaxID:        query.MaxID,
+		SinceID:      query.SinceID,
+		PendingMaxID: query.PendingMaxID,
+		LastUpdate:   now.UnixMilli(),
 	})
 	if err != nil {
 		return err
@@ -592,6 +1503,9 @@ func (config *DDBDriver) PutFollowersConfig(query *CursoredTweetConfigQuery) err
 		UserID:         query.UserID,
 		PreviousCursor: query.PreviousCursor,
 		NextCursor:     query.NextCursor,
+		PassStarted:    query.PassStarted,
+		PassCompleted:  query.PassCompleted,
+		Passes:         query.Passes,
 		LastUpdate:     now.UnixMilli(),
 	})
 	if err != nil {
@@ -613,6 +1527,9 @@ func (config *DDBDriver) PutFriendsConfig(query *CursoredTweetConfigQuery) error
 		UserID:         query.UserID,
 		PreviousCursor: query.PreviousCursor,
 		NextCursor:     query.NextCursor,
+		PassStarted:    query.PassStarted,
+		PassCompleted:  query.PassCompleted,
+		Passes:         query.Passes,
 		LastUpdate:     now.UnixMilli(),
 	})
 	if err != nil {
@@ -627,6 +1544,47 @@ func (config *DDBDriver) PutFriendsConfig(query *CursoredTweetConfigQuery) error
 	}
 	return nil
 }
+func (config *DDBDriver) PutGap(query *GapQuery) error {
+	now := time.Now()
+	kvp, err := attributevalue.MarshalMap(&GapItem{
+		Domain:     gapDomain(query.Kind, query.SinceID),
+		UserID:     query.UserID,
+		Kind:       query.Kind,
+		SinceID:    query.SinceID,
+		MaxID:      query.MaxID,
+		Count:      query.Count,
+		LastUpdate: now.UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		Item:      kvp,
+		TableName: aws.String(config.paramsTable),
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (config *DDBDriver) PutHistory(items []*HistoryItem) error {
+	for _, item := range items {
+		kvp, err := attributevalue.MarshalMap(item)
+		if err != nil {
+			return err
+		}
+
+		if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+			TableName: aws.String(config.historyTable),
+			Item:      kvp,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (config *DDBDriver) PutMedia(mediaItem *MediaItem) error {
 	kvp, err := attributevalue.MarshalMap(mediaItem)
 	if err != nil {
@@ -642,6 +1600,157 @@ func (config *DDBDriver) PutMedia(mediaItem *MediaItem) error {
 	return nil
 }
 
+// PutMediaClusters records the face clusters found in media objects. S3Key is set from
+// MediaKey and ClusterID.
+func (config *DDBDriver) PutMediaClusters(items []*MediaClusterItem) error {
+	now := time.Now().UnixMilli()
+	for _, item := range items {
+		item.S3Key = MediaClusterKey(item.MediaKey, item.ClusterID)
+		item.LastUpdate = now
+		kvp, err := attributevalue.MarshalMap(item)
+		if err != nil {
+			return err
+		}
+
+		if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+			TableName: aws.String(config.mediaTable),
+			Item:      kvp,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (config *DDBDriver) PutMediaRef(item *MediaRefItem) error {
+	item.RefKey = MediaRefKey(item.UserID, item.EntityURL)
+	item.LastUpdate = time.Now().UnixMilli()
+	kvp, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+
+	if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		Item:      kvp,
+		TableName: aws.String(config.mediaRefsTable),
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// PutMediaRetry records a failed media download. Attempts is incremented on every call; the
+// caller's value is ignored.
+func (config *DDBDriver) PutMediaRetry(item *MediaRetryItem) error {
+	_, err := config.db.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(config.mediaRetriesTable),
+		Key: map[string]types.AttributeValue{
+			"EntityURL": &types.AttributeValueMemberS{Value: item.EntityURL},
+		},
+		UpdateExpression: aws.String("SET UserID = :UserID, TweetID = :TweetID, MediaType = :MediaType, #Status = :Status, LastError = :LastError, LastUpdate = :LastUpdate ADD Attempts :One"),
+		ExpressionAttributeNames: map[string]string{
+			"#Status": "Status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":UserID":     &types.AttributeValueMemberN{Value: strconv.FormatInt(item.UserID, 10)},
+			":TweetID":    &types.AttributeValueMemberS{Value: item.TweetID},
+			":MediaType":  &types.AttributeValueMemberS{Value: item.MediaType},
+			":Status":     &types.AttributeValueMemberN{Value: strconv.Itoa(item.Status)},
+			":LastError":  &types.AttributeValueMemberS{Value: item.LastError},
+			":LastUpdate": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().UnixMilli(), 10)},
+			":One":        &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	return err
+}
+
+// PutRateLimit seeds endpoint's bucket from Twitter's rate-limit headers. Concurrent callers
+// race, so a newer window always wins and within a window the lowest Remaining wins.
+func (config *DDBDriver) PutRateLimit(endpoint string, limit int, remaining int, reset time.Time) error {
+	kvp, err := attributevalue.MarshalMap(&RateLimitItem{
+		Endpoint:   endpoint,
+		Limit:      int64(limit),
+		Remaining:  int64(remaining),
+		Reset:      reset.Unix(),
+		LastUpdate: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:           aws.String(config.rateLimitsTable),
+		Item:                kvp,
+		ConditionExpression: aws.String("attribute_not_exists(Endpoint) OR #Reset < :Reset OR (#Reset = :Reset AND Remaining > :Remaining)"),
+		ExpressionAttributeNames: map[string]string{
+			"#Reset": "Reset",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":Reset":     &types.AttributeValueMemberN{Value: strconv.FormatInt(reset.Unix(), 10)},
+			":Remaining": &types.AttributeValueMemberN{Value: strconv.Itoa(remaining)},
+		},
+	})
+	var stale *types.ConditionalCheckFailedException
+	if errors.As(err, &stale) {
+		return nil
+	}
+	return err
+}
+
+// PutSeenTweet marks tweetID as seen until now+ttl. ok is false when it was already seen and
+// the mark hasn't expired. DynamoDB deletes expired items lazily, so the condition checks
+// Expires as well as existence.
+func (config *DDBDriver) PutSeenTweet(tweetID int64, ttl time.Duration) (ok bool, err error) {
+	now := time.Now()
+	kvp, err := attributevalue.MarshalMap(&SeenTweetItem{
+		TweetID:    tweetID,
+		Expires:    now.Add(ttl).Unix(),
+		LastUpdate: now.UnixMilli(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	_, err = config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:           aws.String(config.seenTweetsTable),
+		Item:                kvp,
+		ConditionExpression: aws.String("attribute_not_exists(TweetID) OR Expires < :Now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":Now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+	var seen *types.ConditionalCheckFailedException
+	if errors.As(err, &seen) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PutHydratedUsers marks userIDs as hydrated until now+ttl.
+func (config *DDBDriver) PutHydratedUsers(userIDs []int64, ttl time.Duration) error {
+	now := time.Now()
+	for _, userID := range userIDs {
+		kvp, err := attributevalue.MarshalMap(&HydratedUserItem{
+			UserID:     userID,
+			Expires:    now.Add(ttl).Unix(),
+			LastUpdate: now.UnixMilli(),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+			TableName: aws.String(config.hydratedUsersTable),
+			Item:      kvp,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (config *DDBDriver) PutTimelineConfig(query *TweetConfigQuery) error {
 	now := time.Now()
 	kvp, err := attributevalue.MarshalMap(&TweetsItem{
@@ -664,12 +1773,36 @@ func (config *DDBDriver) PutTimelineConfig(query *TweetConfigQuery) error {
 	return nil
 }
 
+func (config *DDBDriver) PutTimelineBackfillConfig(query *BackfillConfigQuery) error {
+	now := time.Now()
+	kvp, err := attributevalue.MarshalMap(&BackfillItem{
+		Domain:     "tweets_backfill",
+		UserID:     query.UserID,
+		NextMaxID:  query.NextMaxID,
+		Count:      query.Count,
+		Complete:   query.Complete,
+		LastUpdate: now.UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := config.db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		Item:      kvp,
+		TableName: aws.String(config.paramsTable),
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (config *DDBDriver) PutRunnerFlags(params *RunnerItem) error {
 	now := time.Now()
 	kvp, err := attributevalue.MarshalMap(&RunnerItem{
 		RunnerName: params.RunnerName,
 		UserID:     params.UserID,
 		Flags:      params.Flags,
+		Paused:     params.Paused,
 		LastUpdate: now.UnixMilli(),
 	})
 	if err != nil {
@@ -698,6 +1831,88 @@ type TableExportRequest struct {
 	exportFormat types.ExportFormat
 }
 
+// SpendBudget adds calls to the amount spent from budget on day, unless that would take it
+// over limit. ok is false when the budget can't cover calls.
+func (config *DDBDriver) SpendBudget(budget string, day string, calls int64, limit int64) (ok bool, err error) {
+	if calls > limit {
+		return false, nil
+	}
+	_, err = config.db.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(config.budgetsTable),
+		Key: map[string]types.AttributeValue{
+			"Budget": &types.AttributeValueMemberS{Value: budget},
+			"Day":    &types.AttributeValueMemberS{Value: day},
+		},
+		UpdateExpression:    aws.String("SET #Limit = :Limit, LastUpdate = :Now ADD Spent :Calls"),
+		ConditionExpression: aws.String("attribute_not_exists(Spent) OR Spent <= :Max"),
+		ExpressionAttributeNames: map[string]string{
+			"#Limit": "Limit",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":Limit": &types.AttributeValueMemberN{Value: strconv.FormatInt(limit, 10)},
+			":Now":   &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().UnixMilli(), 10)},
+			":Calls": &types.AttributeValueMemberN{Value: strconv.FormatInt(calls, 10)},
+			":Max":   &types.AttributeValueMemberN{Value: strconv.FormatInt(limit-calls, 10)},
+		},
+	})
+	var spent *types.ConditionalCheckFailedException
+	if errors.As(err, &spent) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// TakeRateLimit takes one call from endpoint's shared bucket. When the bucket is spent, ok is
+// false and reset is when the window ends. An unknown or expired window lets the call through;
+// its rate-limit headers reseed the bucket.
+func (config *DDBDriver) TakeRateLimit(endpoint string, now time.Time) (reset time.Time, ok bool, err error) {
+	_, err = config.db.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(config.rateLimitsTable),
+		Key: map[string]types.AttributeValue{
+			"Endpoint": &types.AttributeValueMemberS{Value: endpoint},
+		},
+		UpdateExpression:    aws.String("SET Remaining = Remaining - :One"),
+		ConditionExpression: aws.String("Remaining > :Zero AND #Reset > :Now"),
+		ExpressionAttributeNames: map[string]string{
+			"#Reset": "Reset",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":One":  &types.AttributeValueMemberN{Value: "1"},
+			":Zero": &types.AttributeValueMemberN{Value: "0"},
+			":Now":  &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+	if err == nil {
+		return time.Time{}, true, nil
+	}
+	var spent *types.ConditionalCheckFailedException
+	if !errors.As(err, &spent) {
+		return time.Time{}, false, err
+	}
+
+	// The condition also fails for missing and expired buckets; only a live, empty one blocks.
+	result, err := config.db.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(config.rateLimitsTable),
+		Key: map[string]types.AttributeValue{
+			"Endpoint": &types.AttributeValueMemberS{Value: endpoint},
+		},
+	})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	item := &RateLimitItem{}
+	if err := attributevalue.UnmarshalMap(result.Item, item); err != nil {
+		return time.Time{}, false, err
+	}
+	if item.Reset <= now.Unix() {
+		return time.Time{}, true, nil
+	}
+	return time.Unix(item.Reset, 0), false, nil
+}
+
 func (config *DDBDriver) ExportTable(params *TableExportRequest) (*dynamodb.ExportTableToPointInTimeOutput, error) {
 	switch strings.ToUpper(params.ExportFormat) {
 	case "DYNAMODB_JSON":
@@ -0,0 +1,520 @@
+// Package faketwitter is an in-process stand-in for the Twitter v1.1 API.
+// It serves canned users, tweets, cursored follower/friend lists and IDs, and rate-limit
+// headers (including 429s) so service.Config can be pointed at it with service.SetBaseURL.
+package faketwitter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/sirupsen/logrus"
+)
+
+// Endpoint names, as used with SetRateLimit.
+const (
+	EndpointFavoritesList  = "favorites/list"
+	EndpointFollowersIDs   = "followers/ids"
+	EndpointFollowersList  = "followers/list"
+	EndpointFriendsIDs     = "friends/ids"
+	EndpointFriendsList    = "friends/list"
+	EndpointStatusesLookup = "statuses/lookup"
+	EndpointUserTimeline   = "statuses/user_timeline"
+	EndpointUsersLookup    = "users/lookup"
+	EndpointUsersShow      = "users/show"
+)
+
+// Twitter API error codes returned by the fake.
+const (
+	errorCodePageNotFound    = 34
+	errorCodeUserNotFound    = 50
+	errorCodeNoUserMatches   = 17
+	errorCodeRateLimit       = 88
+	errorCodeBadAuthRequired = 215
+)
+
+const (
+	defaultRateLimit       = 900
+	defaultRateLimitWindow = 15 * time.Minute
+	twitterTimeLayout      = "Mon Jan 2 15:04:05 -0700 2006"
+)
+
+// Fixtures is the JSON document accepted by Load.
+type Fixtures struct {
+	Users     []twitter.User     `json:"users"`
+	Tweets    []twitter.Tweet    `json:"tweets"`
+	Favorites map[string][]int64 `json:"favorites"`
+	Followers map[string][]int64 `json:"followers"`
+	Friends   map[string][]int64 `json:"friends"`
+}
+
+type rateLimit struct {
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+type Option func(config *Server)
+
+// Server holds the canned data and serves it over HTTP.
+type Server struct {
+	mu        sync.Mutex
+	log       *logrus.Logger
+	users     map[int64]*twitter.User
+	tweets    map[int64]*twitter.Tweet
+	favorites map[int64][]int64
+	followers map[int64][]int64
+	friends   map[int64][]int64
+	limits    map[string]*rateLimit
+	server    *httptest.Server
+}
+
+func New(opts ...func(*Server)) *Server {
+	config := &Server{
+		users:     make(map[int64]*twitter.User),
+		tweets:    make(map[int64]*twitter.Tweet),
+		favorites: make(map[int64][]int64),
+		followers: make(map[int64][]int64),
+		friends:   make(map[int64][]int64),
+		limits:    make(map[string]*rateLimit),
+	}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.log == nil {
+		config.log = logrus.New()
+		config.log.SetOutput(io.Discard)
+	}
+	return config
+}
+
+func SetLogger(log *logrus.Logger) Option {
+	return func(config *Server) {
+		config.log = log
+	}
+}
+
+// Start serves the fake API on a random local port; use URL() as the base URL.
+func (config *Server) Start() {
+	config.server = httptest.NewServer(config)
+}
+
+// URL returns the base URL of a started server.
+func (config *Server) URL() string {
+	return config.server.URL
+}
+
+func (config *Server) Close() {
+	if config.server != nil {
+		config.server.Close()
+	}
+}
+
+// Load adds the users, tweets and relationships from a fixtures document.
+func (config *Server) Load(r io.Reader) error {
+	fixtures := &Fixtures{}
+	if err := json.NewDecoder(r).Decode(fixtures); err != nil {
+		return err
+	}
+	for _, user := range fixtures.Users {
+		config.AddUser(user)
+	}
+	for _, tweet := range fixtures.Tweets {
+		config.AddTweet(tweet)
+	}
+	for userID, tweetIDs := range fixtures.Favorites {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return err
+		}
+		for _, tweetID := range tweetIDs {
+			config.AddFavorite(id, tweetID)
+		}
+	}
+	for userID, followerIDs := range fixtures.Followers {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return err
+		}
+		for _, followerID := range followerIDs {
+			config.AddFollower(id, followerID)
+		}
+	}
+	for userID, friendIDs := range fixtures.Friends {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return err
+		}
+		for _, friendID := range friendIDs {
+			config.AddFriend(id, friendID)
+		}
+	}
+	return nil
+}
+
+func (config *Server) AddUser(user twitter.User) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	if user.IDStr == "" {
+		user.IDStr = strconv.FormatInt(user.ID, 10)
+	}
+	if user.CreatedAt == "" {
+		user.CreatedAt = time.Now().Format(twitterTimeLayout)
+	}
+	config.users[user.ID] = &user
+}
+
+// AddTweet stores a tweet; it appears in its author's timeline.
+func (config *Server) AddTweet(tweet twitter.Tweet) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	if tweet.IDStr == "" {
+		tweet.IDStr = strconv.FormatInt(tweet.ID, 10)
+	}
+	if tweet.CreatedAt == "" {
+		tweet.CreatedAt = time.Now().Format(twitterTimeLayout)
+	}
+	if tweet.User == nil {
+		tweet.User = &twitter.User{}
+	}
+	// Twitter always returns an entities object, even when it is empty.
+	if tweet.Entities == nil {
+		tweet.Entities = &twitter.Entities{}
+	}
+	config.tweets[tweet.ID] = &tweet
+}
+
+func (config *Server) AddFavorite(userID int64, tweetID int64) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.favorites[userID] = append(config.favorites[userID], tweetID)
+}
+
+func (config *Server) AddFollower(userID int64, followerID int64) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.followers[userID] = append(config.followers[userID], followerID)
+}
+
+func (config *Server) AddFriend(userID int64, friendID int64) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.friends[userID] = append(config.friends[userID], friendID)
+}
+
+// SetRateLimit sets the remaining calls for endpoint until reset. remaining 0 makes
+// every call return 429 until reset passes, after which the default limit applies again.
+func (config *Server) SetRateLimit(endpoint string, remaining int, reset time.Time) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.limits[endpoint] = &rateLimit{
+		limit:     defaultRateLimit,
+		remaining: remaining,
+		reset:     reset,
+	}
+}
+
+func (config *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	config.log.WithFields(logrus.Fields{
+		"action": "faketwitter::ServeHTTP",
+		"method": r.Method,
+		"url":    r.URL.String(),
+	}).Debug("request")
+
+	if r.URL.Path == "/oauth2/token" {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"token_type":   "bearer",
+			"access_token": "faketwitter",
+		})
+		return
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeError(w, http.StatusBadRequest, errorCodeBadAuthRequired, "Bad Authentication data.")
+		return
+	}
+
+	endpoint := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/1.1/"), ".json")
+
+	config.mu.Lock()
+	defer config.mu.Unlock()
+
+	if !config.take(w, endpoint) {
+		writeError(w, http.StatusTooManyRequests, errorCodeRateLimit, "Rate limit exceeded")
+		return
+	}
+
+	query := r.URL.Query()
+	switch endpoint {
+	case EndpointUsersShow:
+		user := config.findUser(query.Get("user_id"), query.Get("screen_name"))
+		if user == nil {
+			writeError(w, http.StatusNotFound, errorCodeUserNotFound, "User not found.")
+			return
+		}
+		writeJSON(w, http.StatusOK, user)
+
+	case EndpointUsersLookup:
+		users := []*twitter.User{}
+		for _, id := range splitList(query.Get("user_id")) {
+			if user := config.findUser(id, ""); user != nil {
+				users = append(users, user)
+			}
+		}
+		for _, name := range splitList(query.Get("screen_name")) {
+			if user := config.findUser("", name); user != nil {
+				users = append(users, user)
+			}
+		}
+		if len(users) == 0 {
+			writeError(w, http.StatusNotFound, errorCodeNoUserMatches, "No user matches for specified terms.")
+			return
+		}
+		writeJSON(w, http.StatusOK, users)
+
+	case EndpointStatusesLookup:
+		tweets := []*twitter.Tweet{}
+		for _, id := range splitList(query.Get("id")) {
+			tweetID, _ := strconv.ParseInt(id, 10, 64)
+			if tweet, ok := config.tweets[tweetID]; ok {
+				tweets = append(tweets, config.hydrateTweet(tweet))
+			}
+		}
+		writeJSON(w, http.StatusOK, tweets)
+
+	case EndpointUserTimeline:
+		user := config.findUser(query.Get("user_id"), query.Get("screen_name"))
+		if user == nil {
+			writeError(w, http.StatusNotFound, errorCodeUserNotFound, "User not found.")
+			return
+		}
+		ids := []int64{}
+		for id, tweet := range config.tweets {
+			if tweet.User != nil && tweet.User.ID == user.ID {
+				ids = append(ids, id)
+			}
+		}
+		writeJSON(w, http.StatusOK, config.page(ids, query))
+
+	case EndpointFavoritesList:
+		user := config.findUser(query.Get("user_id"), query.Get("screen_name"))
+		if user == nil {
+			writeError(w, http.StatusNotFound, errorCodeUserNotFound, "User not found.")
+			return
+		}
+		writeJSON(w, http.StatusOK, config.page(config.favorites[user.ID], query))
+
+	case EndpointFollowersList, EndpointFriendsList:
+		user := config.findUser(query.Get("user_id"), query.Get("screen_name"))
+		if user == nil {
+			writeError(w, http.StatusNotFound, errorCodeUserNotFound, "User not found.")
+			return
+		}
+		ids := config.followers[user.ID]
+		if endpoint == EndpointFriendsList {
+			ids = config.friends[user.ID]
+		}
+		page, next, previous := cursorPage(ids, query, 20, 200)
+		users := make([]*twitter.User, len(page))
+		for i, id := range page {
+			users[i] = config.userOrStub(id)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"users":               users,
+			"next_cursor":         next,
+			"next_cursor_str":     strconv.FormatInt(next, 10),
+			"previous_cursor":     previous,
+			"previous_cursor_str": strconv.FormatInt(previous, 10),
+		})
+
+	case EndpointFollowersIDs, EndpointFriendsIDs:
+		user := config.findUser(query.Get("user_id"), query.Get("screen_name"))
+		if user == nil {
+			writeError(w, http.StatusNotFound, errorCodeUserNotFound, "User not found.")
+			return
+		}
+		ids := config.followers[user.ID]
+		if endpoint == EndpointFriendsIDs {
+			ids = config.friends[user.ID]
+		}
+		page, next, previous := cursorPage(ids, query, 5000, 5000)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"ids":                 page,
+			"next_cursor":         next,
+			"next_cursor_str":     strconv.FormatInt(next, 10),
+			"previous_cursor":     previous,
+			"previous_cursor_str": strconv.FormatInt(previous, 10),
+		})
+
+	default:
+		writeError(w, http.StatusNotFound, errorCodePageNotFound, "Sorry, that page does not exist.")
+	}
+}
+
+// take consumes one call from endpoint's window, writing the rate-limit headers. It reports false when exhausted.
+func (config *Server) take(w http.ResponseWriter, endpoint string) bool {
+	now := time.Now()
+	limit, ok := config.limits[endpoint]
+	if !ok || !now.Before(limit.reset) {
+		limit = &rateLimit{
+			limit:     defaultRateLimit,
+			remaining: defaultRateLimit,
+			reset:     now.Add(defaultRateLimitWindow),
+		}
+		config.limits[endpoint] = limit
+	}
+
+	allowed := limit.remaining > 0
+	if allowed {
+		limit.remaining--
+	}
+
+	w.Header().Set("x-rate-limit-limit", strconv.Itoa(limit.limit))
+	w.Header().Set("x-rate-limit-remaining", strconv.Itoa(limit.remaining))
+	w.Header().Set("x-rate-limit-reset", strconv.FormatInt(limit.reset.Unix(), 10))
+	return allowed
+}
+
+func (config *Server) findUser(userID string, screenName string) *twitter.User {
+	if userID != "" {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return nil
+		}
+		return config.users[id]
+	}
+	for _, user := range config.users {
+		if screenName != "" && strings.EqualFold(user.ScreenName, screenName) {
+			return user
+		}
+	}
+	return nil
+}
+
+func (config *Server) userOrStub(id int64) *twitter.User {
+	if user, ok := config.users[id]; ok {
+		return user
+	}
+	return &twitter.User{
+		ID:        id,
+		IDStr:     strconv.FormatInt(id, 10),
+		CreatedAt: time.Now().Format(twitterTimeLayout),
+	}
+}
+
+// hydrateTweet returns a copy of tweet with the full author attached.
+func (config *Server) hydrateTweet(tweet *twitter.Tweet) *twitter.Tweet {
+	t := *tweet
+	if t.User != nil {
+		t.User = config.userOrStub(t.User.ID)
+	}
+	return &t
+}
+
+// page returns the tweets in ids that match since_id/max_id, newest first, limited by count.
+func (config *Server) page(ids []int64, query map[string][]string) []*twitter.Tweet {
+	sinceID, _ := strconv.ParseInt(first(query["since_id"]), 10, 64)
+	maxID, _ := strconv.ParseInt(first(query["max_id"]), 10, 64)
+	count, _ := strconv.Atoi(first(query["count"]))
+	if count <= 0 {
+		count = 20
+	}
+	if count > 200 {
+		count = 200
+	}
+
+	sorted := append([]int64{}, ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+
+	tweets := []*twitter.Tweet{}
+	for _, id := range sorted {
+		if sinceID != 0 && id <= sinceID {
+			continue
+		}
+		if maxID != 0 && id > maxID {
+			continue
+		}
+		tweet, ok := config.tweets[id]
+		if !ok {
+			continue
+		}
+		tweets = append(tweets, config.hydrateTweet(tweet))
+		if len(tweets) == count {
+			break
+		}
+	}
+	return tweets
+}
+
+// cursorPage slices ids for a cursored endpoint. Cursors are 1-based offsets; -1 (or 0) is the first page
+// and a next cursor of 0 means the list is exhausted.
+func cursorPage(ids []int64, query map[string][]string, defaultCount int, maxCount int) ([]int64, int64, int64) {
+	cursor, _ := strconv.ParseInt(first(query["cursor"]), 10, 64)
+	count, _ := strconv.Atoi(first(query["count"]))
+	if count <= 0 {
+		count = defaultCount
+	}
+	if count > maxCount {
+		count = maxCount
+	}
+
+	start := 0
+	if cursor > 0 {
+		start = int(cursor - 1)
+	}
+	if start > len(ids) {
+		start = len(ids)
+	}
+	end := start + count
+	if end > len(ids) {
+		end = len(ids)
+	}
+
+	var next, previous int64
+	if end < len(ids) {
+		next = int64(end + 1)
+	}
+	if start > 0 {
+		previous = int64(start - count + 1)
+		if previous < 1 {
+			previous = 1
+		}
+	}
+	return ids[start:end], next, previous
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code int, message string) {
+	writeJSON(w, status, twitter.APIError{
+		Errors: []twitter.ErrorDetail{{Code: code, Message: message}},
+	})
+}
@@ -0,0 +1,282 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultTimeout is how long an attempt may wait for the response headers, or for more of
+	// the body, before it fails. Large objects keep downloading as long as data arrives.
+	DefaultTimeout = 15 * time.Second
+
+	// DefaultRetries is how many times a transient failure is retried after the first attempt.
+	DefaultRetries = 2
+
+	// DefaultBackoff is the wait before the first retry; it doubles on each one after.
+	DefaultBackoff = time.Second
+
+	// DefaultMaxSize is the largest object fetched. Twitter caps uploaded videos at 512 MB.
+	// The processor Lambda's timeout and ephemeral storage are sized to spool and upload one.
+	DefaultMaxSize = 512 << 20
+)
+
+// ErrTooLarge is returned, wrapped in an Error, when an object exceeds the maximum size.
+var ErrTooLarge = errors.New("object exceeds maximum size")
+
+type Option func(config *Config)
+
+// Config fetches media objects over HTTP with timeouts, retries and validation.
+type Config struct {
+	log     *logrus.Logger
+	client  *http.Client
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+	maxSize int64
+}
+
+func New(opts ...func(*Config)) *Config {
+	config := &Config{
+		timeout: DefaultTimeout,
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+		maxSize: DefaultMaxSize,
+	}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.log == nil {
+		config.log = logrus.New()
+	}
+	if config.client == nil {
+		config.client = &http.Client{}
+	}
+
+	return config
+}
+
+func SetLogger(log *logrus.Logger) Option {
+	return func(config *Config) {
+		config.log = log
+	}
+}
+
+// SetClient replaces the HTTP client. Leave its Timeout unset: it bounds the whole body, so it
+// would cut off large objects that are still downloading.
+func SetClient(client *http.Client) Option {
+	return func(config *Config) {
+		config.client = client
+	}
+}
+
+// SetTimeout sets how long an attempt may wait for the response headers or for more of the
+// body.
+func SetTimeout(timeout time.Duration) Option {
+	return func(config *Config) {
+		config.timeout = timeout
+	}
+}
+
+func SetRetries(retries int) Option {
+	return func(config *Config) {
+		config.retries = retries
+	}
+}
+
+func SetBackoff(backoff time.Duration) Option {
+	return func(config *Config) {
+		config.backoff = backoff
+	}
+}
+
+func SetMaxSize(maxSize int64) Option {
+	return func(config *Config) {
+		config.maxSize = maxSize
+	}
+}
+
+// Error is a failed fetch. Status is the HTTP status, or 0 when no response was received.
+type Error struct {
+	URL    string
+	Status int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("fetch %s: status %d: %v", e.URL, e.Status, e.Err)
+	}
+	return fmt.Sprintf("fetch %s: %v", e.URL, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether fetching again later might succeed: transport errors, rate
+// limits and server errors. Missing objects, bad content and oversized objects won't change.
+func (e *Error) Temporary() bool {
+	if errors.Is(e.Err, ErrTooLarge) {
+		return false
+	}
+	return e.Status == 0 || e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+// Object is a fetched media object. The caller must close Body. Reading Body past the
+// maximum size returns ErrTooLarge.
+type Object struct {
+	URL         string
+	ContentType string
+	Size        int64
+	Body        io.ReadCloser
+}
+
+// OriginalURL asks pbs.twimg.com for the original upload of a photo instead of the
+// resized default. Other URLs are returned unchanged.
+func OriginalURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host != "pbs.twimg.com" || !strings.HasPrefix(u.Path, "/media/") {
+		return rawURL
+	}
+	query := u.Query()
+	if query.Get("name") != "" {
+		return rawURL
+	}
+	query.Set("name", "orig")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Get fetches rawURL, retrying transient failures with exponential backoff. Only image and
+// video responses are accepted. Errors are returned as *Error.
+func (config *Config) Get(rawURL string) (*Object, error) {
+	backoff := config.backoff
+	for attempt := 0; ; attempt++ {
+		object, err := config.get(rawURL)
+		if err == nil {
+			return object, nil
+		}
+
+		var fetchErr *Error
+		if !errors.As(err, &fetchErr) || !fetchErr.Temporary() || attempt >= config.retries {
+			return nil, err
+		}
+		config.log.WithFields(logrus.Fields{
+			"action":  "fetch::Get",
+			"error":   err.Error(),
+			"url":     rawURL,
+			"attempt": attempt + 1,
+			"backoff": backoff.String(),
+		}).Warn("fetch failed; retrying")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// get makes a single attempt. The request is cancelled once it goes the timeout without
+// receiving headers or body data.
+func (config *Config) get(rawURL string) (*Object, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	idle := time.AfterFunc(config.timeout, cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		idle.Stop()
+		cancel()
+		return nil, &Error{URL: rawURL, Err: err}
+	}
+	resp, err := config.client.Do(req)
+	if err != nil {
+		idle.Stop()
+		cancel()
+		return nil, &Error{URL: rawURL, Err: err}
+	}
+	resp.Body = &idleBody{ReadCloser: resp.Body, timer: idle, timeout: config.timeout, cancel: cancel}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &Error{URL: rawURL, Status: resp.StatusCode, Err: errors.New(http.StatusText(resp.StatusCode))}
+	}
+
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !(strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "video/")) {
+		resp.Body.Close()
+		return nil, &Error{URL: rawURL, Status: resp.StatusCode, Err: fmt.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))}
+	}
+
+	if resp.ContentLength > config.maxSize {
+		resp.Body.Close()
+		return nil, &Error{URL: rawURL, Status: resp.StatusCode, Err: ErrTooLarge}
+	}
+
+	return &Object{
+		URL:         rawURL,
+		ContentType: contentType,
+		Size:        resp.ContentLength,
+		Body: &limitedBody{
+			ReadCloser: resp.Body,
+			remaining:  config.maxSize,
+			err:        &Error{URL: rawURL, Status: resp.StatusCode, Err: ErrTooLarge},
+		},
+	}, nil
+}
+
+// idleBody resets the attempt's idle timer whenever data arrives, and releases the request
+// when closed.
+type idleBody struct {
+	io.ReadCloser
+	timer   *time.Timer
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+func (body *idleBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if n > 0 {
+		body.timer.Reset(body.timeout)
+	}
+	return n, err
+}
+
+func (body *idleBody) Close() error {
+	body.timer.Stop()
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+// limitedBody fails with err once more than remaining bytes have been read. Content-Length
+// can be missing or wrong, so the limit is enforced while streaming as well.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	if body.remaining < 0 {
+		return 0, body.err
+	}
+	// Read one byte past the limit so an object of exactly maxSize still succeeds.
+	if int64(len(p)) > body.remaining+1 {
+		p = p[:body.remaining+1]
+	}
+	n, err := body.ReadCloser.Read(p)
+	body.remaining -= int64(n)
+	if body.remaining < 0 {
+		return n, body.err
+	}
+	return n, err
+}
@@ -0,0 +1,79 @@
+package fetch
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// trickle serves an image in chunks, pausing between them.
+func trickle(chunks int, pause time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		for i := 0; i < chunks; i++ {
+			w.Write([]byte("chunk"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(pause):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+func newTestFetcher(timeout time.Duration) *Config {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return New(SetLogger(log), SetTimeout(timeout), SetRetries(0))
+}
+
+func TestGetOutlastsTimeoutWhileDataArrives(t *testing.T) {
+	server := httptest.NewServer(trickle(6, 50*time.Millisecond))
+	defer server.Close()
+
+	object, err := newTestFetcher(150 * time.Millisecond).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer object.Body.Close()
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	if len(data) != 6*len("chunk") {
+		t.Errorf("read %d bytes, want %d", len(data), 6*len("chunk"))
+	}
+}
+
+func TestGetFailsWhenBodyStalls(t *testing.T) {
+	server := httptest.NewServer(trickle(2, time.Second))
+	defer server.Close()
+
+	object, err := newTestFetcher(100 * time.Millisecond).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer object.Body.Close()
+	if _, err := io.ReadAll(object.Body); err == nil {
+		t.Fatal("reading a stalled body succeeded, want an error")
+	}
+}
+
+func TestGetFailsWithoutHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	_, err := newTestFetcher(100 * time.Millisecond).Get(server.URL)
+	var fetchErr *Error
+	if err == nil || !errors.As(err, &fetchErr) || !fetchErr.Temporary() {
+		t.Fatalf("Get error = %v, want a temporary *Error", err)
+	}
+}
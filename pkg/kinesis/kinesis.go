@@ -10,6 +10,14 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// Sink accepts tweet records destined for the delivery stream.
+type Sink interface {
+	PutRecord(data []byte) (*firehose.PutRecordOutput, error)
+}
+
+var _ Sink = (*Config)(nil)
+var _ Sink = (*Local)(nil)
+
 type Option func(config *Config)
 
 // Configuration structure.
@@ -0,0 +1,81 @@
+package kinesis
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/sirupsen/logrus"
+)
+
+type LocalOption func(config *Local)
+
+// Local appends records to a newline-delimited JSON file in place of the Firehose delivery stream.
+type Local struct {
+	log     *logrus.Logger
+	path    string
+	mu      sync.Mutex
+	records int64
+}
+
+func NewLocal(opts ...func(*Local)) *Local {
+	config := &Local{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+func SetLocalPath(path string) LocalOption {
+	return func(config *Local) {
+		config.path = path
+	}
+}
+
+func SetLocalLogger(log *logrus.Logger) LocalOption {
+	return func(config *Local) {
+		config.log = log
+	}
+}
+
+// PutRecord appends data, followed by a newline, to the sink file.
+func (config *Local) PutRecord(data []byte) (*firehose.PutRecordOutput, error) {
+	if config.path == "" {
+		return nil, errors.New("local sink path is required")
+	}
+
+	config.mu.Lock()
+	defer config.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(config.path), 0o755); err != nil {
+		return nil, err
+	}
+	fp, err := os.OpenFile(config.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	if _, err := fp.Write(append(data, '\n')); err != nil {
+		return nil, err
+	}
+
+	config.records++
+	return &firehose.PutRecordOutput{
+		RecordId:  aws.String(strconv.FormatInt(config.records, 10)),
+		Encrypted: aws.Bool(false),
+	}, nil
+}
+
+// Records returns the number of records written by this sink.
+func (config *Local) Records() int64 {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	return config.records
+}
@@ -0,0 +1,491 @@
+package media
+
+import (
+	"crypto/sha256"
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rmrfslashbin/tndx/pkg/analyzer"
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/phash"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type Option func(config *Config)
+
+// Config holds the drivers used to index stored media.
+type Config struct {
+	log        *logrus.Logger
+	db         database.Database
+	analyzer   analyzer.Analyzer
+	storage    storage.Storage
+	quarantine *QuarantinePolicy
+}
+
+func New(opts ...func(*Config)) *Config {
+	config := &Config{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.log == nil {
+		config.log = logrus.New()
+	}
+
+	return config
+}
+
+func SetLogger(log *logrus.Logger) Option {
+	return func(config *Config) {
+		config.log = log
+	}
+}
+
+func SetDatabase(db database.Database) Option {
+	return func(config *Config) {
+		config.db = db
+	}
+}
+
+// SetAnalyzer sets the media analyzer. Without one, media items are stored without detections.
+func SetAnalyzer(analyzer analyzer.Analyzer) Option {
+	return func(config *Config) {
+		config.analyzer = analyzer
+	}
+}
+
+// SetStorage sets where stored objects are read back from to compute perceptual hashes.
+// Without one, media items are stored without a PHash.
+func SetStorage(storage storage.Storage) Option {
+	return func(config *Config) {
+		config.storage = storage
+	}
+}
+
+// ParseKey returns the user and tweet IDs from a media/<user>/<tweet>/<file> key.
+func ParseKey(key string) (userID int64, tweetID int64, err error) {
+	parts := strings.Split(key, "/")
+	if len(parts) < 4 {
+		return 0, 0, errors.New("invalid media key: " + key)
+	}
+	if userID, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	if tweetID, err = strconv.ParseInt(parts[2], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	return userID, tweetID, nil
+}
+
+// ParseContentKey returns the SHA-256 from a media/sha256/<xx>/<hash><ext> key. ok is false
+// for any other key.
+func ParseContentKey(key string) (hash string, ok bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[0] != "media" || parts[1] != "sha256" {
+		return "", false
+	}
+	hash = strings.TrimSuffix(parts[3], path.Ext(parts[3]))
+	if len(hash) != sha256.Size*2 || !strings.HasPrefix(hash, parts[2]) {
+		return "", false
+	}
+	return hash, true
+}
+
+// analyze runs the analyzer over a stored object. Without one, the detection is empty. The
+// perceptual hash is filled in here when the analyzer doesn't compute one. deferred is true,
+// with an empty detection, when the analyzer's budget is spent.
+func (config *Config) analyze(bucket string, key string) (output *analyzer.Detection, deferred bool, err error) {
+	output = &analyzer.Detection{}
+	if config.analyzer != nil {
+		if output, err = config.analyzer.Analyze(bucket, key); errors.Is(err, analyzer.ErrDeferred) {
+			output, deferred = &analyzer.Detection{}, true
+		} else if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error":    err,
+				"analyzer": config.analyzer.GetAnalyzerName(),
+			}).Error("error processing media")
+			return nil, false, err
+		}
+	}
+	if output.PHash == "" {
+		output.PHash = config.perceptualHash(key)
+	}
+	return output, deferred, nil
+}
+
+// mediaItem builds the media table item for a detection.
+func mediaItem(bucket string, key string, output *analyzer.Detection) *database.MediaItem {
+	return &database.MediaItem{
+		Bucket:           bucket,
+		S3Key:            key,
+		PHash:            output.PHash,
+		Width:            output.Width,
+		Height:           output.Height,
+		Format:           output.Format,
+		Colors:           output.Colors,
+		Faces:            output.Faces,
+		Labels:           output.Labels,
+		Moderation:       output.Moderation,
+		Text:             output.Text,
+		Celebrities:      output.Celebrities,
+		FacesCount:       len(output.Faces),
+		LabelsCount:      len(output.Labels),
+		ModerationCount:  len(output.Moderation),
+		TextCount:        len(output.Text),
+		CelebritiesCount: len(output.Celebrities),
+	}
+}
+
+// detection recovers the detection stored on a media item.
+func detection(item *database.MediaItem) *analyzer.Detection {
+	return &analyzer.Detection{
+		Faces:       item.Faces,
+		Labels:      item.Labels,
+		Moderation:  item.Moderation,
+		Text:        item.Text,
+		Celebrities: item.Celebrities,
+		Width:       item.Width,
+		Height:      item.Height,
+		Format:      item.Format,
+		Colors:      item.Colors,
+		PHash:       item.PHash,
+	}
+}
+
+// clusterFaces assigns every face indexed from key to a cluster: the cluster of its most
+// similar earlier face that has one, or else a new cluster named after the face itself. The
+// distinct clusters found in the object are returned.
+func (config *Config) clusterFaces(key string, matches []analyzer.FaceMatch) ([]string, error) {
+	clusters := []string{}
+	seen := map[string]bool{}
+	for _, match := range matches {
+		clusterID := ""
+		for _, faceID := range match.Matches {
+			face, err := config.db.GetFace(faceID)
+			if err != nil {
+				config.log.WithFields(logrus.Fields{
+					"error":  err,
+					"faceId": faceID,
+				}).Error("error getting face")
+				return nil, err
+			}
+			if face.ClusterID != "" {
+				clusterID = face.ClusterID
+				break
+			}
+		}
+		if clusterID == "" {
+			clusterID = match.FaceID
+		}
+
+		if err := config.db.PutFace(&database.FaceItem{
+			FaceID:    match.FaceID,
+			ClusterID: clusterID,
+			S3Key:     key,
+		}); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error":  err,
+				"faceId": match.FaceID,
+			}).Error("error saving face")
+			return nil, err
+		}
+		if !seen[clusterID] {
+			seen[clusterID] = true
+			clusters = append(clusters, clusterID)
+		}
+	}
+	return clusters, nil
+}
+
+// putMedia saves a media item and a cluster item for each face cluster found in it.
+func (config *Config) putMedia(item *database.MediaItem) error {
+	if err := config.db.PutMedia(item); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("error saving media item")
+		return err
+	}
+	if len(item.FaceClusters) == 0 {
+		return nil
+	}
+
+	clusters := []*database.MediaClusterItem{}
+	for _, clusterID := range item.FaceClusters {
+		clusters = append(clusters, &database.MediaClusterItem{
+			TweetID:   item.TweetID,
+			ClusterID: clusterID,
+			MediaKey:  item.S3Key,
+		})
+	}
+	if err := config.db.PutMediaClusters(clusters); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("error saving media clusters")
+		return err
+	}
+	return nil
+}
+
+// perceptualHash returns the dHash of an image object, or "" for videos and images that
+// can't be decoded. Failures are logged; they never fail the analysis.
+func (config *Config) perceptualHash(key string) string {
+	if config.storage == nil || !phash.Supported(key) {
+		return ""
+	}
+	body, err := config.storage.Get(key)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+			"key":   key,
+		}).Warn("error reading media for perceptual hash")
+		return ""
+	}
+	defer body.Close()
+
+	hash, err := phash.Decode(body)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+			"key":   key,
+		}).Warn("error computing perceptual hash")
+		return ""
+	}
+	return hash.String()
+}
+
+// Created analyzes a newly stored media object and records it in the media table.
+// Content-addressed objects are recorded once per tweet referencing them.
+func (config *Config) Created(bucket string, key string) error {
+	if hash, ok := ParseContentKey(key); ok {
+		return config.createdContent(bucket, key, hash)
+	}
+
+	userID, tweetID, err := ParseKey(key)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+			"key":   key,
+		}).Error("failed to parse userID and tweetID from key")
+		return err
+	}
+
+	output, deferred, err := config.analyze(bucket, key)
+	if err != nil {
+		return err
+	}
+	clusters, err := config.clusterFaces(key, output.FaceMatches)
+	if err != nil {
+		return err
+	}
+
+	item := mediaItem(bucket, key, output)
+	item.UserID = userID
+	item.TweetID = tweetID
+	item.FaceClusters = clusters
+	item.Deferred = deferred
+	quarantined := config.flagQuarantined(item)
+	if err := config.putMedia(item); err != nil {
+		return err
+	}
+	if quarantined {
+		if err := config.moveToQuarantine(key); err != nil {
+			return err
+		}
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"output": output,
+		"bucket": bucket,
+		"key":    key,
+	}).Info("media processed and added to ddb")
+	return nil
+}
+
+// createdContent records a content-addressed object for every tweet referencing it. Analysis
+// is skipped when a reference already has results, e.g. when the object is stored again.
+func (config *Config) createdContent(bucket string, key string, hash string) error {
+	refs, err := config.db.GetMediaRefsByHash(hash)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+			"hash":  hash,
+		}).Error("error getting media refs")
+		return err
+	}
+	if len(refs) == 0 {
+		config.log.WithFields(logrus.Fields{
+			"bucket": bucket,
+			"key":    key,
+		}).Warn("no media refs for object; nothing to record")
+		return nil
+	}
+
+	var output *analyzer.Detection
+	var clusters []string
+	missing := []*database.MediaRefItem{}
+	existing := []*database.MediaItem{}
+	for _, ref := range refs {
+		item, err := config.db.GetMedia(ref.TweetID, key)
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error":   err,
+				"tweetId": ref.TweetID,
+				"key":     key,
+			}).Error("error getting media item")
+			return err
+		}
+		// Deferred items have no analysis to reuse and are written again.
+		if item.S3Key == "" || item.Deferred {
+			missing = append(missing, ref)
+			continue
+		}
+		existing = append(existing, item)
+		if output == nil {
+			output = detection(item)
+			clusters = item.FaceClusters
+		}
+	}
+
+	deferred := false
+	if output == nil {
+		if output, deferred, err = config.analyze(bucket, key); err != nil {
+			return err
+		}
+		if clusters, err = config.clusterFaces(key, output.FaceMatches); err != nil {
+			return err
+		}
+	} else {
+		config.log.WithFields(logrus.Fields{
+			"bucket": bucket,
+			"key":    key,
+		}).Info("media already analyzed; skipping")
+	}
+	// Items analyzed before perceptual hashing existed have none to reuse.
+	if output.PHash == "" {
+		output.PHash = config.perceptualHash(key)
+	}
+
+	quarantined := false
+	for _, ref := range missing {
+		item := mediaItem(bucket, key, output)
+		item.Hash = hash
+		item.UserID = ref.UserID
+		item.TweetID = ref.TweetID
+		item.FaceClusters = clusters
+		item.Deferred = deferred
+		quarantined = config.flagQuarantined(item) || quarantined
+		if err := config.putMedia(item); err != nil {
+			return err
+		}
+	}
+	// Items recorded before the policy matched them are flagged too. An object stored again
+	// after being quarantined goes straight back to quarantine.
+	for _, item := range existing {
+		if item.Quarantined {
+			quarantined = true
+			continue
+		}
+		if !config.flagQuarantined(item) {
+			continue
+		}
+		quarantined = true
+		if err := config.putMedia(item); err != nil {
+			return err
+		}
+	}
+	if quarantined {
+		if err := config.moveToQuarantine(key); err != nil {
+			return err
+		}
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"bucket": bucket,
+		"key":    key,
+		"refs":   len(refs),
+		"added":  len(missing),
+	}).Info("media processed and added to ddb")
+	return nil
+}
+
+// deleteMedia deletes the media item for tweetID and key. Quarantined items are kept: their
+// object was moved, not removed.
+func (config *Config) deleteMedia(tweetID int64, key string) error {
+	item, err := config.db.GetMedia(tweetID, key)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error":   err,
+			"tweetId": tweetID,
+			"key":     key,
+		}).Error("error getting media item")
+		return err
+	}
+	if item.Quarantined {
+		config.log.WithFields(logrus.Fields{
+			"tweetId": tweetID,
+			"key":     key,
+		}).Info("media quarantined; keeping media item")
+		return nil
+	}
+
+	if err := config.db.DeleteMedia(&database.MediaItem{
+		TweetID: tweetID,
+		S3Key:   key,
+	}); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("error deleting media item")
+		return err
+	}
+	return nil
+}
+
+// Removed deletes the media table entries for a removed media object. Entries for
+// quarantined media are kept.
+func (config *Config) Removed(bucket string, key string) error {
+	if hash, ok := ParseContentKey(key); ok {
+		refs, err := config.db.GetMediaRefsByHash(hash)
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"hash":  hash,
+			}).Error("error getting media refs")
+			return err
+		}
+		for _, ref := range refs {
+			if err := config.deleteMedia(ref.TweetID, key); err != nil {
+				return err
+			}
+		}
+		config.log.WithFields(logrus.Fields{
+			"bucket": bucket,
+			"key":    key,
+			"refs":   len(refs),
+		}).Info("media removed from ddb")
+		return nil
+	}
+
+	_, tweetID, err := ParseKey(key)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+			"key":   key,
+		}).Error("failed to parse userID and tweetID from key")
+		return err
+	}
+
+	if err := config.deleteMedia(tweetID, key); err != nil {
+		return err
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"bucket": bucket,
+		"key":    key,
+	}).Info("image removed from ddb")
+	return nil
+}
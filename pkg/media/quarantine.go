@@ -0,0 +1,79 @@
+package media
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// QuarantinePolicy selects media to quarantine: any moderation label, or its parent, named in
+// Labels and detected with at least MinConfidence (0-100). Names are compared case-insensitively.
+type QuarantinePolicy struct {
+	Labels        []string
+	MinConfidence float32
+}
+
+// Match returns the first moderation label that falls under the policy.
+func (policy *QuarantinePolicy) Match(moderation []types.ModerationLabel) (string, bool) {
+	if policy == nil {
+		return "", false
+	}
+	for _, label := range moderation {
+		if aws.ToFloat32(label.Confidence) < policy.MinConfidence {
+			continue
+		}
+		for _, want := range policy.Labels {
+			if strings.EqualFold(want, aws.ToString(label.Name)) || strings.EqualFold(want, aws.ToString(label.ParentName)) {
+				return aws.ToString(label.Name), true
+			}
+		}
+	}
+	return "", false
+}
+
+// SetQuarantine sets the moderation policy. Matching objects are moved from media/ to
+// quarantine/ and their media items flagged Quarantined. Without one, nothing is quarantined.
+func SetQuarantine(policy *QuarantinePolicy) Option {
+	return func(config *Config) {
+		config.quarantine = policy
+	}
+}
+
+// flagQuarantined marks item as quarantined when its moderation labels match the policy.
+func (config *Config) flagQuarantined(item *database.MediaItem) bool {
+	label, ok := config.quarantine.Match(item.Moderation)
+	if !ok {
+		return false
+	}
+	item.Quarantined = true
+	item.QuarantineKey = storage.QuarantineKey(item.S3Key)
+	item.QuarantineLabel = label
+	return true
+}
+
+// moveToQuarantine moves a stored object to its quarantine key. It must run after the media
+// items are flagged, so the removal event for key leaves them in place.
+func (config *Config) moveToQuarantine(key string) error {
+	if config.storage == nil {
+		config.log.WithFields(logrus.Fields{
+			"key": key,
+		}).Warn("no storage set; quarantined media left in place")
+		return nil
+	}
+	if err := config.storage.Move(key, storage.QuarantineKey(key)); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error": err,
+			"key":   key,
+		}).Error("error moving media to quarantine")
+		return err
+	}
+	config.log.WithFields(logrus.Fields{
+		"key":        key,
+		"quarantine": storage.QuarantineKey(key),
+	}).Info("media quarantined")
+	return nil
+}
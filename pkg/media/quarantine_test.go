@@ -0,0 +1,49 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
+)
+
+func moderationLabel(name string, parent string, confidence float32) types.ModerationLabel {
+	return types.ModerationLabel{
+		Name:       aws.String(name),
+		ParentName: aws.String(parent),
+		Confidence: aws.Float32(confidence),
+	}
+}
+
+func TestQuarantinePolicyMatch(t *testing.T) {
+	policy := &QuarantinePolicy{Labels: []string{"Explicit Nudity", "violence"}, MinConfidence: 80}
+
+	tests := []struct {
+		name       string
+		policy     *QuarantinePolicy
+		moderation []types.ModerationLabel
+		want       string
+		ok         bool
+	}{
+		{"no policy", nil, []types.ModerationLabel{moderationLabel("Violence", "", 99)}, "", false},
+		{"no labels", policy, nil, "", false},
+		{"category", policy, []types.ModerationLabel{moderationLabel("Explicit Nudity", "", 95)}, "Explicit Nudity", true},
+		{"parent", policy, []types.ModerationLabel{moderationLabel("Graphic Violence Or Gore", "Violence", 90)}, "Graphic Violence Or Gore", true},
+		{"case-insensitive", policy, []types.ModerationLabel{moderationLabel("VIOLENCE", "", 90)}, "VIOLENCE", true},
+		{"unlisted", policy, []types.ModerationLabel{moderationLabel("Suggestive", "", 99)}, "", false},
+		{"below threshold", policy, []types.ModerationLabel{moderationLabel("Violence", "", 79.9)}, "", false},
+		{"at threshold", policy, []types.ModerationLabel{moderationLabel("Violence", "", 80)}, "Violence", true},
+		{"first confident match", policy, []types.ModerationLabel{
+			moderationLabel("Violence", "", 50),
+			moderationLabel("Suggestive", "", 99),
+			moderationLabel("Weapon Violence", "Violence", 85),
+		}, "Weapon Violence", true},
+		{"no threshold", &QuarantinePolicy{Labels: []string{"Violence"}}, []types.ModerationLabel{moderationLabel("Violence", "", 1)}, "Violence", true},
+	}
+	for _, test := range tests {
+		got, ok := test.policy.Match(test.moderation)
+		if got != test.want || ok != test.ok {
+			t.Errorf("%s: Match = %q, %v; want %q, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
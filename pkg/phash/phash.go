@@ -0,0 +1,108 @@
+// Package phash computes perceptual hashes of images, so re-encoded or resized copies of the
+// same picture can be found even though their bytes differ.
+package phash
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/bits"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Hash is a 64-bit difference hash (dHash).
+type Hash uint64
+
+// String returns the hash as 16 hex digits, the form stored on media items.
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// Parse reads a hash written by String.
+func Parse(s string) (Hash, error) {
+	h, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, err
+	}
+	return Hash(h), nil
+}
+
+// Distance is the number of bits that differ between two hashes. Copies of the same image
+// are usually within 10; unrelated images average around 32.
+func Distance(a Hash, b Hash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// Supported reports whether the extension of key is an image format Decode can read.
+func Supported(key string) bool {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// Decode reads a JPEG, PNG or GIF image from r and returns its dHash.
+func Decode(r io.Reader) (Hash, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return 0, err
+	}
+	return DHash(img), nil
+}
+
+// DHash shrinks img to 9x8 grayscale and sets one bit per row for each pixel brighter than
+// its right-hand neighbour. Shrinking averages every source pixel in a cell, so the hash
+// doesn't depend on the resolution the image was stored at.
+func DHash(img image.Image) Hash {
+	const width, height = 9, 8
+	var cells [height][width]float64
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return 0
+	}
+	for y := 0; y < height; y++ {
+		y0, y1 := bounds.Min.Y+y*h/height, bounds.Min.Y+(y+1)*h/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := bounds.Min.X+x*w/width, bounds.Min.X+(x+1)*w/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var sum float64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					sum += luminance(img.At(px, py))
+				}
+			}
+			cells[y][x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+
+	var hash Hash
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// luminance returns the Rec. 601 luma of c on a 0-65535 scale.
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+}
@@ -0,0 +1,90 @@
+package phash
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// pattern draws a w x h image whose brightness varies across both axes.
+func pattern(w int, h int, invert bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*255/w + (y*7%h)*255/h) / 2)
+			if (x*9/w)%3 == 1 {
+				v = 255 - v
+			}
+			if invert {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+// resize scales img to w x h by nearest neighbour.
+func resize(img image.Image, w int, h int) image.Image {
+	bounds := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.Set(x, y, img.At(bounds.Min.X+x*bounds.Dx()/w, bounds.Min.Y+y*bounds.Dy()/h))
+		}
+	}
+	return out
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b Hash
+		want int
+	}{
+		{0, 0, 0},
+		{0xffffffffffffffff, 0, 64},
+		{0xf0, 0x0f, 8},
+		{0x8000000000000001, 0x0000000000000001, 1},
+	}
+	for _, test := range tests {
+		if got := Distance(test.a, test.b); got != test.want {
+			t.Errorf("Distance(%s, %s) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	for _, h := range []Hash{0, 1, 0xdeadbeefcafef00d} {
+		got, err := Parse(h.String())
+		if err != nil || got != h {
+			t.Errorf("Parse(%q) = %s, %v; want %s", h.String(), got, err, h)
+		}
+	}
+	if _, err := Parse("not a hash"); err == nil {
+		t.Error("Parse(\"not a hash\") succeeded, want an error")
+	}
+}
+
+func TestDHashResizedCopy(t *testing.T) {
+	original := pattern(360, 240, false)
+	originalHash := DHash(original)
+	if originalHash == 0 {
+		t.Fatal("DHash of a patterned image = 0, want some bits set")
+	}
+
+	for _, size := range []image.Point{{180, 120}, {720, 480}, {97, 61}} {
+		if d := Distance(originalHash, DHash(resize(original, size.X, size.Y))); d > 4 {
+			t.Errorf("resized %v copy is %d bits away, want at most 4", size, d)
+		}
+	}
+
+	if d := Distance(originalHash, DHash(pattern(360, 240, true))); d < 32 {
+		t.Errorf("inverted image is %d bits away, want at least 32", d)
+	}
+}
+
+func TestDHashEmptyImage(t *testing.T) {
+	if got := DHash(image.NewGray(image.Rect(0, 0, 0, 0))); got != 0 {
+		t.Errorf("DHash of an empty image = %s, want 0", got)
+	}
+}
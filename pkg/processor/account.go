@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// accountFunctions are the processor functions that act on message.UserID's own account, so a
+// suspended, not found or protected error from them describes that account.
+var accountFunctions = map[string]bool{
+	"favorites":         true,
+	"followers":         true,
+	"friends":           true,
+	"timeline":          true,
+	"timeline_backfill": true,
+	"timeline_gap":      true,
+	"user":              true,
+}
+
+// pausableFlags are cleared while an account is unavailable. F_user stays set so the next
+// scheduled user fetch can notice the account is back.
+const pausableFlags = database.F_favorites | database.F_followers | database.F_friends | database.F_timeline
+
+// accountStatus returns the account status implied by err, or "" if err says nothing about it.
+func accountStatus(err error) string {
+	var (
+		notFound  *service.NotFound
+		suspended *service.Suspended
+		protected *service.Protected
+	)
+	switch {
+	case errors.As(err, &suspended):
+		return database.AccountSuspended
+	case errors.As(err, &notFound):
+		return database.AccountDeleted
+	case errors.As(err, &protected):
+		return database.AccountProtected
+	}
+	return ""
+}
+
+// setAccountStatus records status for userid. Runner flags are paused while the account is
+// unavailable and restored once a user fetch finds it active again.
+func (config *Config) setAccountStatus(userid int64, status string) error {
+	current, err := config.db.GetAccountStatus(userid)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "setAccountStatus::GetAccountStatus",
+			"error":  err.Error(),
+			"userid": userid,
+		}).Error("error getting account status")
+		return err
+	}
+
+	if current.Status != status {
+		if err := config.db.PutAccountStatus(&database.AccountStatusQuery{
+			UserID:      userid,
+			Status:      status,
+			StatusSince: time.Now().UnixMilli(),
+		}); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action": "setAccountStatus::PutAccountStatus",
+				"error":  err.Error(),
+				"userid": userid,
+				"status": status,
+			}).Error("error putting account status")
+			return err
+		}
+		config.log.WithFields(logrus.Fields{
+			"action":   "setAccountStatus",
+			"userid":   userid,
+			"status":   status,
+			"previous": current.Status,
+		}).Warn("account status changed")
+	}
+
+	if status != database.AccountActive {
+		return config.updateRunnerFlags(userid, true)
+	}
+	if current.Status != "" && current.Status != database.AccountActive {
+		return config.updateRunnerFlags(userid, false)
+	}
+	return nil
+}
+
+// updateRunnerFlags pauses or restores the pausable flags of every runner entry for userid.
+func (config *Config) updateRunnerFlags(userid int64, pause bool) error {
+	items, err := config.db.GetRunnerUsersByUserId(userid)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "updateRunnerFlags::GetRunnerUsersByUserId",
+			"error":  err.Error(),
+			"userid": userid,
+		}).Error("error getting runner users")
+		return err
+	}
+
+	for _, item := range items {
+		flags, paused := item.Flags, item.Paused
+		if pause {
+			paused = database.Set(paused, flags&pausableFlags)
+			flags = database.Clear(flags, pausableFlags)
+		} else {
+			flags = database.Set(flags, paused)
+			paused = 0
+		}
+		if flags == item.Flags && paused == item.Paused {
+			continue
+		}
+
+		if err := config.db.PutRunnerFlags(&database.RunnerItem{
+			RunnerName: item.RunnerName,
+			UserID:     item.UserID,
+			Flags:      flags,
+			Paused:     paused,
+		}); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action": "updateRunnerFlags::PutRunnerFlags",
+				"error":  err.Error(),
+				"runner": item.RunnerName,
+				"userid": userid,
+			}).Error("error putting runner flags")
+			return err
+		}
+		config.log.WithFields(logrus.Fields{
+			"action": "updateRunnerFlags",
+			"runner": item.RunnerName,
+			"userid": userid,
+			"flags":  flags,
+			"paused": paused,
+		}).Info("updated runner flags")
+	}
+	return nil
+}
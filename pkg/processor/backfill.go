@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// seedTimelineBackfill starts a backfill below lowerID unless one is already running or done.
+func (config *Config) seedTimelineBackfill(userid int64, lowerID int64, bootstrap *queue.Bootstrap) error {
+	backfillConfig, err := config.db.GetTimelineBackfillConfig(userid)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "timeline::GetTimelineBackfillConfig",
+			"error":  err.Error(),
+		}).Error("error getting timeline backfill config")
+		return err
+	}
+	if backfillConfig.NextMaxID != 0 || backfillConfig.Complete {
+		return nil
+	}
+
+	if err := config.db.PutTimelineBackfillConfig(
+		&database.BackfillConfigQuery{
+			UserID:    userid,
+			NextMaxID: lowerID - 1,
+		},
+	); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "timeline::PutTimelineBackfillConfig",
+			"error":  err.Error(),
+			"userid": userid,
+		}).Error("error putting timeline backfill config")
+		return err
+	}
+
+	return config.enqueueTimelineBackfill(userid, bootstrap)
+}
+
+func (config *Config) enqueueTimelineBackfill(userid int64, bootstrap *queue.Bootstrap) error {
+	bootstrap.Function = "timeline_backfill"
+	if err := config.queue.SendRunnerMessage(&queue.SendMessage{
+		Bootstrap: bootstrap,
+		Message: &queue.ProcessorMessage{
+			UserID: userid,
+		},
+	}); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "timelineBackfill::queue::SendRunnerMessage",
+			"error":  err.Error(),
+			"userid": userid,
+		}).Error("error sending message to queue")
+		return err
+	}
+	return nil
+}
+
+// timelineBackfill fetches one page of tweets older than the stored NextMaxID and
+// re-enqueues itself until Twitter stops returning tweets (about 3200 tweets back).
+func (config *Config) timelineBackfill(userid int64, bootstrap *queue.Bootstrap) error {
+	backfillConfig, err := config.db.GetTimelineBackfillConfig(userid)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "timelineBackfill::GetTimelineBackfillConfig",
+			"error":  err.Error(),
+		}).Error("error getting timeline backfill config")
+		return err
+	}
+
+	if backfillConfig.Complete {
+		config.log.WithFields(logrus.Fields{
+			"action": "timelineBackfill::Complete",
+			"userid": userid,
+			"count":  backfillConfig.Count,
+		}).Info("timeline backfill already complete")
+		return nil
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action": "timelineBackfill::Setup",
+		"userid": userid,
+		"maxid":  backfillConfig.NextMaxID,
+	}).Debug("setting up timeline backfill")
+
+	tweets, _, err := config.twitter.GetUserTimeline(
+		&service.QueryParams{
+			UserID: userid,
+			Count:  200,
+			MaxID:  backfillConfig.NextMaxID,
+		},
+	)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "timelineBackfill::GetUserTimeline",
+			"error":  err,
+		}).Error("error getting user's timeline")
+		return classify(err)
+	}
+
+	_, lowerID, err := config.putTweets("timelineBackfill", userid, 0, tweets, bootstrap)
+	if err != nil {
+		return err
+	}
+
+	query := &database.BackfillConfigQuery{
+		UserID:    userid,
+		NextMaxID: backfillConfig.NextMaxID,
+		Count:     backfillConfig.Count + int64(len(tweets)),
+		Complete:  len(tweets) == 0,
+	}
+	if lowerID > 0 {
+		query.NextMaxID = lowerID - 1
+	}
+	if err := config.db.PutTimelineBackfillConfig(query); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":    "timelineBackfill::PutTimelineBackfillConfig",
+			"error":     err.Error(),
+			"userid":    userid,
+			"nextMaxID": query.NextMaxID,
+		}).Error("error putting timeline backfill config")
+		return err
+	}
+
+	if !query.Complete {
+		if err := config.enqueueTimelineBackfill(userid, bootstrap); err != nil {
+			return err
+		}
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":    "timelineBackfill::Done!",
+		"userid":    userid,
+		"nextMaxID": query.NextMaxID,
+		"count":     len(tweets),
+		"total":     query.Count,
+		"complete":  query.Complete,
+	}).Info("finished getting timeline backfill page")
+
+	return nil
+}
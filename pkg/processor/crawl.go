@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/sirupsen/logrus"
+)
+
+// crawlStallTimeout is how long a followers/friends pass may go without progress before a
+// scheduled run assumes its chain of messages was lost and resumes it.
+const crawlStallTimeout = 15 * time.Minute
+
+// crawlState is the stored progress of a followers/friends crawl pass.
+type crawlState struct {
+	NextCursor    int64
+	PassStarted   int64
+	PassCompleted int64
+	LastUpdate    int64
+}
+
+// nextCrawlPage decides which cursor page a followers/friends message should fetch.
+// Chained messages carry the cursor they were sent for and only proceed while it is
+// still the stored NextCursor, so redelivered messages don't fork the chain.
+// Scheduled messages (cursor 0) start a new pass from -1 unless one is already running.
+func nextCrawlPage(messageCursor int64, state *crawlState, now time.Time) (cursor int64, passStarted int64, ok bool) {
+	inProgress := state.PassStarted > 0 && state.PassCompleted < state.PassStarted && state.NextCursor != 0
+
+	if messageCursor != 0 {
+		if inProgress && messageCursor == state.NextCursor {
+			return messageCursor, state.PassStarted, true
+		}
+		return 0, 0, false
+	}
+
+	if inProgress {
+		if now.Sub(time.UnixMilli(state.LastUpdate)) > crawlStallTimeout {
+			return state.NextCursor, state.PassStarted, true
+		}
+		return 0, 0, false
+	}
+
+	return -1, now.UnixMilli(), true
+}
+
+// enqueueCrawlPage sends the next page of a followers/friends pass back to the queue.
+func (config *Config) enqueueCrawlPage(function string, userid int64, cursor int64, bootstrap *queue.Bootstrap) error {
+	bootstrap.Function = function
+	if err := config.queue.SendRunnerMessage(&queue.SendMessage{
+		Bootstrap: bootstrap,
+		Message: &queue.ProcessorMessage{
+			UserID: userid,
+			Cursor: cursor,
+		},
+	}); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": function + "::queue::SendRunnerMessage",
+			"error":  err.Error(),
+			"userid": userid,
+			"cursor": cursor,
+		}).Error("error sending message to queue")
+		return err
+	}
+	return nil
+}
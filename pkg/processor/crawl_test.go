@@ -0,0 +1,130 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/faketwitter"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+)
+
+func TestNextCrawlPage(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	started := now.Add(-time.Hour).UnixMilli()
+	previous := now.Add(-2 * time.Hour).UnixMilli()
+	fresh := now.Add(-time.Minute).UnixMilli()
+	stalled := now.Add(-16 * time.Minute).UnixMilli()
+
+	// running is a pass part-way through, with the previous pass's completion time kept.
+	running := crawlState{NextCursor: 42, PassStarted: started, PassCompleted: previous, LastUpdate: fresh}
+
+	tests := []struct {
+		name          string
+		messageCursor int64
+		state         crawlState
+		cursor        int64
+		passStarted   int64
+		ok            bool
+	}{
+		{"first pass", 0, crawlState{}, -1, now.UnixMilli(), true},
+		{"after a completed pass", 0, crawlState{PassStarted: started, PassCompleted: fresh, LastUpdate: fresh}, -1, now.UnixMilli(), true},
+		{"scheduled while running", 0, running, 0, 0, false},
+		{"scheduled while stalled", 0, crawlState{NextCursor: 42, PassStarted: started, PassCompleted: previous, LastUpdate: stalled}, 42, started, true},
+		{"chained page", 42, running, 42, started, true},
+		{"redelivered page", 41, running, 0, 0, false},
+		{"chained after the pass completed", 42, crawlState{NextCursor: 42, PassStarted: started, PassCompleted: fresh, LastUpdate: fresh}, 0, 0, false},
+		{"chained with no pass", 42, crawlState{}, 0, 0, false},
+	}
+	for _, test := range tests {
+		state := test.state
+		cursor, passStarted, ok := nextCrawlPage(test.messageCursor, &state, now)
+		if cursor != test.cursor || passStarted != test.passStarted || ok != test.ok {
+			t.Errorf("%s: nextCrawlPage = %d, %d, %v; want %d, %d, %v", test.name, cursor, passStarted, ok, test.cursor, test.passStarted, test.ok)
+		}
+	}
+}
+
+func TestFollowersKeepsPassCompletedMidPass(t *testing.T) {
+	fake := faketwitter.New()
+	fake.Start()
+	defer fake.Close()
+
+	fake.AddUser(twitter.User{ID: 1, ScreenName: "one"})
+	// One more follower than fits on a page of IDs.
+	for id := int64(1000); id < 1000+5001; id++ {
+		fake.AddFollower(1, id)
+	}
+
+	runner := queue.NewLocal()
+	config, db, _ := newTestConfig(t,
+		SetTwitter(service.New(
+			service.SetConsumerKey("key"),
+			service.SetConsumerSecret("secret"),
+			service.SetBaseURL(fake.URL()),
+		)),
+		SetQueue(runner),
+	)
+
+	if err := config.Run(&queue.Bootstrap{Function: "followers"}, &queue.ProcessorMessage{UserID: 1}); err != nil {
+		t.Fatalf("Run(followers): %v", err)
+	}
+	mid, err := db.GetFollowersConfig(1)
+	if err != nil {
+		t.Fatalf("GetFollowersConfig: %v", err)
+	}
+	if mid.NextCursor == 0 || mid.PassStarted == 0 || mid.PassCompleted != 0 || mid.Passes != 0 {
+		t.Fatalf("followers config after the first page = %+v, want a pass in progress", mid)
+	}
+
+	// A scheduled run while the pass is in progress doesn't start another.
+	if err := config.Run(&queue.Bootstrap{Function: "followers"}, &queue.ProcessorMessage{UserID: 1}); err != nil {
+		t.Fatalf("Run(followers) again: %v", err)
+	}
+
+	var pages []*queue.ProcessorMessage
+	for {
+		message, ok := runner.Receive()
+		if !ok {
+			break
+		}
+		if message.Bootstrap.Function == "followers" {
+			pages = append(pages, message.Message)
+		}
+	}
+	if len(pages) != 1 || pages[0].Cursor != mid.NextCursor {
+		t.Fatalf("chained followers pages = %+v, want one for cursor %d", pages, mid.NextCursor)
+	}
+	if err := config.Run(&queue.Bootstrap{Function: "followers"}, pages[0]); err != nil {
+		t.Fatalf("Run(followers page): %v", err)
+	}
+
+	done, err := db.GetFollowersConfig(1)
+	if err != nil {
+		t.Fatalf("GetFollowersConfig: %v", err)
+	}
+	if done.NextCursor != 0 || done.PassStarted != mid.PassStarted || done.PassCompleted < done.PassStarted || done.Passes != 1 {
+		t.Errorf("followers config after the last page = %+v, want pass %d completed once", done, mid.PassStarted)
+	}
+	followers, err := db.GetFollowersByUserId(1)
+	if err != nil {
+		t.Fatalf("GetFollowersByUserId: %v", err)
+	}
+	if len(followers) != 5001 {
+		t.Errorf("got %d followers, want 5001", len(followers))
+	}
+
+	// The next pass keeps the last completion time until it finishes too.
+	time.Sleep(2 * time.Millisecond)
+	if err := config.Run(&queue.Bootstrap{Function: "followers"}, &queue.ProcessorMessage{UserID: 1}); err != nil {
+		t.Fatalf("Run(followers) for the next pass: %v", err)
+	}
+	next, err := db.GetFollowersConfig(1)
+	if err != nil {
+		t.Fatalf("GetFollowersConfig: %v", err)
+	}
+	if next.PassStarted <= done.PassCompleted || next.PassCompleted != done.PassCompleted || next.NextCursor == 0 || next.Passes != 1 {
+		t.Errorf("followers config part-way through the next pass = %+v, want PassCompleted %d kept", next, done.PassCompleted)
+	}
+}
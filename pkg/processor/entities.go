@@ -0,0 +1,269 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/fetch"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// entities downloads a media entity and stores it once under its SHA-256, with its content
+// type. Every tweet, user and URL pointing at the same content gets a media ref, and reuses the
+// analysis already stored for it. Downloads that fail after the fetcher's own retries are
+// recorded in the media retry table; the entry is removed once a later attempt succeeds.
+func (config *Config) entities(userId *int64, tweetId *string, entityURL *string, mediaType string) error {
+	// Video variant URLs carry a query string (?tag=12), which doesn't belong in the key.
+	u, err := url.Parse(*entityURL)
+	if err != nil {
+		return Permanent(err)
+	}
+	tweetID, err := strconv.ParseInt(*tweetId, 10, 64)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	object, err := config.fetcher.Get(fetch.OriginalURL(*entityURL))
+	var tmp *os.File
+	var hash string
+	if err == nil {
+		tmp, hash, err = spool(object.Body)
+		object.Body.Close()
+	}
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":    "entities::fetch",
+			"error":     err.Error(),
+			"userid":    *userId,
+			"tweetId":   *tweetId,
+			"entityURL": *entityURL,
+		}).Error("error fetching entity")
+		return config.retryEntity(userId, tweetId, entityURL, mediaType, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	key := storage.ContentKey(hash, path.Ext(u.Path))
+	exists, err := config.storage.Exists(key)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "entities::storage::Exists",
+			"error":  err.Error(),
+			"key":    key,
+		}).Error("error checking for stored media")
+		return err
+	}
+	// Quarantined content is not stored again; its analysis is shared like any other copy.
+	// The quarantine/ prefix is not readable here, so the media items record it instead.
+	if !exists {
+		if exists, err = config.quarantined(hash, key); err != nil {
+			return err
+		}
+	}
+
+	// The ref goes in first: storing the object triggers analysis, which reads the refs.
+	if err := config.db.PutMediaRef(&database.MediaRefItem{
+		TweetID:   tweetID,
+		UserID:    *userId,
+		EntityURL: *entityURL,
+		MediaType: mediaType,
+		Hash:      hash,
+		S3Key:     key,
+	}); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":    "entities::PutMediaRef",
+			"error":     err.Error(),
+			"entityURL": *entityURL,
+			"hash":      hash,
+		}).Error("error putting media ref")
+		return err
+	}
+
+	if exists {
+		if err := config.shareAnalysis(tweetID, *userId, hash, key); err != nil {
+			return err
+		}
+	} else if err := config.storage.PutStream(key, tmp, object.ContentType); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "entities::storage::PutStream",
+			"error":  err.Error(),
+			"key":    key,
+		}).Error("error storing entity")
+		return err
+	}
+
+	if err := config.db.DeleteMediaRetry(*entityURL); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":    "entities::DeleteMediaRetry",
+			"error":     err.Error(),
+			"entityURL": *entityURL,
+		}).Warn("error deleting media retry")
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":      "entites",
+		"userid":      userId,
+		"tweetId":     tweetId,
+		"entityURL":   entityURL,
+		"mediaType":   mediaType,
+		"contentType": object.ContentType,
+		"key":         key,
+		"duplicate":   exists,
+	}).Info("fetched and put entity")
+	return nil
+}
+
+// spool copies body to a temp file while hashing it. The file is rewound, ready to upload.
+func spool(body io.Reader) (*os.File, string, error) {
+	tmp, err := os.CreateTemp("", "tndx-media-*")
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, sum), body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, "", err
+	}
+	return tmp, hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// quarantined reports whether the object stored under key was quarantined, going by the media
+// items of the tweets already referencing hash.
+func (config *Config) quarantined(hash string, key string) (bool, error) {
+	refs, err := config.db.GetMediaRefsByHash(hash)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "quarantined::GetMediaRefsByHash",
+			"error":  err.Error(),
+			"hash":   hash,
+		}).Error("error getting media refs")
+		return false, err
+	}
+
+	for _, ref := range refs {
+		item, err := config.db.GetMedia(ref.TweetID, key)
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action":  "quarantined::GetMedia",
+				"error":   err.Error(),
+				"tweetId": ref.TweetID,
+				"key":     key,
+			}).Error("error getting media")
+			return false, err
+		}
+		if item.Quarantined {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// shareAnalysis copies the analysis of an already stored object to tweetID, so per-tweet and
+// per-user media queries find it. Nothing is copied while the first analysis is still
+// running; it covers every ref when it finishes.
+func (config *Config) shareAnalysis(tweetID int64, userID int64, hash string, key string) error {
+	refs, err := config.db.GetMediaRefsByHash(hash)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "shareAnalysis::GetMediaRefsByHash",
+			"error":  err.Error(),
+			"hash":   hash,
+		}).Error("error getting media refs")
+		return err
+	}
+
+	for _, ref := range refs {
+		if ref.TweetID == tweetID {
+			continue
+		}
+		item, err := config.db.GetMedia(ref.TweetID, key)
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action":  "shareAnalysis::GetMedia",
+				"error":   err.Error(),
+				"tweetId": ref.TweetID,
+				"key":     key,
+			}).Error("error getting media")
+			return err
+		}
+		if item.S3Key == "" {
+			continue
+		}
+
+		item.TweetID = tweetID
+		item.UserID = userID
+		if err := config.db.PutMedia(item); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action":  "shareAnalysis::PutMedia",
+				"error":   err.Error(),
+				"tweetId": tweetID,
+				"key":     key,
+			}).Error("error putting media")
+			return err
+		}
+
+		clusters := []*database.MediaClusterItem{}
+		for _, clusterID := range item.FaceClusters {
+			clusters = append(clusters, &database.MediaClusterItem{
+				TweetID:   tweetID,
+				ClusterID: clusterID,
+				MediaKey:  key,
+			})
+		}
+		if len(clusters) > 0 {
+			if err := config.db.PutMediaClusters(clusters); err != nil {
+				config.log.WithFields(logrus.Fields{
+					"action":  "shareAnalysis::PutMediaClusters",
+					"error":   err.Error(),
+					"tweetId": tweetID,
+					"key":     key,
+				}).Error("error putting media clusters")
+				return err
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// retryEntity records a failed download and returns err, marked permanent when fetching again
+// can't help (missing object, wrong content type, too large).
+func (config *Config) retryEntity(userId *int64, tweetId *string, entityURL *string, mediaType string, err error) error {
+	item := &database.MediaRetryItem{
+		EntityURL: *entityURL,
+		UserID:    *userId,
+		TweetID:   *tweetId,
+		MediaType: mediaType,
+		LastError: err.Error(),
+	}
+	var fetchErr *fetch.Error
+	if errors.As(err, &fetchErr) {
+		item.Status = fetchErr.Status
+	}
+	if putErr := config.db.PutMediaRetry(item); putErr != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":    "entities::PutMediaRetry",
+			"error":     putErr.Error(),
+			"entityURL": *entityURL,
+		}).Error("error putting media retry")
+	}
+
+	if fetchErr != nil && !fetchErr.Temporary() {
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+)
+
+func TestEntitiesSkipsQuarantinedContent(t *testing.T) {
+	body := []byte("quarantined content")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	key := storage.ContentKey(hash, ".jpg")
+
+	// An earlier tweet's copy was analyzed and moved to quarantine/.
+	config, db, store := newTestConfig(t)
+	if err := db.PutMediaRef(&database.MediaRefItem{TweetID: 100, UserID: 1, Hash: hash, S3Key: key}); err != nil {
+		t.Fatalf("PutMediaRef: %v", err)
+	}
+	if err := db.PutMedia(&database.MediaItem{
+		TweetID:         100,
+		UserID:          1,
+		S3Key:           key,
+		Quarantined:     true,
+		QuarantineKey:   storage.QuarantineKey(key),
+		QuarantineLabel: "Violence",
+	}); err != nil {
+		t.Fatalf("PutMedia: %v", err)
+	}
+
+	userID := int64(2)
+	tweetID := "200"
+	entityURL := server.URL + "/media/copy.jpg"
+	if err := config.entities(&userID, &tweetID, &entityURL, "photo"); err != nil {
+		t.Fatalf("entities: %v", err)
+	}
+
+	if exists, err := store.Exists(key); err != nil || exists {
+		t.Errorf("Exists(%s) = %v, %v; want false", key, exists, err)
+	}
+	item, err := db.GetMedia(200, key)
+	if err != nil {
+		t.Fatalf("GetMedia: %v", err)
+	}
+	if !item.Quarantined || item.UserID != 2 {
+		t.Errorf("shared media item = %+v, want the quarantined analysis for user 2", item)
+	}
+}
+
+func TestEntitiesRecordsRetry(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	config, db, _ := newTestConfig(t)
+	userID := int64(1)
+	tweetID := "100"
+	entityURL := server.URL + "/media/missing.jpg"
+	err := config.entities(&userID, &tweetID, &entityURL, "photo")
+	if !IsPermanent(err) {
+		t.Fatalf("entities error = %v, want permanent", err)
+	}
+
+	retries, err := db.GetMediaRetries()
+	if err != nil {
+		t.Fatalf("GetMediaRetries: %v", err)
+	}
+	if len(retries) != 1 || retries[0].EntityURL != entityURL || retries[0].Status != http.StatusNotFound {
+		t.Fatalf("got retries %+v, want one 404 for %s", retries, entityURL)
+	}
+}
+
+func TestEntitiesStoresContentOnce(t *testing.T) {
+	body := []byte("not really a jpeg")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	config, db, store := newTestConfig(t)
+	userID := int64(1)
+	for _, tweetID := range []string{"100", "200"} {
+		entityURL := server.URL + "/media/" + tweetID + ".jpg"
+		if err := config.entities(&userID, &tweetID, &entityURL, "photo"); err != nil {
+			t.Fatalf("entities(%s): %v", tweetID, err)
+		}
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	key := storage.ContentKey(hash, ".jpg")
+	if exists, err := store.Exists(key); err != nil || !exists {
+		t.Fatalf("Exists(%s) = %v, %v; want true", key, exists, err)
+	}
+
+	refs, err := db.GetMediaRefsByHash(hash)
+	if err != nil {
+		t.Fatalf("GetMediaRefsByHash: %v", err)
+	}
+	if len(refs) != 2 {
+		t.Fatalf("got %d media refs, want 2", len(refs))
+	}
+	for _, ref := range refs {
+		if ref.S3Key != key {
+			t.Errorf("ref %d key = %q, want %q", ref.TweetID, ref.S3Key, key)
+		}
+	}
+}
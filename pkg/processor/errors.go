@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"errors"
+
+	"github.com/rmrfslashbin/tndx/pkg/service"
+)
+
+// PermanentError marks a failure that will not go away by redelivering the message,
+// such as a malformed message or a tweet that no longer exists.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err as a PermanentError. A nil err stays nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err, or any error it wraps, is a PermanentError.
+// Any other error is treated as transient and the message should be retried.
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// classify marks Twitter errors that will fail the same way on retry as permanent: missing,
+// suspended and protected accounts or tweets, and otherwise invalid requests. Rate limits,
+// auth failures, server errors and transport errors stay transient.
+func classify(err error) error {
+	var (
+		notFound  *service.NotFound
+		suspended *service.Suspended
+		protected *service.Protected
+		invalid   *service.Invalid
+	)
+	switch {
+	case errors.As(err, &notFound), errors.As(err, &suspended), errors.As(err, &protected), errors.As(err, &invalid):
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// favorites fetches the newest page of favorites above the stored MaxID. When that page is
+// full, older pages may have been skipped, so it re-enqueues itself with maxID below the page
+// and walks back to the stored MaxID before advancing it.
+func (config *Config) favorites(userid int64, maxID int64, bootstrap *queue.Bootstrap) error {
+	favConfig, err := config.db.GetFavoritesConfig(userid)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "favorites::GetFavoritesConfig",
+			"error":  err.Error(),
+		}).Error("error getting favorites config")
+		return err
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":  "favorites::Setup",
+		"userid":  userid,
+		"sinceid": favConfig.MaxID,
+		"maxid":   maxID,
+	}).Info("setting up favorites")
+
+	tweets, _, err := config.twitter.GetUserFavorites(
+		&service.QueryParams{
+			Count:   200,
+			SinceID: favConfig.MaxID,
+			MaxID:   maxID,
+			UserID:  userid,
+		},
+	)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "favorites",
+			"error":  err,
+		}).Error("error getting user's favorites")
+		return classify(err)
+	}
+
+	listOfTweets := make([]*database.UserToTweetLink, len(tweets))
+	for t := range tweets {
+		listOfTweets[t] = &database.UserToTweetLink{UserID: userid, TweetID: tweets[t].ID}
+	}
+
+	upperID, lowerID, err := config.putTweets("favorites", userid, 0, tweets, bootstrap)
+	if err != nil {
+		return err
+	}
+
+	if err := config.db.PutFavorites(listOfTweets); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "favorites::PutFavorites",
+			"error":  err.Error(),
+		}).Error("error putting favorites")
+		return err
+	}
+
+	pendingMaxID := favConfig.PendingMaxID
+	if upperID > pendingMaxID {
+		pendingMaxID = upperID
+	}
+
+	query := &database.TweetConfigQuery{
+		UserID:  userid,
+		SinceID: favConfig.SinceID,
+		MaxID:   favConfig.MaxID,
+	}
+	walking := favConfig.MaxID != 0 && len(tweets) == 200 && lowerID-1 > favConfig.MaxID
+	if walking {
+		// Hold the new MaxID back until the walk reaches the stored one, so an interrupted
+		// walk is retried from the same since_id instead of skipping the rest of the range.
+		query.PendingMaxID = pendingMaxID
+	} else if pendingMaxID > 0 {
+		query.MaxID = pendingMaxID
+		if lowerID > 0 {
+			query.SinceID = lowerID
+		}
+	}
+
+	if walking || pendingMaxID > 0 {
+		if err := config.db.PutFavoritesConfig(query); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action":       "favorites::PutFavoritesConfig",
+				"error":        err.Error(),
+				"userid":       userid,
+				"MaxUpperID":   query.MaxID,
+				"SinceLowerID": query.SinceID,
+				"PendingMaxID": query.PendingMaxID,
+			}).Error("error putting favorites config")
+			return err
+		}
+	}
+
+	if walking {
+		if err := config.enqueueFavorites(userid, lowerID-1, bootstrap); err != nil {
+			return err
+		}
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":  "favorites::Done!",
+		"userid":  userid,
+		"upperID": upperID,
+		"lowerID": lowerID,
+		"count":   len(tweets),
+		"walking": walking,
+	}).Info("finished getting favorites")
+
+	return nil
+}
+
+func (config *Config) enqueueFavorites(userid int64, maxID int64, bootstrap *queue.Bootstrap) error {
+	bootstrap.Function = "favorites"
+	if err := config.queue.SendRunnerMessage(&queue.SendMessage{
+		Bootstrap: bootstrap,
+		Message: &queue.ProcessorMessage{
+			UserID: userid,
+			MaxID:  maxID,
+		},
+	}); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "favorites::queue::SendRunnerMessage",
+			"error":  err.Error(),
+			"userid": userid,
+			"maxID":  maxID,
+		}).Error("error sending message to queue")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/faketwitter"
+	"github.com/rmrfslashbin/tndx/pkg/kinesis"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+)
+
+func TestFavoritesWalksBackFullPages(t *testing.T) {
+	fake := faketwitter.New()
+	fake.Start()
+	defer fake.Close()
+
+	author := &twitter.User{ID: 2, ScreenName: "two"}
+	fake.AddUser(twitter.User{ID: 1, ScreenName: "one"})
+	fake.AddUser(*author)
+	for id := int64(1); id <= 500; id++ {
+		fake.AddTweet(twitter.Tweet{ID: id, User: author, Text: "liked"})
+		fake.AddFavorite(1, id)
+	}
+
+	runner := queue.NewLocal()
+	config, db, _ := newTestConfig(t,
+		SetTwitter(service.New(
+			service.SetConsumerKey("key"),
+			service.SetConsumerSecret("secret"),
+			service.SetBaseURL(fake.URL()),
+		)),
+		SetKinesis(kinesis.NewLocal(kinesis.SetLocalPath(filepath.Join(t.TempDir(), "tweets.json")))),
+		SetQueue(runner),
+	)
+
+	// The last sync stopped at tweet 100; 400 newer favorites arrived since.
+	if err := db.PutFavoritesConfig(&database.TweetConfigQuery{UserID: 1, SinceID: 90, MaxID: 100}); err != nil {
+		t.Fatalf("PutFavoritesConfig: %v", err)
+	}
+
+	if err := config.Run(&queue.Bootstrap{Function: "favorites"}, &queue.ProcessorMessage{UserID: 1}); err != nil {
+		t.Fatalf("Run(favorites): %v", err)
+	}
+
+	// The full first page leaves MaxID in place until the walk reaches it.
+	favConfig, err := db.GetFavoritesConfig(1)
+	if err != nil {
+		t.Fatalf("GetFavoritesConfig: %v", err)
+	}
+	if favConfig.MaxID != 100 || favConfig.PendingMaxID != 500 {
+		t.Errorf("favorites config after the first page = %+v, want MaxID 100 and PendingMaxID 500", favConfig)
+	}
+
+	walked := 0
+	for {
+		message, ok := runner.Receive()
+		if !ok {
+			break
+		}
+		if message.Bootstrap.Function != "favorites" {
+			continue
+		}
+		walked++
+		if message.Message.MaxID != 300 {
+			t.Errorf("favorites continuation MaxID = %d, want 300", message.Message.MaxID)
+		}
+		if err := config.Run(message.Bootstrap, message.Message); err != nil {
+			t.Fatalf("Run(favorites continuation): %v", err)
+		}
+	}
+	if walked != 1 {
+		t.Errorf("walked %d favorites pages, want 1", walked)
+	}
+
+	favConfig, err = db.GetFavoritesConfig(1)
+	if err != nil {
+		t.Fatalf("GetFavoritesConfig: %v", err)
+	}
+	if favConfig.MaxID != 500 || favConfig.PendingMaxID != 0 {
+		t.Errorf("favorites config after the walk = %+v, want MaxID 500 and no pending MaxID", favConfig)
+	}
+
+	favorites, err := db.GetFavoritesByUserId(1)
+	if err != nil {
+		t.Fatalf("GetFavoritesByUserId: %v", err)
+	}
+	if len(favorites) != 400 {
+		t.Errorf("got %d favorites, want 400", len(favorites))
+	}
+}
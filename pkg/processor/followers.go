@@ -0,0 +1,127 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// followers fetches one cursor page of the user's follower IDs and chains the next page through
+// the queue until Twitter returns NextCursor 0, which completes the pass. Profiles are
+// hydrated separately by hydrate_users.
+func (config *Config) followers(userid int64, cursor int64, bootstrap *queue.Bootstrap) error {
+	followersConfig, err := config.db.GetFollowersConfig(userid)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "followers::GetFollowersConfig",
+			"error":  err.Error(),
+		}).Error("error getting follower config")
+		return err
+	}
+
+	cursor, passStarted, ok := nextCrawlPage(cursor, &crawlState{
+		NextCursor:    followersConfig.NextCursor,
+		PassStarted:   followersConfig.PassStarted,
+		PassCompleted: followersConfig.PassCompleted,
+		LastUpdate:    followersConfig.LastUpdate,
+	}, time.Now())
+	if !ok {
+		config.log.WithFields(logrus.Fields{
+			"action":      "followers::Setup",
+			"userid":      userid,
+			"nextCursor":  followersConfig.NextCursor,
+			"passStarted": followersConfig.PassStarted,
+		}).Info("followers pass already in progress")
+		return nil
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action": "followers::Setup",
+		"userid": userid,
+		"cursor": cursor,
+	}).Debug("setting up followers")
+
+	followers, _, err := config.twitter.GetUserFollowerIDs(
+		&service.QueryParams{
+			Count:  5000,
+			UserID: userid,
+			Cursor: cursor,
+		},
+	)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "followers::GetUserFollowerIDs",
+			"error":  err,
+		}).Error("error getting user's followers")
+		return classify(err)
+	}
+
+	listOfFollowers := make([]*database.UserToFollowerLink, len(followers.IDs))
+	for f := range followers.IDs {
+		listOfFollowers[f] = &database.UserToFollowerLink{UserID: userid, FollowerID: followers.IDs[f]}
+	}
+	if err := config.db.PutFollowers(listOfFollowers); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "followers::PutFollowers",
+			"error":  err.Error(),
+		}).Error("error putting followers")
+		return err
+	}
+
+	if err := config.enqueueHydrateUsers("followers", followers.IDs, bootstrap); err != nil {
+		return err
+	}
+
+	// NextCursor 0 means this was the last page of the pass. Changes are recorded before the
+	// config is marked complete so a failure retries this page. Until then the previous pass's
+	// completion time is kept; the pass is in progress while it is older than PassStarted.
+	passCompleted := followersConfig.PassCompleted
+	passes := followersConfig.Passes
+	if followers.NextCursor == 0 {
+		if err := config.followerChanges(userid, passStarted, passes == 0); err != nil {
+			return err
+		}
+		passCompleted = time.Now().UnixMilli()
+		passes++
+	}
+
+	if err := config.db.PutFollowersConfig(
+		&database.CursoredTweetConfigQuery{
+			UserID:         userid,
+			NextCursor:     followers.NextCursor,
+			PassStarted:    passStarted,
+			PassCompleted:  passCompleted,
+			Passes:         passes,
+			PreviousCursor: followers.PreviousCursor,
+		},
+	); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":         "followers::PutFollowersConfig",
+			"error":          err.Error(),
+			"userid":         userid,
+			"nextCursor":     followers.NextCursor,
+			"previousCursor": followers.PreviousCursor,
+		}).Error("error putting followers config")
+		return err
+	}
+
+	if followers.NextCursor != 0 {
+		if err := config.enqueueCrawlPage("followers", userid, followers.NextCursor, bootstrap); err != nil {
+			return err
+		}
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":         "followers::Done!",
+		"userid":         userid,
+		"nextCursor":     followers.NextCursor,
+		"previousCursor": followers.PreviousCursor,
+		"count":          len(followers.IDs),
+		"passCompleted":  followers.NextCursor == 0,
+	}).Info("finished getting followers")
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// friends fetches one cursor page of the user's friend IDs and chains the next page through
+// the queue until Twitter returns NextCursor 0, which completes the pass. Profiles are
+// hydrated separately by hydrate_users.
+func (config *Config) friends(userid int64, cursor int64, bootstrap *queue.Bootstrap) error {
+	friendsConfig, err := config.db.GetFriendsConfig(userid)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "friends::GetFriendsConfig",
+			"error":  err.Error(),
+		}).Error("error getting friends config")
+		return err
+	}
+	config.log.WithFields(logrus.Fields{
+		"action":     "friends::GetFriendsConfig",
+		"userid":     userid,
+		"nextCursor": friendsConfig.NextCursor,
+	}).Debug("got friends config")
+
+	cursor, passStarted, ok := nextCrawlPage(cursor, &crawlState{
+		NextCursor:    friendsConfig.NextCursor,
+		PassStarted:   friendsConfig.PassStarted,
+		PassCompleted: friendsConfig.PassCompleted,
+		LastUpdate:    friendsConfig.LastUpdate,
+	}, time.Now())
+	if !ok {
+		config.log.WithFields(logrus.Fields{
+			"action":      "friends::Setup",
+			"userid":      userid,
+			"nextCursor":  friendsConfig.NextCursor,
+			"passStarted": friendsConfig.PassStarted,
+		}).Info("friends pass already in progress")
+		return nil
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action": "friends::Setup",
+		"userid": userid,
+		"cursor": cursor,
+	}).Debug("setting up friends")
+
+	friends, _, err := config.twitter.GetUserFriendIDs(
+		&service.QueryParams{
+			Count:  5000,
+			UserID: userid,
+			Cursor: cursor,
+		},
+	)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "friends::GetUserFriendIDs",
+			"error":  err,
+		}).Error("error getting user's friends")
+		return classify(err)
+	}
+
+	listOfFriends := make([]*database.UserToFriendLink, len(friends.IDs))
+	for f := range friends.IDs {
+		listOfFriends[f] = &database.UserToFriendLink{UserID: userid, FriendID: friends.IDs[f]}
+	}
+	if err := config.db.PutFriends(listOfFriends); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "friends::PutFriends",
+			"error":  err.Error(),
+		}).Error("error putting friends")
+		return err
+	}
+
+	if err := config.enqueueHydrateUsers("friends", friends.IDs, bootstrap); err != nil {
+		return err
+	}
+
+	// NextCursor 0 means this was the last page of the pass. Changes are recorded before the
+	// config is marked complete so a failure retries this page. Until then the previous pass's
+	// completion time is kept; the pass is in progress while it is older than PassStarted.
+	passCompleted := friendsConfig.PassCompleted
+	passes := friendsConfig.Passes
+	if friends.NextCursor == 0 {
+		if err := config.friendChanges(userid, passStarted, passes == 0); err != nil {
+			return err
+		}
+		passCompleted = time.Now().UnixMilli()
+		passes++
+	}
+
+	if err := config.db.PutFriendsConfig(
+		&database.CursoredTweetConfigQuery{
+			UserID:         userid,
+			NextCursor:     friends.NextCursor,
+			PreviousCursor: friends.PreviousCursor,
+			PassStarted:    passStarted,
+			PassCompleted:  passCompleted,
+			Passes:         passes,
+		},
+	); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":         "friends::PutFriendsConfig",
+			"error":          err.Error(),
+			"userid":         userid,
+			"nextCursor":     friends.NextCursor,
+			"previousCursor": friends.PreviousCursor,
+		}).Error("error putting friends config")
+		return err
+	}
+
+	if friends.NextCursor != 0 {
+		if err := config.enqueueCrawlPage("friends", userid, friends.NextCursor, bootstrap); err != nil {
+			return err
+		}
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":         "friends::Done!",
+		"userid":         userid,
+		"nextCursor":     friends.NextCursor,
+		"previousCursor": friends.PreviousCursor,
+		"count":          len(friends.IDs),
+		"passCompleted":  friends.NextCursor == 0,
+	}).Info("finished getting friends")
+
+	return nil
+}
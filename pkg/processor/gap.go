@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"errors"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// gapFunctions maps a params domain to the processor function that fills its gaps.
+var gapFunctions = map[string]string{
+	"tweets": "timeline_gap",
+}
+
+// openGap records a gap when an incremental timeline sync returned a full page. Twitter
+// returns the newest tweets first, so anything between the previous MaxID and the oldest
+// tweet on the page may have been skipped.
+func (config *Config) openGap(kind string, userid int64, previousMaxID int64, lowerID int64, count int, bootstrap *queue.Bootstrap) error {
+	if previousMaxID == 0 || count < 200 || lowerID-1 <= previousMaxID {
+		return nil
+	}
+
+	query := &database.GapQuery{
+		UserID:  userid,
+		Kind:    kind,
+		SinceID: previousMaxID,
+		MaxID:   lowerID - 1,
+	}
+	if err := config.db.PutGap(query); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":  "openGap::PutGap",
+			"error":   err.Error(),
+			"userid":  userid,
+			"kind":    kind,
+			"sinceID": query.SinceID,
+			"maxID":   query.MaxID,
+		}).Error("error putting gap")
+		return err
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":  "openGap",
+		"userid":  userid,
+		"kind":    kind,
+		"sinceID": query.SinceID,
+		"maxID":   query.MaxID,
+	}).Info("opened gap")
+
+	return config.enqueueGap(query, bootstrap)
+}
+
+func (config *Config) enqueueGap(query *database.GapQuery, bootstrap *queue.Bootstrap) error {
+	bootstrap.Function = gapFunctions[query.Kind]
+	if err := config.queue.SendRunnerMessage(&queue.SendMessage{
+		Bootstrap: bootstrap,
+		Message: &queue.ProcessorMessage{
+			UserID:  query.UserID,
+			SinceID: query.SinceID,
+			MaxID:   query.MaxID,
+		},
+	}); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "enqueueGap::queue::SendRunnerMessage",
+			"error":  err.Error(),
+			"userid": query.UserID,
+			"kind":   query.Kind,
+		}).Error("error sending message to queue")
+		return err
+	}
+	return nil
+}
+
+// fillGap fetches one page of the gap (sinceID, maxID] and re-enqueues itself with a lower
+// maxID until Twitter returns nothing more in the range.
+func (config *Config) fillGap(kind string, userid int64, sinceID int64, maxID int64, bootstrap *queue.Bootstrap) error {
+	if sinceID == 0 || maxID == 0 {
+		return Permanent(errors.New("gap since_id and max_id are required"))
+	}
+
+	gaps, err := config.db.GetGaps(userid, kind)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "fillGap::GetGaps",
+			"error":  err.Error(),
+		}).Error("error getting gaps")
+		return err
+	}
+	var gap *database.GapItem
+	for _, g := range gaps {
+		if g.SinceID == sinceID {
+			gap = g
+		}
+	}
+
+	// A missing gap has already closed, and a lower stored MaxID means a later page has
+	// already been fetched; either way this message is a redelivery.
+	if gap == nil || gap.MaxID < maxID {
+		config.log.WithFields(logrus.Fields{
+			"action":  "fillGap",
+			"userid":  userid,
+			"kind":    kind,
+			"sinceID": sinceID,
+			"maxID":   maxID,
+		}).Info("gap already filled past this page")
+		return nil
+	}
+
+	params := &service.QueryParams{
+		UserID:  userid,
+		Count:   200,
+		SinceID: sinceID,
+		MaxID:   maxID,
+	}
+	var tweets []twitter.Tweet
+	switch kind {
+	case "tweets":
+		tweets, _, err = config.twitter.GetUserTimeline(params)
+	default:
+		return Permanent(errors.New("invalid gap kind: " + kind))
+	}
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "fillGap::" + kind,
+			"error":  err,
+		}).Error("error filling gap")
+		return classify(err)
+	}
+
+	_, lowerID, err := config.putTweets("fillGap::"+kind, userid, 0, tweets, bootstrap)
+	if err != nil {
+		return err
+	}
+
+	// The gap is closed once Twitter has nothing left in the range.
+	if len(tweets) == 0 || lowerID-1 <= sinceID {
+		if err := config.db.DeleteGap(userid, kind, sinceID); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action": "fillGap::DeleteGap",
+				"error":  err.Error(),
+			}).Error("error deleting gap")
+			return err
+		}
+		config.log.WithFields(logrus.Fields{
+			"action":  "fillGap::Done!",
+			"userid":  userid,
+			"kind":    kind,
+			"sinceID": sinceID,
+			"count":   gap.Count + int64(len(tweets)),
+		}).Info("closed gap")
+		return nil
+	}
+
+	query := &database.GapQuery{
+		UserID:  userid,
+		Kind:    kind,
+		SinceID: sinceID,
+		MaxID:   lowerID - 1,
+		Count:   gap.Count + int64(len(tweets)),
+	}
+	if err := config.db.PutGap(query); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "fillGap::PutGap",
+			"error":  err.Error(),
+		}).Error("error putting gap")
+		return err
+	}
+	if err := config.enqueueGap(query, bootstrap); err != nil {
+		return err
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":  "fillGap::Done!",
+		"userid":  userid,
+		"kind":    kind,
+		"sinceID": sinceID,
+		"maxID":   query.MaxID,
+		"count":   len(tweets),
+	}).Info("finished getting gap page")
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/sirupsen/logrus"
+)
+
+// graphEdge is a follower/friend edge reduced to what change detection needs.
+type graphEdge struct {
+	OtherID   int64
+	FirstSeen int64
+	LastSeen  int64
+}
+
+// graphChanges compares the stored edges with a pass that has just completed. Edges not seen
+// since the pass started are gone and are dated to the pass start; edges first seen during the
+// pass are new and are dated to when they were first seen. The first pass for a user only
+// establishes the baseline, so it prunes stale edges without recording any events.
+func graphChanges(domain string, userid int64, edges []graphEdge, passStarted int64, baseline bool) (history []*database.HistoryItem, removed []int64) {
+	for _, edge := range edges {
+		switch {
+		case edge.LastSeen < passStarted:
+			removed = append(removed, edge.OtherID)
+			if !baseline {
+				history = append(history, &database.HistoryItem{
+					UserID:    userid,
+					EventKey:  database.HistoryEventKey(domain, passStarted, edge.OtherID),
+					Domain:    domain,
+					Event:     "unfollowed",
+					OtherID:   edge.OtherID,
+					Timestamp: passStarted,
+				})
+			}
+		case edge.FirstSeen >= passStarted && !baseline:
+			history = append(history, &database.HistoryItem{
+				UserID:    userid,
+				EventKey:  database.HistoryEventKey(domain, edge.FirstSeen, edge.OtherID),
+				Domain:    domain,
+				Event:     "followed",
+				OtherID:   edge.OtherID,
+				Timestamp: edge.FirstSeen,
+			})
+		}
+	}
+	return history, removed
+}
+
+// followerChanges records follow/unfollow events for a completed followers pass and removes
+// the edges it no longer saw.
+func (config *Config) followerChanges(userid int64, passStarted int64, baseline bool) error {
+	links, err := config.db.GetFollowersByUserId(userid)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "followers::GetFollowersByUserId",
+			"error":  err.Error(),
+			"userid": userid,
+		}).Error("error getting followers")
+		return err
+	}
+
+	edges := make([]graphEdge, len(links))
+	for l := range links {
+		edges[l] = graphEdge{OtherID: links[l].FollowerID, FirstSeen: links[l].FirstSeen, LastSeen: links[l].LastSeen}
+	}
+	history, removed := graphChanges("followers", userid, edges, passStarted, baseline)
+
+	if err := config.db.PutHistory(history); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "followers::PutHistory",
+			"error":  err.Error(),
+			"userid": userid,
+		}).Error("error putting follower history")
+		return err
+	}
+	for _, followerID := range removed {
+		if err := config.db.DeleteFollower(&database.UserToFollowerLink{UserID: userid, FollowerID: followerID}); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action":     "followers::DeleteFollower",
+				"error":      err.Error(),
+				"userid":     userid,
+				"followerId": followerID,
+			}).Error("error deleting follower")
+			return err
+		}
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":   "followers::Changes",
+		"userid":   userid,
+		"events":   len(history),
+		"removed":  len(removed),
+		"baseline": baseline,
+	}).Info("recorded follower changes")
+
+	return nil
+}
+
+// friendChanges records follow/unfollow events for a completed friends pass and removes the
+// edges it no longer saw.
+func (config *Config) friendChanges(userid int64, passStarted int64, baseline bool) error {
+	links, err := config.db.GetFriendsByUserId(userid)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "friends::GetFriendsByUserId",
+			"error":  err.Error(),
+			"userid": userid,
+		}).Error("error getting friends")
+		return err
+	}
+
+	edges := make([]graphEdge, len(links))
+	for l := range links {
+		edges[l] = graphEdge{OtherID: links[l].FriendID, FirstSeen: links[l].FirstSeen, LastSeen: links[l].LastSeen}
+	}
+	history, removed := graphChanges("friends", userid, edges, passStarted, baseline)
+
+	if err := config.db.PutHistory(history); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "friends::PutHistory",
+			"error":  err.Error(),
+			"userid": userid,
+		}).Error("error putting friend history")
+		return err
+	}
+	for _, friendID := range removed {
+		if err := config.db.DeleteFriend(&database.UserToFriendLink{UserID: userid, FriendID: friendID}); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action":   "friends::DeleteFriend",
+				"error":    err.Error(),
+				"userid":   userid,
+				"friendId": friendID,
+			}).Error("error deleting friend")
+			return err
+		}
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":   "friends::Changes",
+		"userid":   userid,
+		"events":   len(history),
+		"removed":  len(removed),
+		"baseline": baseline,
+	}).Info("recorded friend changes")
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+)
+
+func TestGraphChanges(t *testing.T) {
+	const passStarted = 1000
+
+	kept := graphEdge{OtherID: 1, FirstSeen: 100, LastSeen: 1500}
+	gone := graphEdge{OtherID: 2, FirstSeen: 100, LastSeen: 900}
+	added := graphEdge{OtherID: 3, FirstSeen: 1200, LastSeen: 1500}
+	addedAtStart := graphEdge{OtherID: 4, FirstSeen: passStarted, LastSeen: passStarted}
+
+	unfollowed := func(id int64) *database.HistoryItem {
+		return &database.HistoryItem{
+			UserID:    7,
+			EventKey:  database.HistoryEventKey("followers", passStarted, id),
+			Domain:    "followers",
+			Event:     "unfollowed",
+			OtherID:   id,
+			Timestamp: passStarted,
+		}
+	}
+	followed := func(id int64, firstSeen int64) *database.HistoryItem {
+		return &database.HistoryItem{
+			UserID:    7,
+			EventKey:  database.HistoryEventKey("followers", firstSeen, id),
+			Domain:    "followers",
+			Event:     "followed",
+			OtherID:   id,
+			Timestamp: firstSeen,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		edges    []graphEdge
+		baseline bool
+		history  []*database.HistoryItem
+		removed  []int64
+	}{
+		{"no edges", nil, false, nil, nil},
+		{"unchanged", []graphEdge{kept}, false, nil, nil},
+		{"unfollowed at pass start", []graphEdge{kept, gone}, false, []*database.HistoryItem{unfollowed(2)}, []int64{2}},
+		{"followed when first seen", []graphEdge{kept, added}, false, []*database.HistoryItem{followed(3, 1200)}, nil},
+		{"first seen at pass start", []graphEdge{addedAtStart}, false, []*database.HistoryItem{followed(4, passStarted)}, nil},
+		{"both", []graphEdge{gone, added}, false, []*database.HistoryItem{unfollowed(2), followed(3, 1200)}, []int64{2}},
+		{"baseline prunes without events", []graphEdge{kept, gone, added}, true, nil, []int64{2}},
+	}
+	for _, test := range tests {
+		history, removed := graphChanges("followers", 7, test.edges, passStarted, test.baseline)
+		if !reflect.DeepEqual(history, test.history) {
+			t.Errorf("%s: history = %+v, want %+v", test.name, history, test.history)
+		}
+		if !reflect.DeepEqual(removed, test.removed) {
+			t.Errorf("%s: removed = %v, want %v", test.name, removed, test.removed)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// hydrateBatchSize is the most user IDs users/lookup accepts per call.
+const hydrateBatchSize = 100
+
+// enqueueHydrateUsers splits the ids whose profiles are missing or stale into users/lookup
+// sized batches and sends a hydrate_users message for each.
+func (config *Config) enqueueHydrateUsers(action string, ids []int64, bootstrap *queue.Bootstrap) error {
+	stale, err := config.staleUsers(ids)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": action + "::db::GetHydratedUsers",
+			"error":  err.Error(),
+			"count":  len(ids),
+		}).Error("error getting hydrated users")
+		return err
+	}
+
+	for start := 0; start < len(stale); start += hydrateBatchSize {
+		end := start + hydrateBatchSize
+		if end > len(stale) {
+			end = len(stale)
+		}
+		bootstrap.Function = "hydrate_users"
+		if err := config.queue.SendRunnerMessage(&queue.SendMessage{
+			Bootstrap: bootstrap,
+			Message: &queue.ProcessorMessage{
+				UserIDs: stale[start:end],
+			},
+		}); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action": action + "::queue::SendRunnerMessage::hydrate_users",
+				"error":  err.Error(),
+				"count":  end - start,
+			}).Error("error sending message to queue")
+			return err
+		}
+	}
+	return nil
+}
+
+// staleUsers returns the ids that have no hydrated mark, or whose mark has expired.
+func (config *Config) staleUsers(ids []int64) ([]int64, error) {
+	items, err := config.db.GetHydratedUsers(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	fresh := make(map[int64]bool, len(items))
+	for _, item := range items {
+		if item.Expires >= now {
+			fresh[item.UserID] = true
+		}
+	}
+
+	stale := []int64{}
+	for _, id := range ids {
+		if !fresh[id] {
+			stale = append(stale, id)
+		}
+	}
+	return stale, nil
+}
+
+// hydrateUsers looks up a batch of user IDs and stores each profile under users/. Users
+// Twitter no longer returns (suspended or deleted) are skipped. Every requested ID is then
+// marked hydrated, so it isn't looked up again until the mark expires.
+func (config *Config) hydrateUsers(userids []int64) error {
+	if len(userids) == 0 || len(userids) > hydrateBatchSize {
+		return Permanent(errors.New("hydrate_users needs 1 to 100 user ids"))
+	}
+
+	users, _, err := config.twitter.LookupUsers(&twitter.UserLookupParams{UserID: userids})
+	// users/lookup answers not found when every user in the batch is gone.
+	var notFound *service.NotFound
+	if err != nil && !errors.As(err, &notFound) {
+		config.log.WithFields(logrus.Fields{
+			"action": "hydrateUsers::LookupUsers",
+			"error":  err,
+		}).Error("error looking up users")
+		return classify(err)
+	}
+
+	for u := range users {
+		if data, err := json.Marshal(users[u]); err == nil {
+			if err := config.storage.Put(storage.UserKey(users[u].IDStr), data); err != nil {
+				config.log.WithFields(logrus.Fields{
+					"action": "hydrateUsers::Put",
+					"error":  err.Error(),
+					"userid": users[u].ID,
+				}).Error("error storing user")
+				return err
+			}
+		}
+	}
+
+	if err := config.db.PutHydratedUsers(userids, config.hydrateUsersTTL); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "hydrateUsers::PutHydratedUsers",
+			"error":  err.Error(),
+			"count":  len(userids),
+		}).Error("error putting hydrated users")
+		return err
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":    "hydrateUsers::Done!",
+		"requested": len(userids),
+		"count":     len(users),
+	}).Info("finished hydrating users")
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+)
+
+func TestEnqueueHydrateUsersSkipsFreshProfiles(t *testing.T) {
+	runner := queue.NewLocal()
+	config, db, _ := newTestConfig(t, SetQueue(runner))
+
+	if err := db.PutHydratedUsers([]int64{1, 2}, time.Hour); err != nil {
+		t.Fatalf("PutHydratedUsers: %v", err)
+	}
+	if err := db.PutHydratedUsers([]int64{3}, -time.Hour); err != nil {
+		t.Fatalf("PutHydratedUsers: %v", err)
+	}
+
+	if err := config.enqueueHydrateUsers("test", []int64{1, 2, 3, 4}, &queue.Bootstrap{}); err != nil {
+		t.Fatalf("enqueueHydrateUsers: %v", err)
+	}
+
+	message, ok := runner.Receive()
+	if !ok {
+		t.Fatal("no hydrate_users message queued")
+	}
+	if got := message.Message.UserIDs; len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Errorf("hydrate_users ids = %v, want [3 4]", got)
+	}
+	if runner.Len() != 0 {
+		t.Errorf("%d more messages queued, want none", runner.Len())
+	}
+
+	// Nothing is queued once every profile is fresh.
+	if err := config.enqueueHydrateUsers("test", []int64{1, 2}, &queue.Bootstrap{}); err != nil {
+		t.Fatalf("enqueueHydrateUsers: %v", err)
+	}
+	if runner.Len() != 0 {
+		t.Errorf("%d messages queued for fresh profiles, want none", runner.Len())
+	}
+}
@@ -0,0 +1,300 @@
+package processor
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/fetch"
+	"github.com/rmrfslashbin/tndx/pkg/kinesis"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxTweetDepth is how many retweet/quote hops get_tweet follows from a crawled tweet.
+	DefaultMaxTweetDepth = 2
+
+	// DefaultSeenTweetTTL is how long a tweet queued for get_tweet is not queued again.
+	DefaultSeenTweetTTL = 7 * 24 * time.Hour
+
+	// DefaultMaxThreadDepth is how many in_reply_to hops thread walks up from a crawled reply.
+	DefaultMaxThreadDepth = 10
+
+	// DefaultHydrateUsersTTL is how long a stored profile is not looked up again.
+	DefaultHydrateUsersTTL = 7 * 24 * time.Hour
+)
+
+type Option func(config *Config)
+
+// Config holds the drivers and clients used by the processor functions.
+type Config struct {
+	log             *logrus.Logger
+	twitter         service.Twitter
+	storage         storage.Storage
+	db              database.Database
+	queue           queue.Queue
+	kinesis         kinesis.Sink
+	fetcher         *fetch.Config
+	maxTweetDepth   int
+	seenTweetTTL    time.Duration
+	maxThreadDepth  int
+	hydrateUsersTTL time.Duration
+}
+
+func New(opts ...func(*Config)) *Config {
+	config := &Config{
+		maxTweetDepth:   DefaultMaxTweetDepth,
+		seenTweetTTL:    DefaultSeenTweetTTL,
+		maxThreadDepth:  DefaultMaxThreadDepth,
+		hydrateUsersTTL: DefaultHydrateUsersTTL,
+	}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.log == nil {
+		config.log = logrus.New()
+	}
+	if config.fetcher == nil {
+		config.fetcher = fetch.New(fetch.SetLogger(config.log))
+	}
+
+	return config
+}
+
+func SetLogger(log *logrus.Logger) Option {
+	return func(config *Config) {
+		config.log = log
+	}
+}
+
+func SetTwitter(twitter service.Twitter) Option {
+	return func(config *Config) {
+		config.twitter = twitter
+	}
+}
+
+func SetStorage(storage storage.Storage) Option {
+	return func(config *Config) {
+		config.storage = storage
+	}
+}
+
+func SetDatabase(db database.Database) Option {
+	return func(config *Config) {
+		config.db = db
+	}
+}
+
+func SetQueue(queue queue.Queue) Option {
+	return func(config *Config) {
+		config.queue = queue
+	}
+}
+
+func SetKinesis(kinesis kinesis.Sink) Option {
+	return func(config *Config) {
+		config.kinesis = kinesis
+	}
+}
+
+// SetFetcher sets the media fetcher used by entities. Without one, a fetcher with the
+// default timeout, retries and size limit is used.
+func SetFetcher(fetcher *fetch.Config) Option {
+	return func(config *Config) {
+		config.fetcher = fetcher
+	}
+}
+
+// SetMaxTweetDepth limits how many retweet/quote hops get_tweet follows; 0 fetches none.
+func SetMaxTweetDepth(depth int) Option {
+	return func(config *Config) {
+		config.maxTweetDepth = depth
+	}
+}
+
+// SetMaxThreadDepth limits how many in_reply_to hops thread walks; 0 disables threading.
+func SetMaxThreadDepth(depth int) Option {
+	return func(config *Config) {
+		config.maxThreadDepth = depth
+	}
+}
+
+// SetSeenTweetTTL sets how long a tweet queued for get_tweet is not queued again.
+func SetSeenTweetTTL(ttl time.Duration) Option {
+	return func(config *Config) {
+		config.seenTweetTTL = ttl
+	}
+}
+
+// SetHydrateUsersTTL sets how long a profile stored by hydrate_users is not looked up again.
+func SetHydrateUsersTTL(ttl time.Duration) Option {
+	return func(config *Config) {
+		config.hydrateUsersTTL = ttl
+	}
+}
+
+// Run dispatches message to the processor function named by bootstrap.Function.
+// Errors that redelivery cannot fix are returned as PermanentError. Messages for an endpoint
+// whose rate-limit window is exhausted are re-enqueued with a delay instead of run or failed.
+// Accounts found suspended, deleted or protected have their status recorded instead.
+func (config *Config) Run(bootstrap *queue.Bootstrap, message *queue.ProcessorMessage) error {
+	// Functions reuse bootstrap to enqueue follow-up work, so keep the original function name.
+	function := bootstrap.Function
+
+	if endpoint, ok := functionEndpoints[function]; ok {
+		if limit, ok := config.twitter.RateLimit(endpoint); ok && limit.Exhausted(time.Now()) {
+			return config.deferMessage(function, endpoint, limit.Reset, bootstrap, message)
+		}
+	}
+
+	err := config.dispatch(bootstrap, message)
+	var limited *service.RateLimited
+	if errors.As(err, &limited) {
+		return config.deferMessage(function, limited.Endpoint, limited.Reset, bootstrap, message)
+	}
+	// A suspended, deleted or protected account fails the same way every run; record it and
+	// pause the user's runner flags instead of failing the message.
+	if status := accountStatus(err); status != "" && accountFunctions[function] && message.UserID != 0 {
+		return config.setAccountStatus(message.UserID, status)
+	}
+	return err
+}
+
+func (config *Config) dispatch(bootstrap *queue.Bootstrap, message *queue.ProcessorMessage) error {
+	switch bootstrap.Function {
+	case "entities":
+		if err := config.entities(&message.UserID, &message.TweetID, &message.EntityURL, message.MediaType); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "entities",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	case "favorites":
+		if err := config.favorites(message.UserID, message.MaxID, bootstrap); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "favorites",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	case "followers":
+		if err := config.followers(message.UserID, message.Cursor, bootstrap); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "followers",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	case "friends":
+		if err := config.friends(message.UserID, message.Cursor, bootstrap); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "friends",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	case "get_tweet":
+		// Older messages carry a single TweetID; batches carry TweetIDs.
+		tweetIDs := message.TweetIDs
+		if message.TweetID != "" {
+			tweetId, err := strconv.ParseInt(message.TweetID, 10, 64)
+			if err != nil {
+				config.log.WithFields(logrus.Fields{
+					"function": "get_tweet",
+					"error":    err,
+				}).Error("unable to parse tweet id to int64")
+				return Permanent(err)
+			}
+			tweetIDs = append(tweetIDs, tweetId)
+		}
+		if err := config.getTweets(tweetIDs, message.Depth, bootstrap); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "get_tweet",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	case "hydrate_users":
+		if err := config.hydrateUsers(message.UserIDs); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "hydrate_users",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	case "thread":
+		tweetId, err := strconv.ParseInt(message.TweetID, 10, 64)
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "thread",
+				"error":    err,
+			}).Error("unable to parse tweet id to int64")
+			return Permanent(err)
+		}
+		if err := config.thread(tweetId, bootstrap); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "thread",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	case "timeline":
+		if err := config.timeline(message.UserID, bootstrap); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "timeline",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	case "timeline_backfill":
+		if err := config.timelineBackfill(message.UserID, bootstrap); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "timeline_backfill",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	case "timeline_gap":
+		if err := config.fillGap("tweets", message.UserID, message.SinceID, message.MaxID, bootstrap); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "timeline_gap",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	case "user":
+		if err := config.user(message.UserID); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"function": "user",
+				"error":    err,
+			}).Error("function failed")
+			return err
+		}
+
+	default:
+		config.log.WithFields(logrus.Fields{
+			"function": bootstrap.Function,
+		}).Error("invalid function; should be one of user, friend, followers, favorites, timeline, timeline_backfill, timeline_gap, get_tweet, thread, hydrate_users, entities")
+		return Permanent(errors.New("invalid function; should be one of user, friend, followers, favorites, timeline, timeline_backfill, timeline_gap, get_tweet, thread, hydrate_users, entities"))
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package processor
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/fetch"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestConfig returns a processor backed by a bolt database and local storage in a temp dir.
+func newTestConfig(t *testing.T, opts ...func(*Config)) (*Config, *database.BoltDriver, *storage.LocalStorage) {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	db := database.NewBolt(database.SetBoltPath(filepath.Join(t.TempDir(), "tndx.db")), database.SetBoltLogger(log))
+	t.Cleanup(func() { db.Close() })
+	store := storage.NewLocalStorage(storage.SetLocalDirectory(t.TempDir()), storage.SetLocalLogger(log))
+
+	opts = append([]func(*Config){
+		SetLogger(log),
+		SetDatabase(db),
+		SetStorage(store),
+		SetFetcher(fetch.New(fetch.SetLogger(log), fetch.SetRetries(0))),
+	}, opts...)
+	return New(opts...), db, store
+}
@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// maxDelay is the longest delay SQS accepts on a message. Windows that reset later than
+// this are waited out over several deferrals.
+const maxDelay = 900 * time.Second
+
+// functionEndpoints maps each processor function to the Twitter endpoint it calls.
+var functionEndpoints = map[string]string{
+	"favorites":         service.EndpointFavoritesList,
+	"followers":         service.EndpointFollowersIDs,
+	"friends":           service.EndpointFriendsIDs,
+	"get_tweet":         service.EndpointStatusesLookup,
+	"hydrate_users":     service.EndpointUsersLookup,
+	"thread":            service.EndpointStatusesLookup,
+	"timeline":          service.EndpointUserTimeline,
+	"timeline_backfill": service.EndpointUserTimeline,
+	"timeline_gap":      service.EndpointUserTimeline,
+	"user":              service.EndpointUsersShow,
+}
+
+// deferDelay returns the SQS delay, in seconds, that holds a message until reset.
+func deferDelay(reset time.Time, now time.Time) int32 {
+	delay := reset.Sub(now).Truncate(time.Second) + time.Second
+	if delay < time.Second {
+		delay = time.Second
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return int32(delay / time.Second)
+}
+
+// deferMessage re-enqueues message for function with a delay until reset.
+func (config *Config) deferMessage(function string, endpoint string, reset time.Time, bootstrap *queue.Bootstrap, message *queue.ProcessorMessage) error {
+	bootstrap.Function = function
+	delay := deferDelay(reset, time.Now())
+	if err := config.queue.SendRunnerMessage(&queue.SendMessage{
+		Bootstrap:    bootstrap,
+		Message:      message,
+		DelaySeconds: delay,
+	}); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":   "deferMessage::queue::SendRunnerMessage",
+			"error":    err.Error(),
+			"function": function,
+		}).Error("error sending message to queue")
+		return err
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":   "deferMessage",
+		"function": function,
+		"endpoint": endpoint,
+		"reset":    reset.Format(time.RFC3339),
+		"delay":    delay,
+	}).Warn("rate limited; deferred message until the window resets")
+	return nil
+}
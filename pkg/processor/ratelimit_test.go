@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/faketwitter"
+	"github.com/rmrfslashbin/tndx/pkg/kinesis"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+)
+
+func TestDeferDelay(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+
+	tests := []struct {
+		name  string
+		reset time.Time
+		want  int32
+	}{
+		{"whole seconds", now.Add(90 * time.Second), 91},
+		{"partial second", now.Add(90*time.Second + 500*time.Millisecond), 91},
+		{"just under the cap", now.Add(899 * time.Second), 900},
+		{"at the cap", now.Add(900 * time.Second), 900},
+		{"past the cap", now.Add(time.Hour), 900},
+		{"now", now, 1},
+		{"in the past", now.Add(-time.Minute), 1},
+		{"zero", time.Time{}, 1},
+	}
+	for _, test := range tests {
+		if got := deferDelay(test.reset, now); got != test.want {
+			t.Errorf("%s: deferDelay = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+// recordingQueue keeps every message sent to it, including its delay.
+type recordingQueue struct {
+	sent []*queue.SendMessage
+}
+
+func (q *recordingQueue) SendRunnerMessage(params *queue.SendMessage) error {
+	bootstrap := *params.Bootstrap
+	message := *params.Message
+	q.sent = append(q.sent, &queue.SendMessage{Bootstrap: &bootstrap, Message: &message, DelaySeconds: params.DelaySeconds})
+	return nil
+}
+
+func TestRunDefersRateLimitedMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		// remaining is what the fake allows before the run; the run under test is made after
+		// one warm-up call when remaining is 1, so only the client's own tracking stops it.
+		remaining int
+	}{
+		{"after a 429", 0},
+		{"up front when exhausted", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := faketwitter.New()
+			fake.Start()
+			defer fake.Close()
+
+			user := &twitter.User{ID: 1, ScreenName: "one"}
+			fake.AddUser(*user)
+			fake.AddTweet(twitter.Tweet{ID: 101, User: user, Text: "first"})
+
+			sink := kinesis.NewLocal(kinesis.SetLocalPath(filepath.Join(t.TempDir(), "tweets.json")))
+			sent := &recordingQueue{}
+			config, _, _ := newTestConfig(t,
+				SetTwitter(service.New(
+					service.SetConsumerKey("key"),
+					service.SetConsumerSecret("secret"),
+					service.SetBaseURL(fake.URL()),
+				)),
+				SetKinesis(sink),
+				SetQueue(sent),
+			)
+
+			reset := time.Now().Add(2 * time.Minute)
+			fake.SetRateLimit(faketwitter.EndpointUserTimeline, test.remaining, reset)
+			if test.remaining > 0 {
+				if err := config.Run(&queue.Bootstrap{Function: "timeline"}, &queue.ProcessorMessage{UserID: 1}); err != nil {
+					t.Fatalf("warm-up Run(timeline): %v", err)
+				}
+				// The server would allow more calls now, but the client saw the window run out.
+				fake.SetRateLimit(faketwitter.EndpointUserTimeline, 100, reset)
+				fake.AddTweet(twitter.Tweet{ID: 102, User: user, Text: "second"})
+			}
+			records := sink.Records()
+			sent.sent = nil
+
+			if err := config.Run(&queue.Bootstrap{Function: "timeline"}, &queue.ProcessorMessage{UserID: 1}); err != nil {
+				t.Fatalf("Run(timeline): %v", err)
+			}
+
+			if got := sink.Records(); got != records {
+				t.Errorf("got %d new delivery stream records, want none", got-records)
+			}
+			if len(sent.sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(sent.sent))
+			}
+			deferred := sent.sent[0]
+			if deferred.Bootstrap.Function != "timeline" || deferred.Message.UserID != 1 {
+				t.Errorf("deferred %s for user %d, want timeline for user 1", deferred.Bootstrap.Function, deferred.Message.UserID)
+			}
+			// The reset header is in whole seconds, so allow for truncation and a slow run.
+			if deferred.DelaySeconds < 118 || deferred.DelaySeconds > 121 {
+				t.Errorf("DelaySeconds = %d, want about 120", deferred.DelaySeconds)
+			}
+		})
+	}
+}
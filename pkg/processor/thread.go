@@ -0,0 +1,140 @@
+package processor
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// enqueueThreads sends a thread message for each reply in ids. Errors are logged; the replies
+// are already stored.
+func (config *Config) enqueueThreads(action string, ids []int64, bootstrap *queue.Bootstrap) {
+	for _, id := range ids {
+		bootstrap.Function = "thread"
+		if err := config.queue.SendRunnerMessage(&queue.SendMessage{
+			Bootstrap: bootstrap,
+			Message: &queue.ProcessorMessage{
+				TweetID: strconv.FormatInt(id, 10),
+			},
+		}); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action":  action + "::queue::SendRunnerMessage::thread",
+				"error":   err.Error(),
+				"tweetId": id,
+			}).Error("error sending message to queue")
+		}
+	}
+}
+
+// thread walks the in_reply_to chain up from tweetID, at most maxThreadDepth hops, and records
+// the reply and its ancestors as a conversation. The walk stops early at a tweet that is already
+// part of a conversation and joins it. Ancestors fetched on the way are archived.
+func (config *Config) thread(tweetID int64, bootstrap *queue.Bootstrap) error {
+	if known, err := config.db.GetConversationsByTweetId(tweetID); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":  "thread::GetConversationsByTweetId",
+			"error":   err.Error(),
+			"tweetId": tweetID,
+		}).Error("error getting conversations")
+		return err
+	} else if len(known) > 0 {
+		config.log.WithFields(logrus.Fields{
+			"action":         "thread",
+			"tweetId":        tweetID,
+			"conversationId": known[0].ConversationID,
+		}).Debug("tweet already in a conversation")
+		return nil
+	}
+
+	var (
+		items          []*database.ConversationItem
+		ancestors      []twitter.Tweet
+		conversationID int64
+	)
+	current := tweetID
+	for hops := 0; ; hops++ {
+		tweets, _, err := config.twitter.LookupTweets([]int64{current})
+		var notFound *service.NotFound
+		if err != nil && !errors.As(err, &notFound) {
+			config.log.WithFields(logrus.Fields{
+				"action":  "thread::LookupTweets",
+				"error":   err.Error(),
+				"tweetId": current,
+			}).Error("error getting tweet")
+			return classify(err)
+		}
+		if len(tweets) == 0 {
+			// A deleted or protected parent ends the walk at the last tweet fetched.
+			config.log.WithFields(logrus.Fields{
+				"action":  "thread::LookupTweets",
+				"tweetId": current,
+			}).Warn("tweet not returned; thread ends here")
+			if len(items) == 0 {
+				return nil
+			}
+			conversationID = items[len(items)-1].TweetID
+			break
+		}
+
+		tweet := tweets[0]
+		item := &database.ConversationItem{TweetID: tweet.ID, ParentID: tweet.InReplyToStatusID}
+		if tweet.User != nil {
+			item.UserID = tweet.User.ID
+		}
+		items = append(items, item)
+		if hops > 0 {
+			ancestors = append(ancestors, tweet)
+		}
+
+		if tweet.InReplyToStatusID == 0 || hops >= config.maxThreadDepth {
+			conversationID = tweet.ID
+			break
+		}
+
+		known, err := config.db.GetConversationsByTweetId(tweet.InReplyToStatusID)
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action":  "thread::GetConversationsByTweetId",
+				"error":   err.Error(),
+				"tweetId": tweet.InReplyToStatusID,
+			}).Error("error getting conversations")
+			return err
+		}
+		if len(known) > 0 {
+			conversationID = known[0].ConversationID
+			break
+		}
+		current = tweet.InReplyToStatusID
+	}
+
+	for _, item := range items {
+		item.ConversationID = conversationID
+	}
+	if err := config.db.PutConversation(items); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":         "thread::PutConversation",
+			"error":          err.Error(),
+			"conversationId": conversationID,
+		}).Error("error putting conversation")
+		return err
+	}
+
+	// Ancestors count as one get_tweet hop from the crawled reply.
+	if _, _, err := config.putTweets("thread", 0, 1, ancestors, bootstrap); err != nil {
+		return err
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":         "thread::Done!",
+		"tweetId":        tweetID,
+		"conversationId": conversationID,
+		"count":          len(items),
+	}).Info("finished threading tweet")
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/faketwitter"
+	"github.com/rmrfslashbin/tndx/pkg/kinesis"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+)
+
+func TestThread(t *testing.T) {
+	user := &twitter.User{ID: 1, ScreenName: "one"}
+	// reply builds tweet id replying to parent; parent 0 is a thread root.
+	reply := func(id int64, parent int64) twitter.Tweet {
+		return twitter.Tweet{ID: id, User: user, Text: "reply", InReplyToStatusID: parent}
+	}
+
+	tests := []struct {
+		name     string
+		tweets   []twitter.Tweet
+		depth    int
+		existing []*database.ConversationItem
+		tweetID  int64
+		// conversation is the ConversationID recorded for tweets, nearest the reply first.
+		conversation int64
+		threaded     []int64
+		records      int64
+	}{
+		{
+			name:         "walks to the root",
+			tweets:       []twitter.Tweet{reply(1, 0), reply(2, 1), reply(3, 2), reply(4, 3)},
+			depth:        DefaultMaxThreadDepth,
+			tweetID:      4,
+			conversation: 1,
+			threaded:     []int64{1, 2, 3, 4},
+			records:      3,
+		},
+		{
+			name:         "stops at the depth limit",
+			tweets:       []twitter.Tweet{reply(1, 0), reply(2, 1), reply(3, 2), reply(4, 3)},
+			depth:        2,
+			tweetID:      4,
+			conversation: 2,
+			threaded:     []int64{2, 3, 4},
+			records:      2,
+		},
+		{
+			name:   "joins an existing conversation",
+			tweets: []twitter.Tweet{reply(1, 0), reply(2, 1), reply(3, 2), reply(4, 3)},
+			depth:  DefaultMaxThreadDepth,
+			existing: []*database.ConversationItem{
+				{ConversationID: 1, TweetID: 1, UserID: 1},
+				{ConversationID: 1, TweetID: 2, ParentID: 1, UserID: 1},
+			},
+			tweetID:      4,
+			conversation: 1,
+			threaded:     []int64{3, 4},
+			records:      1,
+		},
+		{
+			name:         "deleted parent ends the walk",
+			tweets:       []twitter.Tweet{reply(1, 0), reply(3, 2), reply(4, 3)},
+			depth:        DefaultMaxThreadDepth,
+			tweetID:      4,
+			conversation: 3,
+			threaded:     []int64{3, 4},
+			records:      1,
+		},
+		{
+			name:     "deleted reply",
+			tweets:   []twitter.Tweet{reply(1, 0)},
+			depth:    DefaultMaxThreadDepth,
+			tweetID:  4,
+			threaded: nil,
+		},
+		{
+			name:     "already threaded",
+			tweets:   []twitter.Tweet{reply(1, 0), reply(2, 1)},
+			depth:    DefaultMaxThreadDepth,
+			existing: []*database.ConversationItem{{ConversationID: 1, TweetID: 2, ParentID: 1, UserID: 1}},
+			tweetID:  2,
+			threaded: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := faketwitter.New()
+			fake.Start()
+			defer fake.Close()
+			fake.AddUser(*user)
+			for _, tweet := range test.tweets {
+				fake.AddTweet(tweet)
+			}
+
+			sink := kinesis.NewLocal(kinesis.SetLocalPath(filepath.Join(t.TempDir(), "tweets.json")))
+			config, db, _ := newTestConfig(t,
+				SetTwitter(service.New(
+					service.SetConsumerKey("key"),
+					service.SetConsumerSecret("secret"),
+					service.SetBaseURL(fake.URL()),
+				)),
+				SetKinesis(sink),
+				SetQueue(queue.NewLocal()),
+				SetMaxThreadDepth(test.depth),
+			)
+			if len(test.existing) > 0 {
+				if err := db.PutConversation(test.existing); err != nil {
+					t.Fatalf("PutConversation: %v", err)
+				}
+			}
+
+			if err := config.thread(test.tweetID, &queue.Bootstrap{}); err != nil {
+				t.Fatalf("thread: %v", err)
+			}
+
+			var threaded []int64
+			for _, tweetID := range []int64{1, 2, 3, 4} {
+				items, err := db.GetConversationsByTweetId(tweetID)
+				if err != nil {
+					t.Fatalf("GetConversationsByTweetId(%d): %v", tweetID, err)
+				}
+				for _, item := range items {
+					if isExisting(test.existing, item.TweetID) {
+						continue
+					}
+					threaded = append(threaded, item.TweetID)
+					if item.ConversationID != test.conversation {
+						t.Errorf("tweet %d is in conversation %d, want %d", item.TweetID, item.ConversationID, test.conversation)
+					}
+				}
+			}
+			sort.Slice(threaded, func(i, j int) bool { return threaded[i] < threaded[j] })
+			if !reflect.DeepEqual(threaded, test.threaded) {
+				t.Errorf("threaded tweets %v, want %v", threaded, test.threaded)
+			}
+
+			// Ancestors fetched on the way are archived; the reply itself already was.
+			if records := sink.Records(); records != test.records {
+				t.Errorf("got %d delivery stream records, want %d", records, test.records)
+			}
+		})
+	}
+}
+
+// isExisting reports whether tweetID was threaded before the test ran.
+func isExisting(existing []*database.ConversationItem, tweetID int64) bool {
+	for _, item := range existing {
+		if item.TweetID == tweetID {
+			return true
+		}
+	}
+	return false
+}
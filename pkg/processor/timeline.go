@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+func (config *Config) timeline(userid int64, bootstrap *queue.Bootstrap) error {
+	timelineConfig, err := config.db.GetTimelineConfig(userid)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "timeline::GetTimelineConfig",
+			"error":  err.Error(),
+		}).Error("error getting timeline config")
+		return err
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":  "timeline::Setup",
+		"userid":  userid,
+		"sinceid": timelineConfig.MaxID,
+	}).Debug("setting up timeline")
+
+	tweets, _, err := config.twitter.GetUserTimeline(
+		&service.QueryParams{
+			UserID:  userid,
+			Count:   200,
+			SinceID: timelineConfig.MaxID,
+		},
+	)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "timeline::GetUserTimeline",
+			"error":  err,
+		}).Error("error getting user's timeline")
+		return classify(err)
+	}
+
+	upperID, lowerID, err := config.putTweets("timeline", userid, 0, tweets, bootstrap)
+	if err != nil {
+		return err
+	}
+
+	if err := config.openGap("tweets", userid, timelineConfig.MaxID, lowerID, len(tweets), bootstrap); err != nil {
+		return err
+	}
+
+	if upperID > 0 {
+		if err := config.db.PutTimelineConfig(
+			&database.TweetConfigQuery{
+				UserID:  userid,
+				SinceID: lowerID,
+				MaxID:   upperID,
+			},
+		); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action":  "timeline::PutTimelineConfig",
+				"error":   err.Error(),
+				"userid":  userid,
+				"upperID": upperID,
+				"lowerID": lowerID,
+			}).Error("error putting timeline config")
+			return err
+		}
+	}
+
+	// The first run only sees the newest page; walk the rest of the history in the background.
+	if timelineConfig.MaxID == 0 && lowerID > 0 {
+		if err := config.seedTimelineBackfill(userid, lowerID, bootstrap); err != nil {
+			return err
+		}
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":  "timeline::Done!",
+		"userid":  userid,
+		"upperID": upperID,
+		"lowerID": lowerID,
+		"count":   len(tweets),
+	}).Info("finished getting timeline")
+
+	return nil
+}
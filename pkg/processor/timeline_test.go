@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/faketwitter"
+	"github.com/rmrfslashbin/tndx/pkg/kinesis"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+)
+
+func TestTimelineAgainstFakeTwitter(t *testing.T) {
+	fake := faketwitter.New()
+	fake.Start()
+	defer fake.Close()
+
+	user := &twitter.User{ID: 1, ScreenName: "one"}
+	fake.AddUser(*user)
+	fake.AddUser(twitter.User{ID: 2, ScreenName: "two"})
+	original := twitter.Tweet{ID: 50, User: &twitter.User{ID: 2}, Text: "original"}
+	fake.AddTweet(original)
+	fake.AddTweet(twitter.Tweet{ID: 101, User: user, Text: "first"})
+	fake.AddTweet(twitter.Tweet{ID: 102, User: user, Text: "retweet", RetweetedStatus: &original})
+	fake.AddTweet(twitter.Tweet{ID: 103, User: user, Text: "photo", Entities: &twitter.Entities{
+		Media: []twitter.MediaEntity{{MediaURLHttps: "https://pbs.twimg.com/media/abc.jpg", Type: "photo"}},
+	}})
+
+	sink := kinesis.NewLocal(kinesis.SetLocalPath(filepath.Join(t.TempDir(), "tweets.json")))
+	runner := queue.NewLocal()
+	config, db, _ := newTestConfig(t,
+		SetTwitter(service.New(
+			service.SetConsumerKey("key"),
+			service.SetConsumerSecret("secret"),
+			service.SetBaseURL(fake.URL()),
+		)),
+		SetKinesis(sink),
+		SetQueue(runner),
+	)
+
+	if err := config.Run(&queue.Bootstrap{Function: "timeline"}, &queue.ProcessorMessage{UserID: 1}); err != nil {
+		t.Fatalf("Run(timeline): %v", err)
+	}
+
+	if records := sink.Records(); records != 3 {
+		t.Errorf("got %d delivery stream records, want 3", records)
+	}
+
+	timelineConfig, err := db.GetTimelineConfig(1)
+	if err != nil {
+		t.Fatalf("GetTimelineConfig: %v", err)
+	}
+	if timelineConfig.MaxID != 103 || timelineConfig.SinceID != 101 {
+		t.Errorf("timeline config = %d..%d, want 101..103", timelineConfig.SinceID, timelineConfig.MaxID)
+	}
+
+	queued := map[string][]*queue.ProcessorMessage{}
+	for {
+		message, ok := runner.Receive()
+		if !ok {
+			break
+		}
+		queued[message.Bootstrap.Function] = append(queued[message.Bootstrap.Function], message.Message)
+	}
+	if got := queued["get_tweet"]; len(got) != 1 || len(got[0].TweetIDs) != 1 || got[0].TweetIDs[0] != 50 {
+		t.Errorf("get_tweet messages = %+v, want one for tweet 50", got)
+	}
+	if got := queued["entities"]; len(got) != 1 || got[0].TweetID != "103" || got[0].UserID != 1 {
+		t.Errorf("entities messages = %+v, want one for tweet 103", got)
+	}
+
+	// Queued and crawled tweets are both marked seen.
+	for _, tweetID := range []int64{50, 101, 102, 103} {
+		if item, err := db.GetSeenTweet(tweetID); err != nil || item.TweetID != tweetID {
+			t.Errorf("GetSeenTweet(%d) = %+v, %v; want a seen mark", tweetID, item, err)
+		}
+	}
+
+	// A second run asks only for tweets newer than the stored MaxID.
+	if err := config.Run(&queue.Bootstrap{Function: "timeline"}, &queue.ProcessorMessage{UserID: 1}); err != nil {
+		t.Fatalf("Run(timeline) again: %v", err)
+	}
+	if records := sink.Records(); records != 3 {
+		t.Errorf("got %d delivery stream records after the second run, want 3", records)
+	}
+}
@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"errors"
+
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// lookupBatchSize is the most tweet IDs statuses/lookup accepts per call.
+const lookupBatchSize = 100
+
+// getTweets looks up a batch of tweet IDs with one statuses/lookup call and stores the tweets
+// returned. IDs Twitter doesn't return (deleted, protected or suspended) are reported one by one.
+func (config *Config) getTweets(tweetIDs []int64, depth int, bootstrap *queue.Bootstrap) error {
+	if len(tweetIDs) == 0 || len(tweetIDs) > lookupBatchSize {
+		return Permanent(errors.New("get_tweet needs 1 to 100 tweet ids"))
+	}
+
+	tweets, _, err := config.twitter.LookupTweets(tweetIDs)
+	// statuses/lookup answers not found when none of the tweets exist.
+	var notFound *service.NotFound
+	if err != nil && !errors.As(err, &notFound) {
+		config.log.WithFields(logrus.Fields{
+			"action":   "getTweets::svc.twitterClient.LookupTweets",
+			"tweetIds": tweetIDs,
+			"error":    err.Error(),
+		}).Error("error getting tweets")
+		return classify(err)
+	}
+
+	returned := make(map[int64]bool, len(tweets))
+	for t := range tweets {
+		returned[tweets[t].ID] = true
+	}
+	missing := 0
+	for _, tweetID := range tweetIDs {
+		if !returned[tweetID] {
+			missing++
+			config.log.WithFields(logrus.Fields{
+				"action":  "getTweets::LookupTweets",
+				"tweetId": tweetID,
+			}).Warn("tweet not returned")
+		}
+	}
+
+	if _, _, err := config.putTweets("getTweets", 0, depth, tweets, bootstrap); err != nil {
+		return err
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action":    "get_tweet::Done!",
+		"requested": len(tweetIDs),
+		"count":     len(tweets),
+		"missing":   missing,
+	}).Info("finished getting tweets")
+
+	return nil
+}
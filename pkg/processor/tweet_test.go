@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/faketwitter"
+	"github.com/rmrfslashbin/tndx/pkg/kinesis"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+)
+
+// lookupNotFound answers every statuses/lookup the way Twitter does when none of the tweets exist.
+type lookupNotFound struct {
+	service.Twitter
+}
+
+func (lookupNotFound) LookupTweets(ids []int64) ([]twitter.Tweet, *http.Response, error) {
+	return nil, nil, &service.NotFound{Endpoint: service.EndpointStatusesLookup}
+}
+
+func TestEnqueueGetTweetsBatches(t *testing.T) {
+	runner := queue.NewLocal()
+	config, _, _ := newTestConfig(t, SetQueue(runner))
+
+	ids := make([]int64, 250)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	if err := config.enqueueGetTweets("test", ids, 1, &queue.Bootstrap{}); err != nil {
+		t.Fatalf("enqueueGetTweets: %v", err)
+	}
+
+	var sizes []int
+	next := int64(1)
+	for {
+		message, ok := runner.Receive()
+		if !ok {
+			break
+		}
+		if message.Bootstrap.Function != "get_tweet" || message.Message.Depth != 1 {
+			t.Errorf("queued %s at depth %d, want get_tweet at depth 1", message.Bootstrap.Function, message.Message.Depth)
+		}
+		for _, id := range message.Message.TweetIDs {
+			if id != next {
+				t.Fatalf("batch has tweet %d, want %d", id, next)
+			}
+			next++
+		}
+		sizes = append(sizes, len(message.Message.TweetIDs))
+	}
+	if len(sizes) != 3 || sizes[0] != 100 || sizes[1] != 100 || sizes[2] != 50 {
+		t.Errorf("batch sizes = %v, want [100 100 50]", sizes)
+	}
+}
+
+func TestGetTweets(t *testing.T) {
+	fake := faketwitter.New()
+	fake.Start()
+	defer fake.Close()
+
+	user := &twitter.User{ID: 1, ScreenName: "one"}
+	fake.AddUser(*user)
+	fake.AddTweet(twitter.Tweet{ID: 10, User: user, Text: "ten"})
+	fake.AddTweet(twitter.Tweet{ID: 11, User: user, Text: "eleven"})
+
+	tooMany := make([]int64, lookupBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = int64(i + 1)
+	}
+
+	tests := []struct {
+		name      string
+		twitter   service.Twitter
+		ids       []int64
+		records   int64
+		permanent bool
+	}{
+		{"all found", nil, []int64{10, 11}, 2, false},
+		{"partly missing", nil, []int64{10, 12, 11, 13}, 2, false},
+		{"all missing", nil, []int64{12}, 0, false},
+		{"not found", lookupNotFound{}, []int64{12}, 0, false},
+		{"no ids", nil, nil, 0, true},
+		{"more than a batch", nil, tooMany, 0, true},
+	}
+	for _, test := range tests {
+		client := test.twitter
+		if client == nil {
+			client = service.New(
+				service.SetConsumerKey("key"),
+				service.SetConsumerSecret("secret"),
+				service.SetBaseURL(fake.URL()),
+			)
+		}
+		sink := kinesis.NewLocal(kinesis.SetLocalPath(filepath.Join(t.TempDir(), "tweets.json")))
+		config, _, _ := newTestConfig(t, SetTwitter(client), SetKinesis(sink), SetQueue(queue.NewLocal()))
+
+		err := config.getTweets(test.ids, 1, &queue.Bootstrap{})
+		if test.permanent {
+			if !IsPermanent(err) {
+				t.Errorf("%s: getTweets error = %v, want permanent", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: getTweets: %v", test.name, err)
+		}
+		if records := sink.Records(); records != test.records {
+			t.Errorf("%s: got %d delivery stream records, want %d", test.name, records, test.records)
+		}
+	}
+}
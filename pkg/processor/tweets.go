@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// putTweets sends each tweet to the delivery stream and enqueues get_tweet messages for
+// retweeted/quoted statuses, thread messages for crawled replies and entities messages for
+// attached media. Media is filed under userid, or under the tweet's author when userid is 0.
+// depth is the number of get_tweet hops that led to tweets; crawled tweets are depth 0. It
+// returns the highest and lowest tweet IDs seen.
+func (config *Config) putTweets(action string, userid int64, depth int, tweets []twitter.Tweet, bootstrap *queue.Bootstrap) (upperID int64, lowerID int64, err error) {
+	// Retweeted and quoted tweets to fetch, batched once every tweet is stored, and crawled
+	// replies whose threads to walk.
+	var related, replies []int64
+	following := make(map[int64]bool)
+
+	// Loop through all the tweets.
+	for t := range tweets {
+		config.log.WithFields(logrus.Fields{
+			"action":  action,
+			"tweetId": tweets[t].ID,
+		}).Info("base tweet")
+		if data, err := json.Marshal(tweets[t]); err == nil {
+			if opt, err := config.kinesis.PutRecord(data); err != nil {
+				config.log.WithFields(logrus.Fields{
+					"action":  action + "::kinesis::PutRecord",
+					"error":   err,
+					"tweetId": tweets[t].ID,
+				}).Error("failed putting tweet into kinesis")
+				return upperID, lowerID, err
+			} else {
+				config.log.WithFields(logrus.Fields{
+					"tweetId":  tweets[t].ID,
+					"recordId": *opt.RecordId,
+				}).Info("put record")
+			}
+		}
+
+		// check for RetweetedStatus
+		if tweets[t].RetweetedStatus != nil && !following[tweets[t].RetweetedStatus.ID] && config.followTweet(action+"::RetweetedStatus", tweets[t].RetweetedStatus.ID, depth+1) {
+			related = append(related, tweets[t].RetweetedStatus.ID)
+			following[tweets[t].RetweetedStatus.ID] = true
+		}
+
+		// check for quoted_status_id
+		if tweets[t].QuotedStatusID != 0 && !following[tweets[t].QuotedStatusID] && config.followTweet(action+"::QuotedStatusIDStr", tweets[t].QuotedStatusID, depth+1) {
+			related = append(related, tweets[t].QuotedStatusID)
+			following[tweets[t].QuotedStatusID] = true
+		}
+
+		if tweets[t].InReplyToStatusID != 0 && depth == 0 && config.maxThreadDepth > 0 {
+			replies = append(replies, tweets[t].ID)
+		}
+
+		owner := userid
+		if owner == 0 && tweets[t].User != nil {
+			owner = tweets[t].User.ID
+		}
+
+		// Loop through all the media entities
+		for _, media := range service.TweetMedia(&tweets[t]) {
+			bootstrap.Function = "entities"
+			if err := config.queue.SendRunnerMessage(&queue.SendMessage{
+				Bootstrap: bootstrap,
+				Message: &queue.ProcessorMessage{
+					TweetID:   tweets[t].IDStr,
+					EntityURL: media.URL,
+					MediaType: media.Type,
+					UserID:    owner,
+				},
+			}); err != nil {
+				config.log.WithFields(logrus.Fields{
+					"action":    action + "::queue::SendRunnerMessage::entities",
+					"error":     err.Error(),
+					"userid":    owner,
+					"tweetId":   tweets[t].ID,
+					"mediaType": media.Type,
+				}).Error("error sending message to queue")
+			}
+		}
+
+		// Calculate the max and min tweet IDs.
+		if tweets[t].ID > upperID {
+			upperID = tweets[t].ID
+		}
+		if lowerID == 0 || tweets[t].ID < lowerID {
+			lowerID = tweets[t].ID
+		}
+	}
+
+	// Crawled tweets are already stored, so a retweet or quote of one needn't fetch it again.
+	if depth == 0 {
+		for t := range tweets {
+			config.markTweetSeen(action, tweets[t].ID)
+		}
+	}
+
+	if err := config.enqueueGetTweets(action, related, depth+1, bootstrap); err != nil {
+		return upperID, lowerID, err
+	}
+	config.enqueueThreads(action, replies, bootstrap)
+
+	return upperID, lowerID, nil
+}
+
+// followTweet reports whether a retweeted or quoted tweet found depth hops from a crawled
+// tweet should be fetched. Tweets past the maximum depth, or already seen within the
+// seen-tweet TTL, are skipped. Checking the seen mark fails open.
+func (config *Config) followTweet(action string, tweetID int64, depth int) bool {
+	if depth > config.maxTweetDepth {
+		config.log.WithFields(logrus.Fields{
+			"action":  action + "::get_tweet",
+			"tweetId": tweetID,
+			"depth":   depth,
+		}).Debug("max tweet depth reached; not fetching")
+		return false
+	}
+
+	if item, err := config.db.GetSeenTweet(tweetID); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":  action + "::db::GetSeenTweet",
+			"error":   err.Error(),
+			"tweetId": tweetID,
+		}).Warn("unable to check if tweet was seen; fetching anyway")
+	} else if item.Expires >= time.Now().Unix() {
+		config.log.WithFields(logrus.Fields{
+			"action":  action + "::get_tweet",
+			"tweetId": tweetID,
+		}).Debug("tweet already seen; not fetching")
+		return false
+	}
+	return true
+}
+
+// markTweetSeen marks tweetID seen for the seen-tweet TTL. Failures are logged; the tweet may
+// then be fetched again.
+func (config *Config) markTweetSeen(action string, tweetID int64) {
+	if _, err := config.db.PutSeenTweet(tweetID, config.seenTweetTTL); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":  action + "::db::PutSeenTweet",
+			"error":   err.Error(),
+			"tweetId": tweetID,
+		}).Warn("unable to mark tweet seen")
+	}
+}
+
+// enqueueGetTweets splits ids into statuses/lookup sized batches and sends a get_tweet message
+// for each. The tweets of a batch are marked seen once it is sent, so a failed send is retried
+// with the message that referenced them.
+func (config *Config) enqueueGetTweets(action string, ids []int64, depth int, bootstrap *queue.Bootstrap) error {
+	for start := 0; start < len(ids); start += lookupBatchSize {
+		end := start + lookupBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		bootstrap.Function = "get_tweet"
+		if err := config.queue.SendRunnerMessage(&queue.SendMessage{
+			Bootstrap: bootstrap,
+			Message: &queue.ProcessorMessage{
+				TweetIDs: ids[start:end],
+				Depth:    depth,
+			},
+		}); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action": action + "::queue::SendRunnerMessage::get_tweet",
+				"error":  err.Error(),
+				"count":  end - start,
+			}).Error("error sending message to queue")
+			return err
+		}
+		for _, id := range ids[start:end] {
+			config.markTweetSeen(action, id)
+		}
+	}
+	return nil
+}
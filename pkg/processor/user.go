@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"encoding/json"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+func (config *Config) user(userid int64) error {
+	user, _, err := config.twitter.GetUser(&service.QueryParams{UserID: userid})
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "user::GetUser",
+			"userid": userid,
+			"error":  err.Error(),
+		}).Error("error getting user.")
+		return classify(err)
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"action": "user::GetUser",
+		"userid": userid,
+	}).Info("got user.")
+
+	if data, err := json.Marshal(user); err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "user::GetUser",
+			"userid": userid,
+			"error":  err.Error(),
+		}).Error("error marshalling user.")
+		return err
+	} else {
+		if err := config.storage.Put(storage.UserKey(user.IDStr), data); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action": "user::GetUser",
+				"userid": userid,
+				"error":  err.Error(),
+			}).Error("error storing user.")
+			return err
+		} else {
+			config.log.WithFields(logrus.Fields{
+				"action": "user::GetUser::Storage::Put",
+				"userid": userid,
+			}).Info("stored user.")
+		}
+	}
+
+	status := database.AccountActive
+	if user.Protected && !user.Following {
+		status = database.AccountProtected
+	}
+	return config.setAccountStatus(userid, status)
+}
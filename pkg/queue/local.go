@@ -0,0 +1,110 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type LocalOption func(config *Local)
+
+// Local is an in-process FIFO stand-in for the SQS runner queue.
+type Local struct {
+	log      *logrus.Logger
+	mu       sync.Mutex
+	messages []*localMessage
+}
+
+// localMessage is a queued message and the time it becomes visible.
+type localMessage struct {
+	message *SendMessage
+	due     time.Time
+}
+
+func NewLocal(opts ...func(*Local)) *Local {
+	config := &Local{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+func SetLocalLogger(log *logrus.Logger) LocalOption {
+	return func(config *Local) {
+		config.log = log
+	}
+}
+
+// SendRunnerMessage enqueues a copy of params. Callers reuse and mutate the
+// bootstrap between sends, so the message is copied the way SQS would serialize it.
+func (config *Local) SendRunnerMessage(params *SendMessage) error {
+	bootstrap := *params.Bootstrap
+	message := *params.Message
+
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.messages = append(config.messages, &localMessage{
+		message: &SendMessage{Bootstrap: &bootstrap, Message: &message},
+		due:     time.Now().Add(time.Duration(params.DelaySeconds) * time.Second),
+	})
+
+	if config.log != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":   "Local::SendRunnerMessage",
+			"function": bootstrap.Function,
+			"message":  message,
+			"delay":    params.DelaySeconds,
+		}).Debug("queued message")
+	}
+	return nil
+}
+
+// Receive removes and returns the oldest visible message. ok is false when no message is
+// visible, which includes messages still held back by DelaySeconds.
+func (config *Local) Receive() (message *SendMessage, ok bool) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	now := time.Now()
+	for i, queued := range config.messages {
+		if queued.due.After(now) {
+			continue
+		}
+		config.messages = append(config.messages[:i], config.messages[i+1:]...)
+		return queued.message, true
+	}
+	return nil, false
+}
+
+// Requeue puts a received message back on the queue after delaySeconds. The message is not
+// copied, so callers can recognize it when it is received again.
+func (config *Local) Requeue(message *SendMessage, delaySeconds int32) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.messages = append(config.messages, &localMessage{
+		message: message,
+		due:     time.Now().Add(time.Duration(delaySeconds) * time.Second),
+	})
+}
+
+// NextDue returns the earliest time a queued message becomes visible. ok is false when the
+// queue is empty.
+func (config *Local) NextDue() (due time.Time, ok bool) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	for _, queued := range config.messages {
+		if !ok || queued.due.Before(due) {
+			due, ok = queued.due, true
+		}
+	}
+	return due, ok
+}
+
+// Len returns the number of queued messages, delayed or not.
+func (config *Local) Len() int {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	return len(config.messages)
+}
@@ -23,16 +23,33 @@ type Bootstrap struct {
 }
 
 type ProcessorMessage struct {
-	UserID    int64  `json:"user_id"`
-	TweetID   string `json:"tweet_id"`
-	EntityURL string `json:"entity_url"`
+	UserID    int64   `json:"user_id"`
+	TweetID   string  `json:"tweet_id"`
+	EntityURL string  `json:"entity_url"`
+	MediaType string  `json:"media_type,omitempty"`
+	SinceID   int64   `json:"since_id"`
+	MaxID     int64   `json:"max_id"`
+	Cursor    int64   `json:"cursor"`
+	UserIDs   []int64 `json:"user_ids,omitempty"`
+	TweetIDs  []int64 `json:"tweet_ids,omitempty"`
+	Depth     int     `json:"depth,omitempty"`
 }
 
 type SendMessage struct {
 	Bootstrap *Bootstrap        `json:"bootstrap"`
 	Message   *ProcessorMessage `json:"message"`
+	// DelaySeconds holds the message back from consumers for up to 900 seconds.
+	DelaySeconds int32 `json:"-"`
 }
 
+// Queue sends processor messages to the runner queue.
+type Queue interface {
+	SendRunnerMessage(params *SendMessage) error
+}
+
+var _ Queue = (*Config)(nil)
+var _ Queue = (*Local)(nil)
+
 type Option func(config *Config)
 
 // Configuration structure.
@@ -113,7 +130,8 @@ func (config *Config) SendRunnerMessage(params *SendMessage) error {
 			"twitter_api_key":    {DataType: aws.String("String"), StringValue: aws.String(params.Bootstrap.TwitterAPIKey)},
 			"twitter_api_secret": {DataType: aws.String("String"), StringValue: aws.String(params.Bootstrap.TwitterAPISecret)},
 		},
-		MessageBody: aws.String(string(body)),
+		MessageBody:  aws.String(string(body)),
+		DelaySeconds: params.DelaySeconds,
 	}
 	/*
 		config.log.WithFields(logrus.Fields{
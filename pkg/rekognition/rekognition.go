@@ -4,31 +4,38 @@ import (
 	"context"
 	"os"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/rekognition"
 	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
+	"github.com/rmrfslashbin/tndx/pkg/analyzer"
 	"github.com/sirupsen/logrus"
 )
 
-type Detection struct {
-	Faces      []types.FaceDetail
-	Labels     []types.Label
-	Moderation []types.ModerationLabel
-	Text       []types.TextDetection
-}
-
 type Option func(config *Config)
 
+var _ analyzer.Analyzer = (*Config)(nil)
+
 // Configuration structure.
 type Config struct {
-	region  string
-	profile string
-	log     *logrus.Logger
-	svc     *rekognition.Client
+	region        string
+	profile       string
+	collection    string
+	faceThreshold float32
+	detectors     map[string]bool
+	minConfidence float32
+	maxLabels     int32
+	budget        Budget
+	dailyBudget   int64
+	log           *logrus.Logger
+	svc           *rekognition.Client
 }
 
 func NewImageProcessor(opts ...func(*Config)) *Config {
-	cfg := &Config{}
+	cfg := &Config{
+		faceThreshold: 90,
+	}
+	SetDetectors(DefaultDetectors)(cfg)
 
 	// apply the list of options to Config
 	for _, opt := range opts {
@@ -65,119 +72,248 @@ func SetRegion(region string) Option {
 	}
 }
 
+// SetCollection sets the Rekognition face collection. When set, detected faces are indexed
+// into the collection and searched for matches from earlier media.
+func SetCollection(collection string) Option {
+	return func(config *Config) {
+		config.collection = collection
+	}
+}
+
+// SetFaceMatchThreshold sets the minimum similarity (0-100) for a face match.
+func SetFaceMatchThreshold(threshold float32) Option {
+	return func(config *Config) {
+		config.faceThreshold = threshold
+	}
+}
+
 func SetLogger(log *logrus.Logger) Option {
 	return func(config *Config) {
 		config.log = log
 	}
 }
 
-func (config *Config) Process(s3Obj *types.S3Object) (*Detection, error) {
-	type Faces struct {
-		faces []types.FaceDetail
-		err   error
+// Analyze runs the configured detectors over an S3 object, then indexes its faces when a
+// face collection is set.
+func (config *Config) Analyze(bucket string, key string) (*analyzer.Detection, error) {
+	s3Obj := &types.S3Object{
+		Bucket: aws.String(bucket),
+		Name:   aws.String(key),
+	}
+	ok, err := config.spend(int64(len(config.detectors)))
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "rekognition::Analyze::spend",
+			"error":  err,
+		}).Error("error spending budget")
+		return nil, err
 	}
-	type Labels struct {
-		labels []types.Label
-		err    error
+	if !ok {
+		config.log.WithFields(logrus.Fields{
+			"key":    key,
+			"budget": config.dailyBudget,
+		}).Warn("daily budget spent; deferring analysis")
+		return nil, analyzer.ErrDeferred
 	}
 
-	type Moderation struct {
-		moderation []types.ModerationLabel
-		err        error
+	detection, err := config.Process(s3Obj)
+	if err != nil {
+		return nil, err
 	}
-
-	type Text struct {
-		text []types.TextDetection
-		err  error
+	if config.collection != "" && len(detection.Faces) > 0 {
+		// One IndexFaces call plus a SearchFaces call per face.
+		if ok, err := config.spend(int64(1 + len(detection.Faces))); err != nil {
+			return nil, err
+		} else if !ok {
+			config.log.WithFields(logrus.Fields{
+				"key":    key,
+				"budget": config.dailyBudget,
+			}).Warn("daily budget spent; faces not clustered")
+			return detection, nil
+		}
+		if detection.FaceMatches, err = config.IndexFaces(s3Obj, key); err != nil {
+			return nil, err
+		}
 	}
+	return detection, nil
+}
 
-	config.log.WithFields(logrus.Fields{
-		"s3Obj": s3Obj,
-	}).Info("processing image")
+// IndexFaces adds the faces in s3Obj to the collection and searches the collection for
+// each new face. Matches are face ids from earlier media, most similar first.
+func (config *Config) IndexFaces(s3Obj *types.S3Object, externalID string) ([]analyzer.FaceMatch, error) {
+	indexed, err := config.svc.IndexFaces(context.TODO(), &rekognition.IndexFacesInput{
+		CollectionId:    aws.String(config.collection),
+		Image:           &types.Image{S3Object: s3Obj},
+		ExternalImageId: aws.String(externalImageID(externalID)),
+	})
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action":     "rekognition::IndexFaces",
+			"collection": config.collection,
+			"error":      err,
+		}).Error("error indexing faces")
+		return nil, err
+	}
 
-	facesChannel := make(chan Faces)
-	labelsChannel := make(chan Labels)
-	moderationChannel := make(chan Moderation)
-	textChannel := make(chan Text)
-
-	go func() {
-		if faces, err := config.svc.DetectFaces(context.TODO(), &rekognition.DetectFacesInput{
-			Image: &types.Image{
-				S3Object: s3Obj,
-			},
-		}); err != nil {
-			facesChannel <- Faces{err: err}
-		} else {
-			facesChannel <- Faces{faces: faces.FaceDetails}
+	matches := []analyzer.FaceMatch{}
+	for _, record := range indexed.FaceRecords {
+		if record.Face == nil || record.Face.FaceId == nil {
+			continue
 		}
-	}()
-
-	go func() {
-		if labels, err := config.svc.DetectLabels(context.TODO(), &rekognition.DetectLabelsInput{
-			Image: &types.Image{
-				S3Object: s3Obj,
-			},
-		}); err != nil {
-			labelsChannel <- Labels{err: err}
-		} else {
-			labelsChannel <- Labels{labels: labels.Labels}
+		faceID := aws.ToString(record.Face.FaceId)
+		found, err := config.svc.SearchFaces(context.TODO(), &rekognition.SearchFacesInput{
+			CollectionId:       aws.String(config.collection),
+			FaceId:             aws.String(faceID),
+			FaceMatchThreshold: aws.Float32(config.faceThreshold),
+		})
+		if err != nil {
+			config.log.WithFields(logrus.Fields{
+				"action": "rekognition::SearchFaces",
+				"faceId": faceID,
+				"error":  err,
+			}).Error("error searching faces")
+			return nil, err
 		}
-	}()
-
-	go func() {
-		if moderation, err := config.svc.DetectModerationLabels(context.TODO(), &rekognition.DetectModerationLabelsInput{
-			Image: &types.Image{
-				S3Object: s3Obj,
-			},
-		}); err != nil {
-			moderationChannel <- Moderation{err: err}
-		} else {
-			moderationChannel <- Moderation{moderation: moderation.ModerationLabels}
+		match := analyzer.FaceMatch{FaceID: faceID, Matches: []string{}}
+		for _, f := range found.FaceMatches {
+			if f.Face != nil && f.Face.FaceId != nil {
+				match.Matches = append(match.Matches, aws.ToString(f.Face.FaceId))
+			}
 		}
-	}()
-
-	go func() {
-		if text, err := config.svc.DetectText(context.TODO(), &rekognition.DetectTextInput{
-			Image: &types.Image{
-				S3Object: s3Obj,
-			},
-		}); err != nil {
-			textChannel <- Text{err: err}
-		} else {
-			textChannel <- Text{text: text.TextDetections}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+// externalImageID maps an S3 key onto the characters Rekognition allows in an external image id.
+func externalImageID(key string) string {
+	id := []byte(key)
+	for i, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-' || c == ':') {
+			id[i] = '_'
 		}
-	}()
+	}
+	if len(id) > 255 {
+		id = id[len(id)-255:]
+	}
+	return string(id)
+}
 
-	faces, labels, moderation, text := <-facesChannel, <-labelsChannel, <-moderationChannel, <-textChannel
-	if faces.err != nil {
-		config.log.WithFields(logrus.Fields{
-			"error": faces.err,
-		}).Error("error processing faces")
-		return nil, faces.err
+func (config *Config) GetAnalyzerName() string {
+	return "rekognition"
+}
+
+// Process runs the configured detectors over s3Obj concurrently. Detectors that aren't
+// configured are left empty.
+func (config *Config) Process(s3Obj *types.S3Object) (*analyzer.Detection, error) {
+	config.log.WithFields(logrus.Fields{
+		"s3Obj": s3Obj,
+	}).Info("processing image")
+
+	image := &types.Image{
+		S3Object: s3Obj,
 	}
-	if labels.err != nil {
-		config.log.WithFields(logrus.Fields{
-			"error": labels.err,
-		}).Error("error processing labels")
-		return nil, labels.err
+	var minConfidence *float32
+	if config.minConfidence > 0 {
+		minConfidence = aws.Float32(config.minConfidence)
 	}
-	if moderation.err != nil {
-		config.log.WithFields(logrus.Fields{
-			"error": moderation.err,
-		}).Error("error processing moderation")
-		return nil, moderation.err
+
+	detection := &analyzer.Detection{}
+	calls := map[string]func() error{
+		DetectFaces: func() error {
+			faces, err := config.svc.DetectFaces(context.TODO(), &rekognition.DetectFacesInput{
+				Image: image,
+			})
+			if err == nil {
+				detection.Faces = faces.FaceDetails
+			}
+			return err
+		},
+		DetectLabels: func() error {
+			input := &rekognition.DetectLabelsInput{
+				Image:         image,
+				MinConfidence: minConfidence,
+			}
+			if config.maxLabels > 0 {
+				input.MaxLabels = aws.Int32(config.maxLabels)
+			}
+			labels, err := config.svc.DetectLabels(context.TODO(), input)
+			if err == nil {
+				detection.Labels = labels.Labels
+			}
+			return err
+		},
+		DetectModeration: func() error {
+			moderation, err := config.svc.DetectModerationLabels(context.TODO(), &rekognition.DetectModerationLabelsInput{
+				Image:         image,
+				MinConfidence: minConfidence,
+			})
+			if err == nil {
+				detection.Moderation = moderation.ModerationLabels
+			}
+			return err
+		},
+		DetectText: func() error {
+			input := &rekognition.DetectTextInput{
+				Image: image,
+			}
+			if minConfidence != nil {
+				input.Filters = &types.DetectTextFilters{
+					WordFilter: &types.DetectionFilter{MinConfidence: minConfidence},
+				}
+			}
+			text, err := config.svc.DetectText(context.TODO(), input)
+			if err == nil {
+				detection.Text = text.TextDetections
+			}
+			return err
+		},
+		DetectCelebrities: func() error {
+			celebrities, err := config.svc.RecognizeCelebrities(context.TODO(), &rekognition.RecognizeCelebritiesInput{
+				Image: image,
+			})
+			if err != nil {
+				return err
+			}
+			// RecognizeCelebrities has no confidence filter of its own.
+			for _, celebrity := range celebrities.CelebrityFaces {
+				if aws.ToFloat32(celebrity.MatchConfidence) >= config.minConfidence {
+					detection.Celebrities = append(detection.Celebrities, celebrity)
+				}
+			}
+			return nil
+		},
 	}
-	if text.err != nil {
-		config.log.WithFields(logrus.Fields{
-			"error": text.err,
-		}).Error("error processing text")
-		return nil, text.err
+
+	type result struct {
+		detector string
+		err      error
+	}
+	results := make(chan result)
+	running := 0
+	for detector, call := range calls {
+		if !config.detectors[detector] {
+			continue
+		}
+		running++
+		go func(detector string, call func() error) {
+			results <- result{detector: detector, err: call()}
+		}(detector, call)
 	}
 
-	return &Detection{
-		Faces:      faces.faces,
-		Labels:     labels.labels,
-		Moderation: moderation.moderation,
-		Text:       text.text,
-	}, nil
+	// Every call writes its own field, so the detection is complete once all have returned.
+	var err error
+	for ; running > 0; running-- {
+		if r := <-results; r.err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error":    r.err,
+				"detector": r.detector,
+			}).Error("error processing " + r.detector)
+			err = r.err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return detection, nil
 }
@@ -0,0 +1,87 @@
+package rekognition
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Detectors, as passed to SetDetectors. Each is one Rekognition call per image.
+const (
+	DetectCelebrities = "celebrities"
+	DetectFaces       = "faces"
+	DetectLabels      = "labels"
+	DetectModeration  = "moderation"
+	DetectText        = "text"
+)
+
+// DefaultDetectors are run when SetDetectors isn't used.
+var DefaultDetectors = []string{DetectFaces, DetectLabels, DetectModeration, DetectText}
+
+// BudgetName is the name the daily call budget is tracked under.
+const BudgetName = "rekognition"
+
+// Budget tracks Rekognition calls per UTC day across every analyzer sharing it.
+// database.Database implements it.
+type Budget interface {
+	SpendBudget(budget string, day string, calls int64, limit int64) (ok bool, err error)
+}
+
+// ParseDetectors parses a comma separated detector list, such as "faces,labels,celebrities".
+func ParseDetectors(value string) ([]string, error) {
+	detectors := []string{}
+	for _, detector := range strings.Split(value, ",") {
+		detector = strings.ToLower(strings.TrimSpace(detector))
+		switch detector {
+		case "":
+			continue
+		case DetectCelebrities, DetectFaces, DetectLabels, DetectModeration, DetectText:
+			detectors = append(detectors, detector)
+		default:
+			return nil, fmt.Errorf("unknown detector %q", detector)
+		}
+	}
+	return detectors, nil
+}
+
+// SetDetectors sets which detectors run. Detectors left out aren't called (or paid for).
+func SetDetectors(detectors []string) Option {
+	return func(config *Config) {
+		config.detectors = map[string]bool{}
+		for _, detector := range detectors {
+			config.detectors[detector] = true
+		}
+	}
+}
+
+// SetMinConfidence sets the minimum confidence (0-100) for labels, moderation labels, text
+// and celebrities. Zero keeps Rekognition's defaults.
+func SetMinConfidence(confidence float32) Option {
+	return func(config *Config) {
+		config.minConfidence = confidence
+	}
+}
+
+// SetMaxLabels caps the labels returned per image. Zero returns every label.
+func SetMaxLabels(maxLabels int32) Option {
+	return func(config *Config) {
+		config.maxLabels = maxLabels
+	}
+}
+
+// SetBudget caps the Rekognition calls made per UTC day. Once the day's budget is spent,
+// Analyze returns analyzer.ErrDeferred. A limit of zero (or no budget) is unlimited.
+func SetBudget(budget Budget, daily int64) Option {
+	return func(config *Config) {
+		config.budget = budget
+		config.dailyBudget = daily
+	}
+}
+
+// spend takes calls from the day's budget. ok is false once the budget is spent.
+func (config *Config) spend(calls int64) (bool, error) {
+	if config.budget == nil || config.dailyBudget <= 0 || calls == 0 {
+		return true, nil
+	}
+	return config.budget.SpendBudget(BudgetName, time.Now().UTC().Format("2006-01-02"), calls, config.dailyBudget)
+}
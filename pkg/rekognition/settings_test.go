@@ -0,0 +1,82 @@
+package rekognition
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDetectors(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{}, false},
+		{"faces", []string{DetectFaces}, false},
+		{"faces,labels,celebrities", []string{DetectFaces, DetectLabels, DetectCelebrities}, false},
+		{" Moderation , TEXT ,", []string{DetectModeration, DetectText}, false},
+		{"faces,,labels", []string{DetectFaces, DetectLabels}, false},
+		{"faces,objects", nil, true},
+	}
+	for _, test := range tests {
+		got, err := ParseDetectors(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseDetectors(%q) error = %v, want error %v", test.value, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseDetectors(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+// fakeBudget allows spending up to limit calls, like database.SpendBudget.
+type fakeBudget struct {
+	spent int64
+	calls int
+}
+
+func (budget *fakeBudget) SpendBudget(name string, day string, calls int64, limit int64) (bool, error) {
+	budget.calls++
+	if budget.spent+calls > limit {
+		return false, nil
+	}
+	budget.spent += calls
+	return true, nil
+}
+
+func TestSpend(t *testing.T) {
+	tests := []struct {
+		name      string
+		budget    *fakeBudget
+		daily     int64
+		calls     []int64
+		want      []bool
+		consulted int
+	}{
+		{"no budget", nil, 10, []int64{100}, []bool{true}, 0},
+		{"zero limit", &fakeBudget{}, 0, []int64{100}, []bool{true}, 0},
+		{"negative limit", &fakeBudget{}, -1, []int64{100}, []bool{true}, 0},
+		{"nothing to spend", &fakeBudget{}, 1, []int64{0}, []bool{true}, 0},
+		{"within limit", &fakeBudget{}, 4, []int64{2, 2}, []bool{true, true}, 2},
+		{"over limit", &fakeBudget{}, 4, []int64{3, 2, 1}, []bool{true, false, true}, 3},
+	}
+	for _, test := range tests {
+		// A nil *fakeBudget would make a non-nil Budget, so leave the interface unset instead.
+		var budget Budget
+		if test.budget != nil {
+			budget = test.budget
+		}
+		config := &Config{}
+		SetBudget(budget, test.daily)(config)
+		for i, calls := range test.calls {
+			ok, err := config.spend(calls)
+			if err != nil || ok != test.want[i] {
+				t.Errorf("%s: spend(%d) = %v, %v; want %v", test.name, calls, ok, err, test.want[i])
+			}
+		}
+		if test.budget != nil && test.budget.calls != test.consulted {
+			t.Errorf("%s: budget consulted %d times, want %d", test.name, test.budget.calls, test.consulted)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"errors"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/sirupsen/logrus"
+)
+
+// Functions maps each schedulable processor function to the runner flag that enables it.
+var Functions = map[string]database.Bits{
+	"favorites": database.F_favorites,
+	"followers": database.F_followers,
+	"friends":   database.F_friends,
+	"timeline":  database.F_timeline,
+	"user":      database.F_user,
+}
+
+type Option func(config *Config)
+
+// Config holds the drivers used to fan a scheduled run out to the processor queue.
+type Config struct {
+	log   *logrus.Logger
+	db    database.Database
+	queue queue.Queue
+}
+
+func New(opts ...func(*Config)) *Config {
+	config := &Config{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.log == nil {
+		config.log = logrus.New()
+	}
+
+	return config
+}
+
+func SetLogger(log *logrus.Logger) Option {
+	return func(config *Config) {
+		config.log = log
+	}
+}
+
+func SetDatabase(db database.Database) Option {
+	return func(config *Config) {
+		config.db = db
+	}
+}
+
+func SetQueue(queue queue.Queue) Option {
+	return func(config *Config) {
+		config.queue = queue
+	}
+}
+
+// Run sends a message for function to the queue for every user of runnerName with the matching flag set.
+func (config *Config) Run(runnerName string, function string, bootstrap *queue.Bootstrap) error {
+	flag, ok := Functions[function]
+	if !ok {
+		config.log.WithFields(logrus.Fields{
+			"function": function,
+		}).Error("invalid function; should be one of user, friend, followers, favorites, timeline")
+		return errors.New("invalid function; should be one of user, friend, followers, favorites, timeline")
+	}
+
+	users, err := config.db.GetRunnerUsers(&database.RunnerItem{RunnerName: runnerName})
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "getRunnerUsers",
+			"error":  err.Error(),
+		}).Error("error getting runner users.")
+		return err
+	}
+
+	bootstrap.Function = function
+	for _, user := range users {
+		if database.Has(user.Flags, flag) {
+			params := &queue.SendMessage{
+				Bootstrap: bootstrap,
+				Message: &queue.ProcessorMessage{
+					UserID: user.UserID,
+				},
+			}
+			if err := config.queue.SendRunnerMessage(params); err != nil {
+				config.log.WithFields(logrus.Fields{
+					"action": "sendRunnerMessage",
+					"error":  err.Error(),
+					"params": params,
+				}).Error("error sending runner message.")
+				return err
+			}
+			config.log.WithFields(logrus.Fields{
+				"params": params,
+			}).Info(function + " sent.")
+		}
+	}
+
+	return nil
+}
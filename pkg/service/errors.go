@@ -0,0 +1,224 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Twitter API error codes.
+// https://developer.twitter.com/en/support/twitter-api/error-troubleshooting
+const (
+	codeNoData              = 8
+	codeNoUserMatches       = 17
+	codeCouldNotAuth        = 32
+	codePageNotFound        = 34
+	codeUserNotFound        = 50
+	codeSuspended           = 63
+	codeRateLimit           = 88
+	codeInvalidToken        = 89
+	codeUnableToVerify      = 99
+	codeOverCapacity        = 130
+	codeInternalError       = 131
+	codeTimestampOutOfRange = 135
+	codeNoStatus            = 144
+	codeNotAuthorizedStatus = 179
+	codeBadAuth             = 215
+	codeStatusUnavailable   = 421
+	codeStatusNotAvailable  = 422
+)
+
+// RateLimited means the endpoint's window is spent until Reset, either because Twitter
+// returned 429 or because the shared Budget ran out before calling it.
+type RateLimited struct {
+	Endpoint string
+	Reset    time.Time
+	Err      error
+}
+
+func (e *RateLimited) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: rate limited until %s: %s", e.Endpoint, e.Reset.Format(time.RFC3339), e.Err)
+	}
+	return fmt.Sprintf("%s: rate limit budget spent until %s", e.Endpoint, e.Reset.Format(time.RFC3339))
+}
+
+func (e *RateLimited) Unwrap() error {
+	return e.Err
+}
+
+// NotFound means the requested user, tweet or page doesn't exist (or no longer does).
+type NotFound struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *NotFound) Error() string {
+	return fmt.Sprintf("%s: not found: %s", e.Endpoint, e.Err)
+}
+
+func (e *NotFound) Unwrap() error {
+	return e.Err
+}
+
+// Suspended means the requested user's account has been suspended.
+type Suspended struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *Suspended) Error() string {
+	return fmt.Sprintf("%s: suspended: %s", e.Endpoint, e.Err)
+}
+
+func (e *Suspended) Unwrap() error {
+	return e.Err
+}
+
+// Protected means the requested user's tweets are protected from these credentials.
+type Protected struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *Protected) Error() string {
+	return fmt.Sprintf("%s: protected: %s", e.Endpoint, e.Err)
+}
+
+func (e *Protected) Unwrap() error {
+	return e.Err
+}
+
+// Unauthorized means Twitter rejected the app credentials themselves.
+type Unauthorized struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *Unauthorized) Error() string {
+	return fmt.Sprintf("%s: unauthorized: %s", e.Endpoint, e.Err)
+}
+
+func (e *Unauthorized) Unwrap() error {
+	return e.Err
+}
+
+// Invalid means Twitter rejected the request for any other client-side reason; it will
+// fail the same way if repeated.
+type Invalid struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *Invalid) Error() string {
+	return fmt.Sprintf("%s: invalid request: %s", e.Endpoint, e.Err)
+}
+
+func (e *Invalid) Unwrap() error {
+	return e.Err
+}
+
+// Transient means the call failed in transport or on Twitter's side and may succeed later.
+type Transient struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *Transient) Error() string {
+	return fmt.Sprintf("%s: transient: %s", e.Endpoint, e.Err)
+}
+
+func (e *Transient) Unwrap() error {
+	return e.Err
+}
+
+// wrapError turns the result of a go-twitter call into one of the typed errors above. It also
+// catches error responses go-twitter lets through as a nil error, such as the bare 401 Twitter
+// returns for a protected timeline.
+func wrapError(endpoint string, resp *http.Response, err error) error {
+	if err == nil && (resp == nil || resp.StatusCode < 400) {
+		return nil
+	}
+	if resp == nil {
+		return &Transient{Endpoint: endpoint, Err: err}
+	}
+	if err == nil {
+		err = errors.New(resp.Status)
+	}
+
+	var apiError twitter.APIError
+	if errors.As(err, &apiError) && len(apiError.Errors) > 0 {
+		switch apiError.Errors[0].Code {
+		case codeRateLimit:
+			return rateLimitedError(endpoint, resp, err)
+		case codeSuspended:
+			return &Suspended{Endpoint: endpoint, Err: err}
+		case codeNotAuthorizedStatus:
+			return &Protected{Endpoint: endpoint, Err: err}
+		case codeNoData, codeNoUserMatches, codePageNotFound, codeUserNotFound, codeNoStatus, codeStatusUnavailable, codeStatusNotAvailable:
+			return &NotFound{Endpoint: endpoint, Err: err}
+		case codeCouldNotAuth, codeInvalidToken, codeUnableToVerify, codeTimestampOutOfRange, codeBadAuth:
+			return &Unauthorized{Endpoint: endpoint, Err: err}
+		case codeOverCapacity, codeInternalError:
+			return &Transient{Endpoint: endpoint, Err: err}
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return rateLimitedError(endpoint, resp, err)
+	case resp.StatusCode == http.StatusUnauthorized:
+		// Bad credentials carry an error code; a bare 401 is a protected account.
+		return &Protected{Endpoint: endpoint, Err: err}
+	case resp.StatusCode == http.StatusNotFound:
+		return &NotFound{Endpoint: endpoint, Err: err}
+	case resp.StatusCode >= 500:
+		return &Transient{Endpoint: endpoint, Err: err}
+	case resp.StatusCode >= 400:
+		return &Invalid{Endpoint: endpoint, Err: err}
+	}
+	return &Transient{Endpoint: endpoint, Err: err}
+}
+
+// rateLimitedError builds a RateLimited from a 429, taking the reset from its headers when present.
+func rateLimitedError(endpoint string, resp *http.Response, err error) error {
+	reset := time.Now().Add(rateLimitWindow)
+	if limit, ok := ParseRateLimit(endpoint, resp); ok {
+		reset = limit.Reset
+	}
+	return &RateLimited{Endpoint: endpoint, Reset: reset, Err: err}
+}
+
+// Kind names the typed error err wraps, for logging: "rate_limited", "not_found", "suspended",
+// "protected", "unauthorized", "invalid" or "transient". It is empty for any other error.
+func Kind(err error) string {
+	var (
+		limited      *RateLimited
+		notFound     *NotFound
+		suspended    *Suspended
+		protected    *Protected
+		unauthorized *Unauthorized
+		invalid      *Invalid
+		transient    *Transient
+	)
+	switch {
+	case errors.As(err, &limited):
+		return "rate_limited"
+	case errors.As(err, &notFound):
+		return "not_found"
+	case errors.As(err, &suspended):
+		return "suspended"
+	case errors.As(err, &protected):
+		return "protected"
+	case errors.As(err, &unauthorized):
+		return "unauthorized"
+	case errors.As(err, &invalid):
+		return "invalid"
+	case errors.As(err, &transient):
+		return "transient"
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func apiError(code int) error {
+	return twitter.APIError{Errors: []twitter.ErrorDetail{{Code: code, Message: "error " + strconv.Itoa(code)}}}
+}
+
+func response(status int) *http.Response {
+	return &http.Response{StatusCode: status, Status: strconv.Itoa(status) + " " + http.StatusText(status), Header: http.Header{}}
+}
+
+func TestWrapError(t *testing.T) {
+	transport := errors.New("connection reset")
+
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want string
+	}{
+		{"success", response(http.StatusOK), nil, ""},
+		{"no response or error", nil, nil, ""},
+		{"transport failure", nil, transport, "transient"},
+		{"error below 400", response(http.StatusFound), transport, "transient"},
+
+		{"code rate limit", response(http.StatusTooManyRequests), apiError(codeRateLimit), "rate_limited"},
+		{"code suspended", response(http.StatusForbidden), apiError(codeSuspended), "suspended"},
+		{"code protected status", response(http.StatusForbidden), apiError(codeNotAuthorizedStatus), "protected"},
+		{"code no data", response(http.StatusNotFound), apiError(codeNoData), "not_found"},
+		{"code no user matches", response(http.StatusNotFound), apiError(codeNoUserMatches), "not_found"},
+		{"code page not found", response(http.StatusNotFound), apiError(codePageNotFound), "not_found"},
+		{"code user not found", response(http.StatusNotFound), apiError(codeUserNotFound), "not_found"},
+		{"code no status", response(http.StatusNotFound), apiError(codeNoStatus), "not_found"},
+		{"code status unavailable", response(http.StatusForbidden), apiError(codeStatusUnavailable), "not_found"},
+		{"code status not available", response(http.StatusForbidden), apiError(codeStatusNotAvailable), "not_found"},
+		{"code could not auth", response(http.StatusUnauthorized), apiError(codeCouldNotAuth), "unauthorized"},
+		{"code invalid token", response(http.StatusUnauthorized), apiError(codeInvalidToken), "unauthorized"},
+		{"code unable to verify", response(http.StatusForbidden), apiError(codeUnableToVerify), "unauthorized"},
+		{"code timestamp out of range", response(http.StatusUnauthorized), apiError(codeTimestampOutOfRange), "unauthorized"},
+		{"code bad auth", response(http.StatusBadRequest), apiError(codeBadAuth), "unauthorized"},
+		{"code over capacity", response(http.StatusServiceUnavailable), apiError(codeOverCapacity), "transient"},
+		{"code internal error", response(http.StatusInternalServerError), apiError(codeInternalError), "transient"},
+		{"unknown code falls back to status", response(http.StatusForbidden), apiError(1), "invalid"},
+		{"empty api error falls back to status", response(http.StatusNotFound), twitter.APIError{}, "not_found"},
+
+		{"status 429", response(http.StatusTooManyRequests), nil, "rate_limited"},
+		{"bare 401", response(http.StatusUnauthorized), nil, "protected"},
+		{"status 404", response(http.StatusNotFound), nil, "not_found"},
+		{"status 500", response(http.StatusInternalServerError), nil, "transient"},
+		{"status 503", response(http.StatusServiceUnavailable), transport, "transient"},
+		{"status 400", response(http.StatusBadRequest), nil, "invalid"},
+		{"status 403", response(http.StatusForbidden), nil, "invalid"},
+	}
+	for _, test := range tests {
+		err := wrapError("users/show", test.resp, test.err)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%s: wrapError = %v, want nil", test.name, err)
+			}
+			continue
+		}
+		if got := Kind(err); got != test.want {
+			t.Errorf("%s: wrapError = %v (%q), want %q", test.name, err, got, test.want)
+		}
+		// APIError holds a slice, so compare the wrapped error by its message.
+		if inner := errors.Unwrap(err); test.err != nil && (inner == nil || inner.Error() != test.err.Error()) {
+			t.Errorf("%s: wrapError = %v, want it to wrap %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestWrapErrorRateLimitReset(t *testing.T) {
+	reset := time.Now().Add(5 * time.Minute).Truncate(time.Second)
+	resp := response(http.StatusTooManyRequests)
+	resp.Header.Set("x-rate-limit-limit", "900")
+	resp.Header.Set("x-rate-limit-remaining", "0")
+	resp.Header.Set("x-rate-limit-reset", strconv.FormatInt(reset.Unix(), 10))
+
+	var limited *RateLimited
+	if err := wrapError("users/show", resp, apiError(codeRateLimit)); !errors.As(err, &limited) || !limited.Reset.Equal(reset) {
+		t.Errorf("wrapError = %v, want RateLimited until %v", err, reset)
+	}
+
+	// Without headers the standard 15-minute window is assumed.
+	before := time.Now()
+	if err := wrapError("users/show", response(http.StatusTooManyRequests), nil); !errors.As(err, &limited) ||
+		limited.Reset.Before(before.Add(rateLimitWindow)) || limited.Reset.After(time.Now().Add(rateLimitWindow)) {
+		t.Errorf("wrapError = %v, want RateLimited for about %v", err, rateLimitWindow)
+	}
+}
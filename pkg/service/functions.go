@@ -28,16 +28,23 @@ func FixTwitterTimeRFC3339(timeStr string) (string, error) {
 
 // GetUser returns a Twitter user's details.
 func (config *Config) GetUser(queryParams *QueryParams) (*twitter.User, *http.Response, error) {
+	if err := config.take(EndpointUsersShow); err != nil {
+		return nil, nil, err
+	}
 	// Connect to the Twitter API and fetch the requested user.
 	user, resp, err := config.client.Users.Show(&twitter.UserShowParams{
 		ScreenName: queryParams.ScreenName,
 		UserID:     queryParams.UserID,
 	})
-	return user, resp, err
+	config.track(EndpointUsersShow, resp)
+	return user, resp, wrapError(EndpointUsersShow, resp, err)
 }
 
 // GetUserFavorites returns a user's Twitter favorites (likes).
 func (c *Config) GetUserFavorites(queryParams *QueryParams) ([]twitter.Tweet, *http.Response, error) {
+	if err := c.take(EndpointFavoritesList); err != nil {
+		return nil, nil, err
+	}
 	// Connect to the Twitter API and fetch the requested user's favorites.
 	tweets, resp, err := c.client.Favorites.List(&twitter.FavoriteListParams{
 		ScreenName: queryParams.ScreenName,
@@ -47,8 +54,9 @@ func (c *Config) GetUserFavorites(queryParams *QueryParams) ([]twitter.Tweet, *h
 		MaxID:      queryParams.MaxID,
 		TweetMode:  "extended",
 	})
+	c.track(EndpointFavoritesList, resp)
 	if err != nil {
-		return nil, resp, err
+		return nil, resp, wrapError(EndpointFavoritesList, resp, err)
 	}
 	for tweet := range tweets {
 		tweets[tweet].CreatedAt, _ = FixTwitterTime(tweets[tweet].CreatedAt)
@@ -63,39 +71,44 @@ func (c *Config) GetUserFavorites(queryParams *QueryParams) ([]twitter.Tweet, *h
 		}
 	}
 
-	return tweets, resp, err
+	return tweets, resp, wrapError(EndpointFavoritesList, resp, err)
 }
 
-// GetUserFollowers returns a user's Twitter followers.
-func (c *Config) GetUserFollowers(queryParams *QueryParams) (*twitter.Followers, *http.Response, error) {
-	// Connect to the Twitter API and fetch timeline as defined.
-	followers, resp, err := c.client.Followers.List(&twitter.FollowerListParams{
-		ScreenName:          queryParams.ScreenName,
-		UserID:              queryParams.UserID,
-		Count:               queryParams.Count,
-		SkipStatus:          queryParams.SkipStatus,
-		IncludeUserEntities: queryParams.IncludeUserEntities,
-		Cursor:              queryParams.Cursor,
+// GetUserFollowerIDs returns a page of up to 5000 of a user's follower IDs.
+func (c *Config) GetUserFollowerIDs(queryParams *QueryParams) (*twitter.FollowerIDs, *http.Response, error) {
+	if err := c.take(EndpointFollowersIDs); err != nil {
+		return nil, nil, err
+	}
+	ids, resp, err := c.client.Followers.IDs(&twitter.FollowerIDParams{
+		ScreenName: queryParams.ScreenName,
+		UserID:     queryParams.UserID,
+		Count:      queryParams.Count,
+		Cursor:     queryParams.Cursor,
 	})
-	return followers, resp, err
+	c.track(EndpointFollowersIDs, resp)
+	return ids, resp, wrapError(EndpointFollowersIDs, resp, err)
 }
 
-// GetUserFriends returns a user's Twitter friends.
-func (c *Config) GetUserFriends(queryParams *QueryParams) (*twitter.Friends, *http.Response, error) {
-	// Connect to the Twitter API and fetch timeline as defined.
-	friends, resp, err := c.client.Friends.List(&twitter.FriendListParams{
-		ScreenName:          queryParams.ScreenName,
-		UserID:              queryParams.UserID,
-		Count:               queryParams.Count,
-		SkipStatus:          queryParams.SkipStatus,
-		IncludeUserEntities: queryParams.IncludeUserEntities,
-		Cursor:              queryParams.Cursor,
+// GetUserFriendIDs returns a page of up to 5000 of a user's friend IDs.
+func (c *Config) GetUserFriendIDs(queryParams *QueryParams) (*twitter.FriendIDs, *http.Response, error) {
+	if err := c.take(EndpointFriendsIDs); err != nil {
+		return nil, nil, err
+	}
+	ids, resp, err := c.client.Friends.IDs(&twitter.FriendIDParams{
+		ScreenName: queryParams.ScreenName,
+		UserID:     queryParams.UserID,
+		Count:      queryParams.Count,
+		Cursor:     queryParams.Cursor,
 	})
-	return friends, resp, err
+	c.track(EndpointFriendsIDs, resp)
+	return ids, resp, wrapError(EndpointFriendsIDs, resp, err)
 }
 
 // GetUserTimeline returns a user's Twitter timeline.
 func (c *Config) GetUserTimeline(queryParams *QueryParams) ([]twitter.Tweet, *http.Response, error) {
+	if err := c.take(EndpointUserTimeline); err != nil {
+		return nil, nil, err
+	}
 	// Connect to the Twitter API and fetch timeline as defined.
 	tweets, resp, err := c.client.Timelines.UserTimeline(&twitter.UserTimelineParams{
 		ScreenName: queryParams.ScreenName,
@@ -105,8 +118,9 @@ func (c *Config) GetUserTimeline(queryParams *QueryParams) ([]twitter.Tweet, *ht
 		MaxID:      queryParams.MaxID,
 		TweetMode:  "extended",
 	})
+	c.track(EndpointUserTimeline, resp)
 	if err != nil {
-		return nil, resp, err
+		return nil, resp, wrapError(EndpointUserTimeline, resp, err)
 	}
 	for tweet := range tweets {
 		tweets[tweet].CreatedAt, _ = FixTwitterTime(tweets[tweet].CreatedAt)
@@ -120,15 +134,19 @@ func (c *Config) GetUserTimeline(queryParams *QueryParams) ([]twitter.Tweet, *ht
 			tweets[tweet].QuotedStatus.User.CreatedAt, _ = FixTwitterTime(tweets[tweet].QuotedStatus.User.CreatedAt)
 		}
 	}
-	return tweets, resp, err
+	return tweets, resp, wrapError(EndpointUserTimeline, resp, err)
 }
 
 func (c *Config) LookupTweets(ids []int64) ([]twitter.Tweet, *http.Response, error) {
+	if err := c.take(EndpointStatusesLookup); err != nil {
+		return nil, nil, err
+	}
 	tweets, resp, err := c.client.Statuses.Lookup(ids, &twitter.StatusLookupParams{
 		TweetMode: "extended",
 	})
+	c.track(EndpointStatusesLookup, resp)
 	if err != nil {
-		return nil, resp, err
+		return nil, resp, wrapError(EndpointStatusesLookup, resp, err)
 	}
 	for tweet := range tweets {
 		tweets[tweet].CreatedAt, _ = FixTwitterTime(tweets[tweet].CreatedAt)
@@ -142,10 +160,15 @@ func (c *Config) LookupTweets(ids []int64) ([]twitter.Tweet, *http.Response, err
 			tweets[tweet].QuotedStatus.User.CreatedAt, _ = FixTwitterTime(tweets[tweet].QuotedStatus.User.CreatedAt)
 		}
 	}
-	return tweets, resp, err
+	return tweets, resp, wrapError(EndpointStatusesLookup, resp, err)
 
 }
 
 func (c *Config) LookupUsers(lookupParams *twitter.UserLookupParams) ([]twitter.User, *http.Response, error) {
-	return c.client.Users.Lookup(lookupParams)
+	if err := c.take(EndpointUsersLookup); err != nil {
+		return nil, nil, err
+	}
+	users, resp, err := c.client.Users.Lookup(lookupParams)
+	c.track(EndpointUsersLookup, resp)
+	return users, resp, wrapError(EndpointUsersLookup, resp, err)
 }
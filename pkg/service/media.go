@@ -0,0 +1,68 @@
+package service
+
+import (
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Media types, as reported in MediaEntity.Type.
+const (
+	MediaPhoto       = "photo"
+	MediaVideo       = "video"
+	MediaAnimatedGIF = "animated_gif"
+	mediaContentMP4  = "video/mp4"
+)
+
+// Media is one downloadable media item attached to a tweet.
+type Media struct {
+	URL  string
+	Type string
+}
+
+// TweetMedia returns every photo, video and GIF attached to tweet. extended_entities lists all
+// of them; entities only ever holds the first photo and is used when the former is missing.
+// Videos and GIFs resolve to their highest-bitrate MP4 variant.
+func TweetMedia(tweet *twitter.Tweet) []Media {
+	var entities []twitter.MediaEntity
+	switch {
+	case tweet.ExtendedEntities != nil && len(tweet.ExtendedEntities.Media) > 0:
+		entities = tweet.ExtendedEntities.Media
+	case tweet.ExtendedTweet != nil && tweet.ExtendedTweet.ExtendedEntities != nil:
+		entities = tweet.ExtendedTweet.ExtendedEntities.Media
+	case tweet.Entities != nil:
+		entities = tweet.Entities.Media
+	}
+
+	media := make([]Media, 0, len(entities))
+	for m := range entities {
+		var url string
+		switch entities[m].Type {
+		case MediaVideo, MediaAnimatedGIF:
+			url = bestVariant(entities[m].VideoInfo.Variants)
+		default:
+			if entities[m].MediaURLHttps != "" {
+				url = entities[m].MediaURLHttps
+			} else {
+				url = entities[m].MediaURL
+			}
+		}
+		if url != "" {
+			media = append(media, Media{URL: url, Type: entities[m].Type})
+		}
+	}
+	return media
+}
+
+// bestVariant returns the URL of the highest-bitrate MP4 variant, or "" if there is none.
+// HLS playlists are skipped; they can't be archived as a single file.
+func bestVariant(variants []twitter.VideoVariant) string {
+	var (
+		url     string
+		bitrate = -1
+	)
+	for _, variant := range variants {
+		if variant.ContentType == mediaContentMP4 && variant.Bitrate > bitrate {
+			url, bitrate = variant.URL, variant.Bitrate
+		}
+	}
+	return url
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func photo(url string) twitter.MediaEntity {
+	return twitter.MediaEntity{MediaURLHttps: url, Type: MediaPhoto}
+}
+
+func video(kind string, variants ...twitter.VideoVariant) twitter.MediaEntity {
+	return twitter.MediaEntity{
+		MediaURLHttps: "https://pbs.twimg.com/thumb.jpg",
+		Type:          kind,
+		VideoInfo:     twitter.VideoInfo{Variants: variants},
+	}
+}
+
+func TestTweetMedia(t *testing.T) {
+	low := twitter.VideoVariant{ContentType: mediaContentMP4, Bitrate: 256000, URL: "https://video.twimg.com/low.mp4"}
+	high := twitter.VideoVariant{ContentType: mediaContentMP4, Bitrate: 2176000, URL: "https://video.twimg.com/high.mp4"}
+	hls := twitter.VideoVariant{ContentType: "application/x-mpegURL", URL: "https://video.twimg.com/pl.m3u8"}
+	gif := twitter.VideoVariant{ContentType: mediaContentMP4, URL: "https://video.twimg.com/tweet_video/gif.mp4"}
+
+	tests := []struct {
+		name  string
+		tweet *twitter.Tweet
+		want  []Media
+	}{
+		{"no media", &twitter.Tweet{}, []Media{}},
+		{"entities only", &twitter.Tweet{
+			Entities: &twitter.Entities{Media: []twitter.MediaEntity{photo("https://pbs.twimg.com/a.jpg")}},
+		}, []Media{{URL: "https://pbs.twimg.com/a.jpg", Type: MediaPhoto}}},
+		{"http url fallback", &twitter.Tweet{
+			Entities: &twitter.Entities{Media: []twitter.MediaEntity{{MediaURL: "http://pbs.twimg.com/a.jpg", Type: MediaPhoto}}},
+		}, []Media{{URL: "http://pbs.twimg.com/a.jpg", Type: MediaPhoto}}},
+		{"extended entities replace entities", &twitter.Tweet{
+			Entities: &twitter.Entities{Media: []twitter.MediaEntity{photo("https://pbs.twimg.com/a.jpg")}},
+			ExtendedEntities: &twitter.ExtendedEntity{Media: []twitter.MediaEntity{
+				photo("https://pbs.twimg.com/a.jpg"),
+				photo("https://pbs.twimg.com/b.jpg"),
+			}},
+		}, []Media{
+			{URL: "https://pbs.twimg.com/a.jpg", Type: MediaPhoto},
+			{URL: "https://pbs.twimg.com/b.jpg", Type: MediaPhoto},
+		}},
+		{"extended tweet", &twitter.Tweet{
+			Entities: &twitter.Entities{},
+			ExtendedTweet: &twitter.ExtendedTweet{ExtendedEntities: &twitter.ExtendedEntity{Media: []twitter.MediaEntity{
+				photo("https://pbs.twimg.com/a.jpg"),
+				photo("https://pbs.twimg.com/b.jpg"),
+			}}},
+		}, []Media{
+			{URL: "https://pbs.twimg.com/a.jpg", Type: MediaPhoto},
+			{URL: "https://pbs.twimg.com/b.jpg", Type: MediaPhoto},
+		}},
+		{"empty extended entities", &twitter.Tweet{
+			Entities:         &twitter.Entities{Media: []twitter.MediaEntity{photo("https://pbs.twimg.com/a.jpg")}},
+			ExtendedEntities: &twitter.ExtendedEntity{},
+		}, []Media{{URL: "https://pbs.twimg.com/a.jpg", Type: MediaPhoto}}},
+		{"video", &twitter.Tweet{
+			ExtendedEntities: &twitter.ExtendedEntity{Media: []twitter.MediaEntity{video(MediaVideo, hls, low, high)}},
+		}, []Media{{URL: high.URL, Type: MediaVideo}}},
+		{"animated gif", &twitter.Tweet{
+			ExtendedEntities: &twitter.ExtendedEntity{Media: []twitter.MediaEntity{video(MediaAnimatedGIF, gif)}},
+		}, []Media{{URL: gif.URL, Type: MediaAnimatedGIF}}},
+		{"video without mp4", &twitter.Tweet{
+			ExtendedEntities: &twitter.ExtendedEntity{Media: []twitter.MediaEntity{video(MediaVideo, hls), photo("https://pbs.twimg.com/b.jpg")}},
+		}, []Media{{URL: "https://pbs.twimg.com/b.jpg", Type: MediaPhoto}}},
+	}
+	for _, test := range tests {
+		if got := TweetMedia(test.tweet); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: TweetMedia = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBestVariant(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []twitter.VideoVariant
+		want     string
+	}{
+		{"none", nil, ""},
+		{"hls only", []twitter.VideoVariant{{ContentType: "application/x-mpegURL", URL: "pl.m3u8"}}, ""},
+		{"zero bitrate gif", []twitter.VideoVariant{{ContentType: mediaContentMP4, URL: "gif.mp4"}}, "gif.mp4"},
+		{"highest bitrate", []twitter.VideoVariant{
+			{ContentType: mediaContentMP4, Bitrate: 832000, URL: "mid.mp4"},
+			{ContentType: "application/x-mpegURL", Bitrate: 9000000, URL: "pl.m3u8"},
+			{ContentType: mediaContentMP4, Bitrate: 2176000, URL: "high.mp4"},
+			{ContentType: mediaContentMP4, Bitrate: 256000, URL: "low.mp4"},
+		}, "high.mp4"},
+		{"first of equal bitrates", []twitter.VideoVariant{
+			{ContentType: mediaContentMP4, Bitrate: 832000, URL: "a.mp4"},
+			{ContentType: mediaContentMP4, Bitrate: 832000, URL: "b.mp4"},
+		}, "a.mp4"},
+	}
+	for _, test := range tests {
+		if got := bestVariant(test.variants); got != test.want {
+			t.Errorf("%s: bestVariant = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
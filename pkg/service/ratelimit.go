@@ -0,0 +1,119 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// rateLimitWindow is assumed when a 429 arrives without rate-limit headers.
+const rateLimitWindow = 15 * time.Minute
+
+// Endpoint names, as reported in RateLimit.Endpoint.
+const (
+	EndpointFavoritesList  = "favorites/list"
+	EndpointFollowersIDs   = "followers/ids"
+	EndpointFriendsIDs     = "friends/ids"
+	EndpointStatusesLookup = "statuses/lookup"
+	EndpointUserTimeline   = "statuses/user_timeline"
+	EndpointUsersLookup    = "users/lookup"
+	EndpointUsersShow      = "users/show"
+)
+
+// Budget is a per-endpoint call budget shared by every client using the same app credentials,
+// so concurrent processors stop before Twitter has to tell them. database.Database implements it.
+type Budget interface {
+	TakeRateLimit(endpoint string, now time.Time) (reset time.Time, ok bool, err error)
+	PutRateLimit(endpoint string, limit int, remaining int, reset time.Time) error
+}
+
+// RateLimit is the rate-limit window Twitter last reported for an endpoint.
+type RateLimit struct {
+	Endpoint  string
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// Exhausted reports whether the window has no calls left and hasn't reset yet.
+func (limit *RateLimit) Exhausted(now time.Time) bool {
+	return limit.Remaining <= 0 && now.Before(limit.Reset)
+}
+
+// ParseRateLimit reads the x-rate-limit-* headers from resp. ok is false when resp is nil
+// or the headers are missing.
+func ParseRateLimit(endpoint string, resp *http.Response) (limit *RateLimit, ok bool) {
+	if resp == nil {
+		return nil, false
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("x-rate-limit-remaining"))
+	if err != nil {
+		return nil, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("x-rate-limit-reset"), 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	total, _ := strconv.Atoi(resp.Header.Get("x-rate-limit-limit"))
+	return &RateLimit{
+		Endpoint:  endpoint,
+		Limit:     total,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+	}, true
+}
+
+// RateLimit returns the last window seen for endpoint by this client.
+func (c *Config) RateLimit(endpoint string) (*RateLimit, bool) {
+	c.limitsMu.Lock()
+	defer c.limitsMu.Unlock()
+	limit, ok := c.limits[endpoint]
+	return limit, ok
+}
+
+// take consumes one call for endpoint from the shared budget, if one is set. Budget errors are
+// logged and the call goes ahead; Twitter still enforces the real limit.
+func (c *Config) take(endpoint string) error {
+	if c.budget == nil {
+		return nil
+	}
+	reset, ok, err := c.budget.TakeRateLimit(endpoint, time.Now())
+	if err != nil {
+		if c.log != nil {
+			c.log.WithFields(logrus.Fields{
+				"action":   "service::take",
+				"endpoint": endpoint,
+				"error":    err.Error(),
+			}).Warn("unable to take from rate limit budget")
+		}
+		return nil
+	}
+	if !ok {
+		return &RateLimited{Endpoint: endpoint, Reset: reset}
+	}
+	return nil
+}
+
+// track records the rate-limit headers of resp for endpoint and seeds the shared budget.
+func (c *Config) track(endpoint string, resp *http.Response) {
+	limit, ok := ParseRateLimit(endpoint, resp)
+	if !ok {
+		return
+	}
+	c.limitsMu.Lock()
+	c.limits[endpoint] = limit
+	c.limitsMu.Unlock()
+
+	if c.budget == nil {
+		return
+	}
+	if err := c.budget.PutRateLimit(endpoint, limit.Limit, limit.Remaining, limit.Reset); err != nil && c.log != nil {
+		c.log.WithFields(logrus.Fields{
+			"action":   "service::track",
+			"endpoint": endpoint,
+			"error":    err.Error(),
+		}).Warn("unable to seed rate limit budget")
+	}
+}
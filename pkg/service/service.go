@@ -1,6 +1,11 @@
 package service
 
 import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+
 	"github.com/dghubble/go-twitter/twitter"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
@@ -27,13 +32,17 @@ type Config struct {
 	accessToken    string
 	consumerKey    string
 	consumerSecret string
+	baseURL        string
 	log            *logrus.Logger
 	client         *twitter.Client
+	limitsMu       sync.Mutex
+	limits         map[string]*RateLimit
+	budget         Budget
 }
 
 // New is a factory function for creating a new Config
 func New(opts ...func(*Config)) *Config {
-	config := &Config{}
+	config := &Config{limits: make(map[string]*RateLimit)}
 
 	// apply the list of options to Config
 	for _, opt := range opts {
@@ -53,8 +62,21 @@ func New(opts ...func(*Config)) *Config {
 		ClientSecret: config.consumerSecret,
 		TokenURL:     "https://api.twitter.com/oauth2/token",
 	}
+
+	// Point both the token and API requests at an alternate base URL (e.g. a fake API server).
+	ctx := oauth2.NoContext
+	if config.baseURL != "" {
+		base, err := url.Parse(config.baseURL)
+		if err != nil {
+			panic(err)
+		}
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{
+			Transport: &baseURLTransport{base: base, next: http.DefaultTransport},
+		})
+	}
+
 	// http.Client will automatically authorize Requests
-	httpClient := oauthConfig.Client(oauth2.NoContext)
+	httpClient := oauthConfig.Client(ctx)
 
 	// Twitter client
 	config.client = twitter.NewClient(httpClient)
@@ -73,6 +95,21 @@ func SetAccessToken(accessToken string) Option {
 	}
 }
 
+// SetBaseURL replaces https://api.twitter.com with baseURL; empty keeps the default.
+func SetBaseURL(baseURL string) Option {
+	return func(config *Config) {
+		config.baseURL = baseURL
+	}
+}
+
+// SetBudget shares a per-endpoint rate-limit budget with other clients; nil (the default)
+// relies on Twitter's 429s alone.
+func SetBudget(budget Budget) Option {
+	return func(config *Config) {
+		config.budget = budget
+	}
+}
+
 func SetConsumerKey(consumerKey string) Option {
 	return func(c *Config) {
 		c.consumerKey = consumerKey
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Twitter is the set of Twitter API calls used by tndx. Config implements it against the
+// real API (or any base URL set with SetBaseURL); tests and local mode can substitute fakes.
+type Twitter interface {
+	GetUser(queryParams *QueryParams) (*twitter.User, *http.Response, error)
+	GetUserFavorites(queryParams *QueryParams) ([]twitter.Tweet, *http.Response, error)
+	GetUserFollowerIDs(queryParams *QueryParams) (*twitter.FollowerIDs, *http.Response, error)
+	GetUserFriendIDs(queryParams *QueryParams) (*twitter.FriendIDs, *http.Response, error)
+	GetUserTimeline(queryParams *QueryParams) ([]twitter.Tweet, *http.Response, error)
+	LookupTweets(ids []int64) ([]twitter.Tweet, *http.Response, error)
+	LookupUsers(lookupParams *twitter.UserLookupParams) ([]twitter.User, *http.Response, error)
+	RateLimit(endpoint string) (*RateLimit, bool)
+}
+
+var _ Twitter = (*Config)(nil)
+
+// baseURLTransport rewrites requests aimed at api.twitter.com to another base URL.
+type baseURLTransport struct {
+	base *url.URL
+	next http.RoundTripper
+}
+
+func (t *baseURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.base.Scheme
+	r.URL.Host = t.base.Host
+	r.URL.Path = strings.TrimSuffix(t.base.Path, "/") + req.URL.Path
+	r.Host = t.base.Host
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type LocalOption func(c *LocalStorage)
+
+// LocalStorage stores objects in a local directory using the same key layout as S3Storage.
+type LocalStorage struct {
+	driverName string
+	directory  string
+	log        *logrus.Logger
+	notify     func(key string)
+}
+
+func NewLocalStorage(opts ...func(*LocalStorage)) *LocalStorage {
+	config := &LocalStorage{}
+	config.driverName = "local"
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+func SetLocalDirectory(directory string) LocalOption {
+	return func(config *LocalStorage) {
+		config.directory = directory
+	}
+}
+
+func SetLocalLogger(logger *logrus.Logger) LocalOption {
+	return func(config *LocalStorage) {
+		config.log = logger
+	}
+}
+
+// SetLocalNotify registers fn to be called with the key of every stored object,
+// standing in for the S3 event notifications that trigger later stages.
+func SetLocalNotify(fn func(key string)) LocalOption {
+	return func(config *LocalStorage) {
+		config.notify = fn
+	}
+}
+
+// Put gzips data and writes it to key + ".gz" below the storage directory.
+func (config *LocalStorage) Put(key string, body []byte) error {
+	buf, err := gzipBody(body)
+	if err != nil {
+		return err
+	}
+
+	// Append ".gz" to the key (filename).
+	return config.write(key+".gz", buf)
+}
+
+// PutStream writes fp to key. Files carry no metadata, so contentType is ignored.
+func (config *LocalStorage) PutStream(key string, fp io.Reader, contentType string) error {
+	return config.write(key, fp)
+}
+
+func (config *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	fqpn, err := config.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fqpn)
+}
+
+func (config *LocalStorage) Exists(key string) (bool, error) {
+	fqpn, err := config.Path(key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(fqpn); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Move renames src to dst. Moves are not reported to the notify callback.
+func (config *LocalStorage) Move(src string, dst string) error {
+	srcPath, err := config.Path(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := config.Path(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(srcPath, dstPath)
+}
+
+// Touch reports key to the notify callback, as if it had just been written.
+func (config *LocalStorage) Touch(key string) error {
+	exists, err := config.Exists(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("no object stored under " + key)
+	}
+	if config.notify != nil {
+		config.notify(key)
+	}
+	return nil
+}
+
+func (config *LocalStorage) GetDriverName() string {
+	return config.driverName
+}
+
+// Path returns the filesystem path for key.
+func (config *LocalStorage) Path(key string) (string, error) {
+	if config.directory == "" {
+		return "", errors.New("local storage directory is required")
+	}
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.HasSuffix(key, "/") {
+		return "", errors.New("invalid key: " + key)
+	}
+	return filepath.Join(config.directory, filepath.FromSlash(clean)), nil
+}
+
+// write streams fp to a temp file and renames it into place so readers never see partial objects.
+func (config *LocalStorage) write(key string, fp io.Reader) error {
+	fqpn, err := config.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fqpn), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fqpn), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, fp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), fqpn); err != nil {
+		return err
+	}
+
+	if config.log != nil {
+		config.log.WithFields(logrus.Fields{
+			"action": "LocalStorage::write",
+			"key":    key,
+			"path":   fqpn,
+		}).Debug("stored object")
+	}
+	if config.notify != nil {
+		config.notify(key)
+	}
+	return nil
+}
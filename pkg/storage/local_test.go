@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestStorage(t *testing.T) (*LocalStorage, *[]string) {
+	t.Helper()
+	notified := &[]string{}
+	return NewLocalStorage(
+		SetLocalDirectory(t.TempDir()),
+		SetLocalNotify(func(key string) { *notified = append(*notified, key) }),
+	), notified
+}
+
+func readKey(t *testing.T, store *LocalStorage, key string) []byte {
+	t.Helper()
+	body, err := store.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("reading %s: %v", key, err)
+	}
+	return data
+}
+
+func TestLocalStoragePutGzips(t *testing.T) {
+	store, notified := newTestStorage(t)
+	if err := store.Put("tweets/1.json", []byte(`{"id":1}`)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if exists, err := store.Exists("tweets/1.json"); err != nil || exists {
+		t.Errorf("Exists(tweets/1.json) = %v, %v; want false", exists, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(readKey(t, store, "tweets/1.json.gz")))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil || string(data) != `{"id":1}` {
+		t.Errorf("stored object = %q, %v; want the gzipped body", data, err)
+	}
+	if !reflect.DeepEqual(*notified, []string{"tweets/1.json.gz"}) {
+		t.Errorf("notified %v, want [tweets/1.json.gz]", *notified)
+	}
+}
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	store, notified := newTestStorage(t)
+	key := "media/ab/abcdef.jpg"
+
+	if exists, err := store.Exists(key); err != nil || exists {
+		t.Fatalf("Exists before PutStream = %v, %v; want false", exists, err)
+	}
+	if err := store.PutStream(key, strings.NewReader("image bytes"), "image/jpeg"); err != nil {
+		t.Fatalf("PutStream: %v", err)
+	}
+	if exists, err := store.Exists(key); err != nil || !exists {
+		t.Fatalf("Exists after PutStream = %v, %v; want true", exists, err)
+	}
+	if data := readKey(t, store, key); string(data) != "image bytes" {
+		t.Errorf("Get = %q, want %q", data, "image bytes")
+	}
+
+	// Overwriting replaces the object.
+	if err := store.PutStream(key, strings.NewReader("new bytes"), "image/jpeg"); err != nil {
+		t.Fatalf("PutStream again: %v", err)
+	}
+	if data := readKey(t, store, key); string(data) != "new bytes" {
+		t.Errorf("Get after overwrite = %q, want %q", data, "new bytes")
+	}
+
+	// Moves aren't notified; Touch notifies an existing object again.
+	quarantined := "quarantine/ab/abcdef.jpg"
+	if err := store.Move(key, quarantined); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if exists, err := store.Exists(key); err != nil || exists {
+		t.Errorf("Exists(%s) after Move = %v, %v; want false", key, exists, err)
+	}
+	if data := readKey(t, store, quarantined); string(data) != "new bytes" {
+		t.Errorf("Get(%s) = %q, want %q", quarantined, data, "new bytes")
+	}
+	if err := store.Touch(quarantined); err != nil {
+		t.Errorf("Touch(%s): %v", quarantined, err)
+	}
+	if err := store.Touch(key); err == nil {
+		t.Errorf("Touch(%s) of a moved object succeeded, want an error", key)
+	}
+	if want := []string{key, key, quarantined}; !reflect.DeepEqual(*notified, want) {
+		t.Errorf("notified %v, want %v", *notified, want)
+	}
+
+	// No temp files are left behind.
+	leftovers, err := filepath.Glob(filepath.Join(store.directory, "*", "*", ".tmp-*"))
+	if err != nil || len(leftovers) != 0 {
+		t.Errorf("temp files left behind: %v, %v", leftovers, err)
+	}
+}
+
+func TestLocalStoragePath(t *testing.T) {
+	store := NewLocalStorage(SetLocalDirectory("/data"))
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"media/a.jpg", filepath.FromSlash("/data/media/a.jpg"), false},
+		{"/media/a.jpg", filepath.FromSlash("/data/media/a.jpg"), false},
+		{"../../etc/passwd", filepath.FromSlash("/data/etc/passwd"), false},
+		{"", "", true},
+		{"/", "", true},
+		{"media/", "", true},
+	}
+	for _, test := range tests {
+		got, err := store.Path(test.key)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("Path(%q) = %q, %v; want %q, error %v", test.key, got, err, test.want, test.wantErr)
+		}
+	}
+
+	if _, err := NewLocalStorage().Path("media/a.jpg"); err == nil {
+		t.Error("Path without a directory succeeded, want an error")
+	}
+}
@@ -1,12 +1,15 @@
 package storage
 
 import (
-	"bytes"
-	"compress/gzip"
+	"errors"
 	"io"
+	"net/http"
+	"net/url"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/sirupsen/logrus"
 )
@@ -49,7 +52,7 @@ func SetS3Region(s3Region string) S3Option {
 	}
 }
 
-// PutObject uploads data to an S3 bucket.
+// Put gzips data and uploads it to an S3 bucket.
 func (config *S3Storage) Put(key string, body []byte) error {
 	// *s3manager.UploadOutput
 
@@ -63,18 +66,11 @@ func (config *S3Storage) Put(key string, body []byte) error {
 	uploader := s3manager.NewUploader(s3Session)
 
 	// gzip data
-	var buf bytes.Buffer
-	zw := gzip.NewWriter(&buf)
-	_, err := zw.Write(body)
+	buf, err := gzipBody(body)
 	if err != nil {
 		return err
 	}
 
-	// Bail out if we got an error while compressing.
-	if err := zw.Close(); err != nil {
-		return err
-	}
-
 	// Append ".gz" to the key (filename).
 	key = key + ".gz"
 
@@ -82,7 +78,7 @@ func (config *S3Storage) Put(key string, body []byte) error {
 	upParams := &s3manager.UploadInput{
 		Bucket: &config.s3Bucket,
 		Key:    &key,
-		Body:   &buf,
+		Body:   buf,
 	}
 
 	// Perform an upload.
@@ -91,7 +87,7 @@ func (config *S3Storage) Put(key string, body []byte) error {
 	// return result, err
 }
 
-func (config *S3Storage) PutStream(key string, fp io.Reader) error {
+func (config *S3Storage) PutStream(key string, fp io.Reader, contentType string) error {
 	// *s3manager.UploadOutput
 
 	// The session the S3 Uploader will use
@@ -109,12 +105,98 @@ func (config *S3Storage) PutStream(key string, fp io.Reader) error {
 		Key:    &key,
 		Body:   fp,
 	}
+	if contentType != "" {
+		upParams.ContentType = &contentType
+	}
 
 	// Perform an upload.
 	_, err := uploader.Upload(upParams)
 	return err
 }
 
+func (config *S3Storage) Get(key string) (io.ReadCloser, error) {
+	s3Session := session.Must(session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{Region: aws.String(config.s3Region)},
+	}))
+
+	output, err := s3.New(s3Session).GetObject(&s3.GetObjectInput{
+		Bucket: &config.s3Bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Body, nil
+}
+
+func (config *S3Storage) Exists(key string) (bool, error) {
+	s3Session := session.Must(session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{Region: aws.String(config.s3Region)},
+	}))
+
+	_, err := s3.New(s3Session).HeadObject(&s3.HeadObjectInput{
+		Bucket: &config.s3Bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		var aerr awserr.RequestFailure
+		if errors.As(err, &aerr) && aerr.StatusCode() == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Move copies src to dst within the bucket, then deletes src.
+func (config *S3Storage) Move(src string, dst string) error {
+	s3Session := session.Must(session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{Region: aws.String(config.s3Region)},
+	}))
+	svc := s3.New(s3Session)
+
+	if _, err := svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:     &config.s3Bucket,
+		CopySource: aws.String(url.PathEscape(config.s3Bucket + "/" + src)),
+		Key:        &dst,
+	}); err != nil {
+		return err
+	}
+
+	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: &config.s3Bucket,
+		Key:    &src,
+	})
+	return err
+}
+
+// Touch copies key onto itself, which S3 reports as an ObjectCreated event. Copying in place
+// requires replacing the metadata, so the content type is read first and kept.
+func (config *S3Storage) Touch(key string) error {
+	s3Session := session.Must(session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{Region: aws.String(config.s3Region)},
+	}))
+	svc := s3.New(s3Session)
+
+	head, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: &config.s3Bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:            &config.s3Bucket,
+		CopySource:        aws.String(url.PathEscape(config.s3Bucket + "/" + key)),
+		Key:               &key,
+		ContentType:       head.ContentType,
+		Metadata:          head.Metadata,
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+	})
+	return err
+}
+
 func (config *S3Storage) GetDriverName() string {
 	return config.driverName
 }
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Storage is implemented by every object store backend (S3, local directory).
+type Storage interface {
+	// Put gzips body and stores it under key + ".gz".
+	Put(key string, body []byte) error
+	// PutStream stores the contents of fp under key as-is. contentType is recorded where the
+	// backend supports it and may be empty.
+	PutStream(key string, fp io.Reader, contentType string) error
+	// Get opens the object stored under key. The caller must close it.
+	Get(key string) (io.ReadCloser, error)
+	// Exists reports whether an object is stored under key.
+	Exists(key string) (bool, error)
+	// Move stores the object under src as dst and removes src.
+	Move(src string, dst string) error
+	// Touch stores the object under key again, unchanged, so it is announced as newly created.
+	Touch(key string) error
+	GetDriverName() string
+}
+
+// UserKey returns the key used to store a user's profile (before the ".gz" suffix).
+func UserKey(userID string) string {
+	return path.Join("users", userID+".json")
+}
+
+// MediaKey returns the key used to store a tweet's media entity.
+func MediaKey(userID int64, tweetID string, filename string) string {
+	return path.Join("media", fmt.Sprintf("%d", userID), tweetID, filename)
+}
+
+// ContentKey returns the key of a content-addressed media object: its SHA-256 (hex) and
+// original extension, fanned out by the first two hex digits.
+func ContentKey(hash string, ext string) string {
+	return path.Join("media", "sha256", hash[:2], hash+ext)
+}
+
+// QuarantineKey returns the key a quarantined media object is moved to: the media key with
+// its "media/" prefix replaced by "quarantine/".
+func QuarantineKey(key string) string {
+	return path.Join("quarantine", strings.TrimPrefix(key, "media/"))
+}
+
+// gzipBody compresses body and returns the compressed buffer.
+func gzipBody(body []byte) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+
+	// Bail out if we got an error while compressing.
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
 	"text/tabwriter"
+	"time"
 
+	"github.com/rmrfslashbin/tndx/pkg/database"
 	"github.com/rmrfslashbin/tndx/pkg/events"
 	"github.com/rmrfslashbin/tndx/pkg/glue"
 	"github.com/rmrfslashbin/tndx/pkg/queue"
@@ -27,6 +30,7 @@ var (
 	e     *events.Config
 	q     *queue.Config
 	c     *glue.Config
+	db    database.Database
 
 	// rootCmd is the Viper root command
 	RootCmd = &cobra.Command{
@@ -162,6 +166,12 @@ func setup() {
 		queue.SetSQSURL(outputs.Params[sqs_queue_url].(string)),
 	)
 
+	db = database.NewDDB(
+		database.SetDDBLogger(log),
+		database.SetDDBRegion(aws_region),
+		database.SetDDBTablePrefix(outputs.Params[ddb_table_prefix].(string)),
+	)
+
 	c = glue.NewCrawler(
 		glue.SetLogger(log),
 		glue.SetRegion(aws_region),
@@ -206,6 +216,27 @@ func run() {
 		fmt.Println()
 	}
 
+	if limits, err := db.GetRateLimits(); err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err,
+		}).Fatal("failed to get rate limits")
+	} else {
+		sort.Slice(limits, func(i, j int) bool { return limits[i].Endpoint < limits[j].Endpoint })
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "Endpoint\tRemaining\tLimit\tReset")
+		for _, limit := range limits {
+			// An expired window refills on the next call.
+			remaining := limit.Remaining
+			if limit.Reset <= now.Unix() {
+				remaining = limit.Limit
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", limit.Endpoint, remaining, limit.Limit, time.Unix(limit.Reset, 0).Format(time.RFC3339))
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
 	if ret, err := c.GetCrawlerData(); err != nil {
 		log.WithFields(logrus.Fields{
 			"error": err,
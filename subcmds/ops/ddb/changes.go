@@ -0,0 +1,109 @@
+package ddb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+type Changes struct {
+	UserID *int64                  `json:"user_id" yaml:"user_id"`
+	Domain string                  `json:"domain" yaml:"domain"`
+	Since  int64                   `json:"since" yaml:"since"`
+	Events []*database.HistoryItem `json:"events" yaml:"events"`
+	Count  int                     `json:"count" yaml:"count"`
+}
+
+// parseSince accepts a duration back from now (e.g. 168h) or an RFC3339 time and returns unix ms.
+// An empty value means all history.
+func parseSince(since string) (int64, error) {
+	if since == "" {
+		return 0, nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return time.Now().Add(-d).UnixMilli(), nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return 0, fmt.Errorf("--since must be a duration or an RFC3339 time: %s", since)
+	}
+	return t.UnixMilli(), nil
+}
+
+func changesByUserId(domain string) error {
+	if flags.userid == 0 && flags.screenname != "" {
+		user, _, err := svc.twitter.GetUser(&service.QueryParams{ScreenName: flags.screenname})
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"action": "changesByUserId::GetUser",
+				"error":  err.Error(),
+			}).Error("error getting user")
+			return err
+		}
+		// Set the userid.
+		flags.userid = user.ID
+	}
+
+	since, err := parseSince(flags.since)
+	if err != nil {
+		return err
+	}
+
+	res, err := svc.db.GetHistory(flags.userid, domain, since)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"action": "changesByUserId::GetHistory",
+			"error":  err.Error(),
+			"userid": flags.userid,
+			"domain": domain,
+		}).Error("error getting history for user")
+		return err
+	}
+
+	results := &Changes{
+		UserID: &flags.userid,
+		Domain: domain,
+		Since:  since,
+		Events: res,
+		Count:  len(res),
+	}
+
+	if flags.json {
+		if data, err := json.Marshal(results); err != nil {
+			log.WithFields(logrus.Fields{
+				"error":  err,
+				"action": "changesByUserId::json.Marshal",
+			}).Error("error marshalling changes to json")
+			return err
+		} else {
+			os.Stdout.Write(data)
+		}
+	} else if flags.yaml {
+		if data, err := yaml.Marshal(results); err != nil {
+			log.WithFields(logrus.Fields{
+				"error":  err,
+				"action": "changesByUserId::yaml.Marshal",
+			}).Error("error marshalling changes to yaml")
+			return err
+		} else {
+			os.Stdout.Write(data)
+		}
+	} else {
+		fmt.Printf("Found %d %s changes for user: %d\n", results.Count, domain, *results.UserID)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "Timestamp\tEvent\tUserID")
+		for _, v := range res {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", time.UnixMilli(v.Timestamp).Format(time.RFC3339), v.Event, v.OtherID)
+		}
+		w.Flush()
+	}
+
+	return nil
+}
@@ -26,7 +26,7 @@ type Flags struct {
 }
 
 type Services struct {
-	twitter *service.Config
+	twitter service.Twitter
 	db      *database.DDBDriver
 }
 
@@ -213,6 +213,7 @@ func setup() {
 	svc.twitter = service.New(
 		service.SetConsumerKey(outputs.Params[twitter_api_key].(string)),
 		service.SetConsumerSecret(outputs.Params[twitter_api_secret].(string)),
+		service.SetBaseURL(viper.GetString("TwitterBaseUrl")),
 		service.SetLogger(log),
 	)
 
@@ -23,7 +23,9 @@ type UserByFollower struct {
 }
 
 func runDDBFollowers() error {
-	if flags.followid != 0 {
+	if flags.changes {
+		return changesByUserId("followers")
+	} else if flags.followid != 0 {
 		return byFolloweId()
 	} else {
 		return followersByUserId()
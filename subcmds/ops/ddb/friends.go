@@ -23,7 +23,9 @@ type UsersByFriend struct {
 }
 
 func runDDBFriends() error {
-	if flags.friendid != 0 {
+	if flags.changes {
+		return changesByUserId("friends")
+	} else if flags.friendid != 0 {
 		return byFriendId()
 	} else {
 		return friendsByUserId()
@@ -14,10 +14,13 @@ import (
 )
 
 type Outputs struct {
+	Account   *database.AccountItem   `json:"account" yaml:"account"`
 	Favorites *database.FavoritesItem `json:"favorites" yaml:"favorites"`
 	Followers *database.FollowersItem `json:"followers" yaml:"followers"`
 	Friends   *database.FriendsItem   `json:"friends" yaml:"friends"`
 	Timeline  *database.TweetsItem    `json:"timeline" yaml:"timeline"`
+	Backfill  *database.BackfillItem  `json:"timeline_backfill" yaml:"timeline_backfill"`
+	Gaps      []*database.GapItem     `json:"gaps" yaml:"gaps"`
 }
 
 func runDDBPramsGet() error {
@@ -91,6 +94,47 @@ func runDDBPramsGet() error {
 		outputs.Timeline.LastUpdateTimestamp = time.UnixMilli(resp.LastUpdate)
 	}
 
+	if resp, err := svc.db.GetTimelineBackfillConfig(flags.userid); err != nil {
+		log.WithFields(logrus.Fields{
+			"action": "runDDBPramsGet::GetTimelineBackfillConfig",
+			"error":  err.Error(),
+			"userid": flags.userid,
+		}).Error("error getting timeline backfill config")
+		return err
+	} else {
+		outputs.Backfill = resp
+		outputs.Backfill.LastUpdateTimestamp = time.UnixMilli(resp.LastUpdate)
+	}
+
+	if resp, err := svc.db.GetAccountStatus(flags.userid); err != nil {
+		log.WithFields(logrus.Fields{
+			"action": "runDDBPramsGet::GetAccountStatus",
+			"error":  err.Error(),
+			"userid": flags.userid,
+		}).Error("error getting account status")
+		return err
+	} else {
+		outputs.Account = resp
+		outputs.Account.LastUpdateTimestamp = time.UnixMilli(resp.LastUpdate)
+	}
+
+	for _, kind := range []string{"tweets"} {
+		if resp, err := svc.db.GetGaps(flags.userid, kind); err != nil {
+			log.WithFields(logrus.Fields{
+				"action": "runDDBPramsGet::GetGaps",
+				"error":  err.Error(),
+				"userid": flags.userid,
+				"kind":   kind,
+			}).Error("error getting gaps")
+			return err
+		} else {
+			for _, gap := range resp {
+				gap.LastUpdateTimestamp = time.UnixMilli(gap.LastUpdate)
+				outputs.Gaps = append(outputs.Gaps, gap)
+			}
+		}
+	}
+
 	if flags.json {
 		if data, err := json.Marshal(outputs); err != nil {
 			log.WithFields(logrus.Fields{
@@ -115,12 +159,33 @@ func runDDBPramsGet() error {
 		fmt.Fprintf(w, "%d\tfavorites\t%s\t%d\t%d\n", flags.userid, outputs.Favorites.LastUpdateTimestamp.Format(time.RFC3339), outputs.Favorites.SinceID, outputs.Favorites.MaxID)
 		fmt.Fprintf(w, "%d\ttimeline\t%s\t%d\t%d\n", flags.userid, outputs.Timeline.LastUpdateTimestamp.Format(time.RFC3339), outputs.Timeline.SinceID, outputs.Timeline.MaxID)
 		fmt.Fprintf(w, "\n")
-		fmt.Fprintln(w, "UserID\tDomain\tLastUpdate\tPreviousCursor\tNextCursor")
-		fmt.Fprintf(w, "%d\tfollowers\t%s\t%d\t%d\n", flags.userid, outputs.Followers.LastUpdateTimestamp.Format(time.RFC3339), outputs.Followers.PreviousCursor, outputs.Followers.NextCursor)
-		fmt.Fprintf(w, "%d\tfriends\t%s\t%d\t%d\n", flags.userid, outputs.Friends.LastUpdateTimestamp.Format(time.RFC3339), outputs.Friends.PreviousCursor, outputs.Friends.NextCursor)
+		fmt.Fprintln(w, "UserID\tDomain\tLastUpdate\tPreviousCursor\tNextCursor\tPassStarted\tPassCompleted\tPasses")
+		fmt.Fprintf(w, "%d\tfollowers\t%s\t%d\t%d\t%s\t%s\t%d\n", flags.userid, outputs.Followers.LastUpdateTimestamp.Format(time.RFC3339), outputs.Followers.PreviousCursor, outputs.Followers.NextCursor, passTime(outputs.Followers.PassStarted), passTime(outputs.Followers.PassCompleted), outputs.Followers.Passes)
+		fmt.Fprintf(w, "%d\tfriends\t%s\t%d\t%d\t%s\t%s\t%d\n", flags.userid, outputs.Friends.LastUpdateTimestamp.Format(time.RFC3339), outputs.Friends.PreviousCursor, outputs.Friends.NextCursor, passTime(outputs.Friends.PassStarted), passTime(outputs.Friends.PassCompleted), outputs.Friends.Passes)
+		fmt.Fprintf(w, "\n")
+		fmt.Fprintln(w, "UserID\tDomain\tLastUpdate\tNextMaxID\tCount\tComplete")
+		fmt.Fprintf(w, "%d\ttimeline_backfill\t%s\t%d\t%d\t%t\n", flags.userid, outputs.Backfill.LastUpdateTimestamp.Format(time.RFC3339), outputs.Backfill.NextMaxID, outputs.Backfill.Count, outputs.Backfill.Complete)
+		fmt.Fprintf(w, "\n")
+		fmt.Fprintln(w, "UserID\tDomain\tLastUpdate\tStatus\tStatusSince")
+		fmt.Fprintf(w, "%d\taccount\t%s\t%s\t%s\n", flags.userid, outputs.Account.LastUpdateTimestamp.Format(time.RFC3339), outputs.Account.Status, passTime(outputs.Account.StatusSince))
+		if len(outputs.Gaps) > 0 {
+			fmt.Fprintf(w, "\n")
+			fmt.Fprintln(w, "UserID\tDomain\tLastUpdate\tSinceID\tMaxID\tCount")
+			for _, gap := range outputs.Gaps {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\n", flags.userid, gap.Domain, gap.LastUpdateTimestamp.Format(time.RFC3339), gap.SinceID, gap.MaxID, gap.Count)
+			}
+		}
 		w.Flush()
 		fmt.Println()
 	}
 
 	return nil
 }
+
+// passTime formats a crawl pass timestamp; zero means the pass has not started/completed.
+func passTime(ms int64) string {
+	if ms == 0 {
+		return "-"
+	}
+	return time.UnixMilli(ms).Format(time.RFC3339)
+}
@@ -6,7 +6,7 @@ import (
 
 	"github.com/rmrfslashbin/tndx/pkg/database"
 	"github.com/rmrfslashbin/tndx/pkg/service"
-	"github.com/rmrfslashbin/tndx/pkg/ssmparams"
+	"github.com/rmrfslashbin/tndx/subcmds/ops/opsconfig"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -23,8 +23,8 @@ type Flags struct {
 }
 
 type Services struct {
-	twitter *service.Config
-	db      *database.DDBDriver
+	twitter service.Twitter
+	db      database.Database
 }
 
 var (
@@ -126,61 +126,26 @@ func setup() {
 		}).Fatal("failed to read dotenv file")
 	}
 
-	aws_region := viper.GetString("AwsRegion")
-	aws_profile := viper.GetString("AwsProfile")
-	ddb_table_prefix := viper.GetString("DDBTablePrefix")
+	ddb_table_prefix := opsconfig.TablePrefix(log)
 	twitter_api_key := viper.GetString("TwitterApiKey")
 	twitter_api_secret := viper.GetString("TwitterApiSecret")
 
-	if aws_region == "" {
-		log.Fatal("AwsRegion not set in yaml config file")
-	}
-	if aws_profile == "" {
-		log.Fatal("AwsProfile not set in yaml config file")
-	}
-	if ddb_table_prefix == "" {
-		log.Fatal("DDBTablePrefix not set in yaml config file")
-	}
 	if twitter_api_key == "" {
 		log.Fatal("TwitterApiKey not set in yaml config file")
 	}
 	if twitter_api_secret == "" {
 		log.Fatal("TwitterApiSecret not set in yaml config file")
 	}
-	params := ssmparams.NewSSMParams(
-		ssmparams.SetRegion(aws_region),
-		ssmparams.SetLogger(log),
-	)
-
-	outputs, err := params.GetParams([]string{
-		ddb_table_prefix,
-		twitter_api_key,
-		twitter_api_secret,
-	})
-
-	if err != nil {
-		log.WithFields(logrus.Fields{
-			"action": "getParams",
-			"error":  err.Error(),
-		}).Fatal("error getting parameters.")
-	}
 
-	if len(outputs.InvalidParameters) > 0 {
-		log.WithFields(logrus.Fields{
-			"invalid_parameters": outputs.InvalidParameters,
-		}).Fatal("invalid parameters")
-	}
+	params := opsconfig.Params(log, ddb_table_prefix, twitter_api_key, twitter_api_secret)
 
 	svc.twitter = service.New(
-		service.SetConsumerKey(outputs.Params[twitter_api_key].(string)),
-		service.SetConsumerSecret(outputs.Params[twitter_api_secret].(string)),
+		service.SetConsumerKey(params[twitter_api_key]),
+		service.SetConsumerSecret(params[twitter_api_secret]),
+		service.SetBaseURL(viper.GetString("TwitterBaseUrl")),
 		service.SetLogger(log),
 	)
 
-	svc.db = database.NewDDB(
-		database.SetDDBLogger(log),
-		database.SetDDBTablePrefix(outputs.Params[ddb_table_prefix].(string)),
-		database.SetDDBRegion(aws_region),
-	)
+	svc.db = opsconfig.Database(log, params[ddb_table_prefix])
 
 }
@@ -6,9 +6,9 @@ import (
 
 	"github.com/rmrfslashbin/tndx/pkg/database"
 	"github.com/rmrfslashbin/tndx/pkg/service"
-	"github.com/rmrfslashbin/tndx/pkg/ssmparams"
 	"github.com/rmrfslashbin/tndx/subcmds/ops/ddb/exports"
 	"github.com/rmrfslashbin/tndx/subcmds/ops/ddb/params"
+	"github.com/rmrfslashbin/tndx/subcmds/ops/opsconfig"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -23,13 +23,15 @@ type Flags struct {
 	friendid   int64
 	followid   int64
 	screenname string
+	changes    bool
+	since      string
 	json       bool
 	yaml       bool
 }
 
 type Services struct {
-	twitter *service.Config
-	db      *database.DDBDriver
+	twitter service.Twitter
+	db      database.Database
 }
 
 var (
@@ -72,6 +74,9 @@ var (
 			if flags.friendid == 0 && flags.userid == 0 && flags.screenname == "" {
 				log.Fatal("must specify --friendid or at least one --userid/--screenname")
 			}
+			if flags.changes && flags.friendid != 0 {
+				log.Fatal("--changes requires --userid/--screenname")
+			}
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			setup()
@@ -92,6 +97,9 @@ var (
 			if flags.followid == 0 && flags.userid == 0 && flags.screenname == "" {
 				log.Fatal("must specify --followid or at least one --userid/--screenname")
 			}
+			if flags.changes && flags.followid != 0 {
+				log.Fatal("--changes requires --userid/--screenname")
+			}
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			setup()
@@ -123,12 +131,16 @@ func init() {
 	followersCmd.Flags().StringVarP(&flags.screenname, "screenname", "s", "", "screenname of user to fetch followers for")
 	followersCmd.Flags().Int64VarP(&flags.userid, "userid", "u", 0, "userid of user to fetch followers for")
 	followersCmd.Flags().Int64VarP(&flags.followid, "followid", "f", 0, "followid to fetch followers for")
+	followersCmd.Flags().BoolVarP(&flags.changes, "changes", "c", false, "show follow/unfollow history instead of current followers")
+	followersCmd.Flags().StringVar(&flags.since, "since", "", "with --changes, only show events since a duration ago (e.g. 168h) or an RFC3339 time")
 	followersCmd.Flags().BoolVarP(&flags.json, "json", "j", false, "output in json format")
 	followersCmd.Flags().BoolVarP(&flags.yaml, "yaml", "y", false, "output in yaml format")
 
 	friendsCmd.Flags().StringVarP(&flags.screenname, "screenname", "s", "", "screenname of user to fetch friends for")
 	friendsCmd.Flags().Int64VarP(&flags.userid, "userid", "u", 0, "userid of user to fetch friends for")
 	friendsCmd.Flags().Int64VarP(&flags.friendid, "friendid", "f", 0, "friendid to fetch frineds  for")
+	friendsCmd.Flags().BoolVarP(&flags.changes, "changes", "c", false, "show follow/unfollow history instead of current friends")
+	friendsCmd.Flags().StringVar(&flags.since, "since", "", "with --changes, only show events since a duration ago (e.g. 168h) or an RFC3339 time")
 	friendsCmd.Flags().BoolVarP(&flags.json, "json", "j", false, "output in json format")
 	friendsCmd.Flags().BoolVarP(&flags.yaml, "yaml", "y", false, "output in yaml format")
 
@@ -172,61 +184,26 @@ func setup() {
 		}).Fatal("failed to read dotenv file")
 	}
 
-	aws_region := viper.GetString("AwsRegion")
-	aws_profile := viper.GetString("AwsProfile")
-	ddb_table_prefix := viper.GetString("DDBTablePrefix")
+	ddb_table_prefix := opsconfig.TablePrefix(log)
 	twitter_api_key := viper.GetString("TwitterApiKey")
 	twitter_api_secret := viper.GetString("TwitterApiSecret")
 
-	if aws_region == "" {
-		log.Fatal("AwsRegion not set in yaml config file")
-	}
-	if aws_profile == "" {
-		log.Fatal("AwsProfile not set in yaml config file")
-	}
-	if ddb_table_prefix == "" {
-		log.Fatal("DDBTablePrefix not set in yaml config file")
-	}
 	if twitter_api_key == "" {
 		log.Fatal("TwitterApiKey not set in yaml config file")
 	}
 	if twitter_api_secret == "" {
 		log.Fatal("TwitterApiSecret not set in yaml config file")
 	}
-	params := ssmparams.NewSSMParams(
-		ssmparams.SetRegion(aws_region),
-		ssmparams.SetLogger(log),
-	)
-
-	outputs, err := params.GetParams([]string{
-		ddb_table_prefix,
-		twitter_api_key,
-		twitter_api_secret,
-	})
-
-	if err != nil {
-		log.WithFields(logrus.Fields{
-			"action": "getParams",
-			"error":  err.Error(),
-		}).Fatal("error getting parameters.")
-	}
 
-	if len(outputs.InvalidParameters) > 0 {
-		log.WithFields(logrus.Fields{
-			"invalid_parameters": outputs.InvalidParameters,
-		}).Fatal("invalid parameters")
-	}
+	params := opsconfig.Params(log, ddb_table_prefix, twitter_api_key, twitter_api_secret)
 
 	svc.twitter = service.New(
-		service.SetConsumerKey(outputs.Params[twitter_api_key].(string)),
-		service.SetConsumerSecret(outputs.Params[twitter_api_secret].(string)),
+		service.SetConsumerKey(params[twitter_api_key]),
+		service.SetConsumerSecret(params[twitter_api_secret]),
+		service.SetBaseURL(viper.GetString("TwitterBaseUrl")),
 		service.SetLogger(log),
 	)
 
-	svc.db = database.NewDDB(
-		database.SetDDBLogger(log),
-		database.SetDDBTablePrefix(outputs.Params[ddb_table_prefix].(string)),
-		database.SetDDBRegion(aws_region),
-	)
+	svc.db = opsconfig.Database(log, params[ddb_table_prefix])
 
 }
@@ -0,0 +1,273 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/analyzer"
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/faketwitter"
+	"github.com/rmrfslashbin/tndx/pkg/fetch"
+	"github.com/rmrfslashbin/tndx/pkg/kinesis"
+	"github.com/rmrfslashbin/tndx/pkg/media"
+	"github.com/rmrfslashbin/tndx/pkg/processor"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/runner"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Flags struct contains settings for the root command
+type Flags struct {
+	loglevel         string
+	directory        string
+	runner           string
+	userids          []int64
+	screennames      []string
+	functions        []string
+	fixtures         string
+	twitterBaseURL   string
+	twitterAPIKey    string
+	twitterAPISecret string
+	maxMessages      int
+	maxRetries       int
+	maxTweetDepth    int
+	maxThreadDepth   int
+	seenTweetTTL     time.Duration
+	hydrateUsersTTL  time.Duration
+	mediaTimeout     time.Duration
+	analyzer         string
+}
+
+// service stores drivers and clients
+type services struct {
+	twitterClient service.Twitter
+	storage       *storage.LocalStorage
+	db            *database.BoltDriver
+	queue         *queue.Local
+	kinesis       *kinesis.Local
+	media         *media.Config
+	fake          *faketwitter.Server
+}
+
+var (
+	flags Flags
+	log   *logrus.Logger
+	svc   services
+
+	// rootCmd is the Viper root command
+	RootCmd = &cobra.Command{
+		Use:   "local",
+		Short: "run the pipeline locally without AWS",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// Set the log level
+			switch flags.loglevel {
+			case "error":
+				log.SetLevel(logrus.ErrorLevel)
+			case "warn":
+				log.SetLevel(logrus.WarnLevel)
+			case "info":
+				log.SetLevel(logrus.InfoLevel)
+			case "debug":
+				log.SetLevel(logrus.DebugLevel)
+			case "trace":
+				log.SetLevel(logrus.TraceLevel)
+			default:
+				log.SetLevel(logrus.InfoLevel)
+			}
+		},
+	}
+
+	cmdRun = &cobra.Command{
+		Use:   "run",
+		Short: "run the runner, processor and media stages against local stand-ins",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			cmd.Parent().PersistentPreRun(cmd.Parent(), args)
+			for _, function := range flags.functions {
+				if _, ok := runner.Functions[function]; !ok {
+					cmd.Usage()
+					log.Fatalf("invalid function %s; should be one of user, friends, followers, favorites, timeline", function)
+				}
+			}
+			setup()
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			teardown()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runLocal(); err != nil {
+				teardown()
+				log.Fatal(err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	flags = Flags{}
+	log = logrus.New()
+	log.SetLevel(logrus.InfoLevel)
+	log.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+
+	RootCmd.PersistentFlags().StringVarP(&flags.loglevel, "loglevel", "", "info", "[error|warn|info|debug|trace]")
+
+	cmdRun.PersistentFlags().StringVarP(&flags.directory, "dir", "", "tndx-local", "directory for the local database, objects and tweet records")
+	cmdRun.PersistentFlags().StringVarP(&flags.runner, "runner", "", "local", "runner")
+	cmdRun.PersistentFlags().Int64SliceVarP(&flags.userids, "userid", "", nil, "userid to enroll in the runner")
+	cmdRun.PersistentFlags().StringSliceVarP(&flags.screennames, "screenname", "", nil, "screenname to enroll in the runner")
+	cmdRun.PersistentFlags().StringSliceVarP(&flags.functions, "function", "", []string{"user", "timeline", "favorites", "followers", "friends"}, "runner functions to schedule")
+	cmdRun.PersistentFlags().StringVarP(&flags.fixtures, "fixtures", "", "", "serve the Twitter API from a fake server loaded with this fixtures file")
+	cmdRun.PersistentFlags().StringVarP(&flags.twitterBaseURL, "twitter-base-url", "", "", "Twitter API base URL (env TWITTER_BASE_URL)")
+	cmdRun.PersistentFlags().StringVarP(&flags.twitterAPIKey, "twitter-api-key", "", "", "Twitter API key (env TWITTER_API_KEY)")
+	cmdRun.PersistentFlags().StringVarP(&flags.twitterAPISecret, "twitter-api-secret", "", "", "Twitter API secret (env TWITTER_API_SECRET)")
+	cmdRun.PersistentFlags().IntVarP(&flags.maxMessages, "max-messages", "", 10000, "stop after processing this many queued messages; 0 for no limit")
+	cmdRun.PersistentFlags().IntVarP(&flags.maxRetries, "max-retries", "", 3, "times a message that failed with a transient error is retried")
+	cmdRun.PersistentFlags().IntVarP(&flags.maxTweetDepth, "max-tweet-depth", "", processor.DefaultMaxTweetDepth, "retweet/quote hops to follow from crawled tweets")
+	cmdRun.PersistentFlags().IntVarP(&flags.maxThreadDepth, "max-thread-depth", "", processor.DefaultMaxThreadDepth, "reply hops to walk up from crawled replies; 0 to disable")
+	cmdRun.PersistentFlags().DurationVarP(&flags.seenTweetTTL, "seen-tweet-ttl", "", processor.DefaultSeenTweetTTL, "how long a fetched retweet/quote is not fetched again")
+	cmdRun.PersistentFlags().DurationVarP(&flags.hydrateUsersTTL, "hydrate-users-ttl", "", processor.DefaultHydrateUsersTTL, "how long a stored follower/friend profile is not looked up again")
+	cmdRun.PersistentFlags().DurationVarP(&flags.mediaTimeout, "media-fetch-timeout", "", fetch.DefaultTimeout, "how long a media download may wait for headers or more data")
+	cmdRun.PersistentFlags().StringVarP(&flags.analyzer, "analyzer", "", "local", "media analyzer [local|none]")
+
+	RootCmd.AddCommand(
+		cmdRun,
+	)
+}
+
+func setup() {
+	if flags.twitterBaseURL == "" {
+		flags.twitterBaseURL = os.Getenv("TWITTER_BASE_URL")
+	}
+	if flags.twitterAPIKey == "" {
+		flags.twitterAPIKey = os.Getenv("TWITTER_API_KEY")
+	}
+	if flags.twitterAPISecret == "" {
+		flags.twitterAPISecret = os.Getenv("TWITTER_API_SECRET")
+	}
+
+	if flags.fixtures != "" {
+		fp, err := os.Open(flags.fixtures)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"fixtures": flags.fixtures,
+				"error":    err,
+			}).Fatal("unable to open fixtures")
+		}
+		defer fp.Close()
+
+		svc.fake = faketwitter.New(
+			faketwitter.SetLogger(log),
+		)
+		if err := svc.fake.Load(fp); err != nil {
+			log.WithFields(logrus.Fields{
+				"fixtures": flags.fixtures,
+				"error":    err,
+			}).Fatal("unable to load fixtures")
+		}
+		svc.fake.Start()
+		flags.twitterBaseURL = svc.fake.URL()
+
+		// The fake server accepts any credentials.
+		if flags.twitterAPIKey == "" {
+			flags.twitterAPIKey = "local"
+		}
+		if flags.twitterAPISecret == "" {
+			flags.twitterAPISecret = "local"
+		}
+	}
+
+	if flags.twitterAPIKey == "" || flags.twitterAPISecret == "" {
+		log.Fatal("--twitter-api-key and --twitter-api-secret (or --fixtures) must be set")
+	}
+
+	directory, err := filepath.Abs(flags.directory)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"dir":   flags.directory,
+			"error": err,
+		}).Fatal("unable to resolve directory")
+	}
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		log.WithFields(logrus.Fields{
+			"dir":   directory,
+			"error": err,
+		}).Fatal("unable to create directory")
+	}
+	flags.directory = directory
+
+	svc.db = database.NewBolt(
+		database.SetBoltLogger(log),
+		database.SetBoltPath(filepath.Join(directory, "tndx.db")),
+	)
+
+	svc.twitterClient = service.New(
+		service.SetConsumerKey(flags.twitterAPIKey),
+		service.SetConsumerSecret(flags.twitterAPISecret),
+		service.SetBaseURL(flags.twitterBaseURL),
+		service.SetBudget(svc.db),
+		service.SetLogger(log),
+	)
+
+	objects := filepath.Join(directory, "objects")
+	svc.storage = storage.NewLocalStorage(
+		storage.SetLocalDirectory(objects),
+		storage.SetLocalLogger(log),
+		storage.SetLocalNotify(func(key string) {
+			// Mirrors the S3 ObjectCreated trigger on the media/ prefix.
+			if !strings.HasPrefix(key, "media/") {
+				return
+			}
+			if err := svc.media.Created(objects, key); err != nil {
+				log.WithFields(logrus.Fields{
+					"action": "local::media::Created",
+					"key":    key,
+					"error":  err,
+				}).Error("error processing media")
+			}
+		}),
+	)
+
+	opts := []func(*media.Config){
+		media.SetLogger(log),
+		media.SetDatabase(svc.db),
+		media.SetStorage(svc.storage),
+	}
+	switch flags.analyzer {
+	case "local":
+		opts = append(opts, media.SetAnalyzer(analyzer.NewLocal(
+			analyzer.SetLocalLogger(log),
+			analyzer.SetLocalStorage(svc.storage),
+		)))
+	case "none":
+	default:
+		log.Fatalf("unknown analyzer %s", flags.analyzer)
+	}
+	svc.media = media.New(opts...)
+
+	svc.kinesis = kinesis.NewLocal(
+		kinesis.SetLocalLogger(log),
+		kinesis.SetLocalPath(filepath.Join(directory, "tweets.json")),
+	)
+
+	svc.queue = queue.NewLocal(
+		queue.SetLocalLogger(log),
+	)
+}
+
+func teardown() {
+	if svc.db != nil {
+		svc.db.Close()
+		svc.db = nil
+	}
+	if svc.fake != nil {
+		svc.fake.Close()
+		svc.fake = nil
+	}
+}
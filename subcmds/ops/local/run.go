@@ -0,0 +1,141 @@
+package local
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/fetch"
+	"github.com/rmrfslashbin/tndx/pkg/processor"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/runner"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// retryDelaySeconds is how long a message that failed with a transient error waits, per
+// attempt, before it is retried.
+const retryDelaySeconds = 10
+
+func runLocal() error {
+	userids := flags.userids
+	for _, screenname := range flags.screennames {
+		user, _, err := svc.twitterClient.GetUser(&service.QueryParams{ScreenName: screenname})
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"action":     "runLocal::GetUser",
+				"screenname": screenname,
+				"error":      err.Error(),
+			}).Error("error getting user")
+			return err
+		}
+		userids = append(userids, user.ID)
+	}
+
+	// Enroll the requested users for the scheduled functions.
+	var newFlags database.Bits
+	for _, function := range flags.functions {
+		newFlags = database.Set(newFlags, runner.Functions[function])
+	}
+	for _, userid := range userids {
+		if err := svc.db.PutRunnerFlags(&database.RunnerItem{RunnerName: flags.runner, UserID: userid, Flags: newFlags}); err != nil {
+			log.WithFields(logrus.Fields{
+				"action": "runLocal::PutRunnerFlags",
+				"userid": userid,
+				"error":  err.Error(),
+			}).Error("error putting runner flags")
+			return err
+		}
+	}
+
+	// The bootstrap names SSM parameters in AWS; the local stand-ins ignore them.
+	bootstrap := &queue.Bootstrap{
+		DDBTablePrefix:   "local",
+		DeliveryStream:   "local",
+		SQSRunnerURL:     "local",
+		S3Bucket:         "local",
+		TwitterAPIKey:    "local",
+		TwitterAPISecret: "local",
+	}
+
+	run := runner.New(
+		runner.SetLogger(log),
+		runner.SetDatabase(svc.db),
+		runner.SetQueue(svc.queue),
+	)
+	for _, function := range flags.functions {
+		if err := run.Run(flags.runner, function, bootstrap); err != nil {
+			return err
+		}
+	}
+
+	proc := processor.New(
+		processor.SetLogger(log),
+		processor.SetTwitter(svc.twitterClient),
+		processor.SetStorage(svc.storage),
+		processor.SetDatabase(svc.db),
+		processor.SetQueue(svc.queue),
+		processor.SetKinesis(svc.kinesis),
+		processor.SetMaxTweetDepth(flags.maxTweetDepth),
+		processor.SetMaxThreadDepth(flags.maxThreadDepth),
+		processor.SetSeenTweetTTL(flags.seenTweetTTL),
+		processor.SetHydrateUsersTTL(flags.hydrateUsersTTL),
+		processor.SetFetcher(fetch.New(
+			fetch.SetLogger(log),
+			fetch.SetTimeout(flags.mediaTimeout),
+		)),
+	)
+
+	// Drain the queue, including every message the processor enqueues along the way. Messages
+	// deferred by a rate limit or a retry are waited for rather than left behind.
+	retries := map[*queue.SendMessage]int{}
+	var processed, retried, failed, dropped int
+	for flags.maxMessages == 0 || processed < flags.maxMessages {
+		message, ok := svc.queue.Receive()
+		if !ok {
+			due, ok := svc.queue.NextDue()
+			if !ok {
+				break
+			}
+			log.WithFields(logrus.Fields{
+				"action":    "runLocal::NextDue",
+				"due":       due.Format(time.RFC3339),
+				"remaining": svc.queue.Len(),
+			}).Info("waiting for delayed messages")
+			time.Sleep(time.Until(due))
+			continue
+		}
+		processed++
+		err := proc.Run(message.Bootstrap, message.Message)
+		switch {
+		case err == nil:
+			delete(retries, message)
+		case processor.IsPermanent(err):
+			delete(retries, message)
+			dropped++
+		case retries[message] < flags.maxRetries:
+			retries[message]++
+			retried++
+			svc.queue.Requeue(message, int32(retries[message]*retryDelaySeconds))
+		default:
+			delete(retries, message)
+			failed++
+		}
+	}
+
+	remaining := svc.queue.Len()
+	log.WithFields(logrus.Fields{
+		"dir":       flags.directory,
+		"processed": processed,
+		"retried":   retried,
+		"failed":    failed,
+		"dropped":   dropped,
+		"remaining": remaining,
+		"records":   svc.kinesis.Records(),
+	}).Info("local run complete")
+
+	if failed > 0 || remaining > 0 {
+		return fmt.Errorf("local run incomplete: %d messages failed, %d remaining", failed, remaining)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package media
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunMediaDeferred lists media whose analysis was deferred by the daily budget. With
+// --reanalyze, each object is stored again so the rekognition stage analyzes it.
+func RunMediaDeferred() error {
+	items, err := svc.db.GetDeferredMedia()
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"action": "RunMediaDeferred::GetDeferredMedia",
+			"error":  err.Error(),
+		}).Error("error getting deferred media")
+		return err
+	}
+
+	if len(items) == 0 {
+		log.Info("no deferred media")
+		return nil
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].S3Key != items[j].S3Key {
+			return items[i].S3Key < items[j].S3Key
+		}
+		return items[i].TweetID < items[j].TweetID
+	})
+
+	// Content-addressed objects are shared by several tweets; analyzing once covers them all.
+	touched := map[string]bool{}
+	for _, item := range items {
+		fmt.Printf("%s  user=%d tweet=%d\n", item.S3Key, item.UserID, item.TweetID)
+		if !flags.reanalyze || touched[item.S3Key] {
+			continue
+		}
+		touched[item.S3Key] = true
+		if err := svc.storage.Touch(item.S3Key); err != nil {
+			log.WithFields(logrus.Fields{
+				"action": "RunMediaDeferred::storage::Touch",
+				"error":  err.Error(),
+				"s3key":  item.S3Key,
+			}).Error("error reanalyzing media")
+			return err
+		}
+	}
+
+	if flags.reanalyze {
+		log.WithFields(logrus.Fields{
+			"items":   len(items),
+			"objects": len(touched),
+		}).Info("deferred media sent for analysis")
+	}
+	return nil
+}
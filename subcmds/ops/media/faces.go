@@ -0,0 +1,45 @@
+package media
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunMediaFaces lists the tweets with media showing the face cluster --cluster, one line per
+// tweet with the matching objects.
+func RunMediaFaces() error {
+	items, err := svc.db.GetMediaByClusterId(flags.cluster)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"action": "RunMediaFaces::GetMediaByClusterId",
+			"error":  err.Error(),
+		}).Error("error getting media for cluster")
+		return err
+	}
+
+	if len(items) == 0 {
+		log.WithFields(logrus.Fields{
+			"cluster": flags.cluster,
+		}).Info("no media found for cluster")
+		return nil
+	}
+
+	tweets := map[int64][]string{}
+	for _, item := range items {
+		tweets[item.TweetID] = append(tweets[item.TweetID], item.MediaKey)
+	}
+	tweetIDs := make([]int64, 0, len(tweets))
+	for tweetID := range tweets {
+		tweetIDs = append(tweetIDs, tweetID)
+	}
+	sort.Slice(tweetIDs, func(i, j int) bool { return tweetIDs[i] < tweetIDs[j] })
+
+	for _, tweetID := range tweetIDs {
+		keys := tweets[tweetID]
+		sort.Strings(keys)
+		fmt.Printf("%d  %v\n", tweetID, keys)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package media
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/sirupsen/logrus"
+)
+
+func RunMediaRetries() error {
+	items, err := svc.db.GetMediaRetries()
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"action": "RunMediaRetries::GetMediaRetries",
+			"error":  err.Error(),
+		}).Error("error getting media retries")
+		return err
+	}
+
+	if len(items) == 0 {
+		log.Info("no failed media downloads")
+		return nil
+	}
+
+	for _, item := range items {
+		log.WithFields(logrus.Fields{
+			"entityURL":  item.EntityURL,
+			"userid":     item.UserID,
+			"tweetId":    item.TweetID,
+			"mediaType":  item.MediaType,
+			"status":     item.Status,
+			"attempts":   item.Attempts,
+			"lastError":  item.LastError,
+			"lastUpdate": time.UnixMilli(item.LastUpdate),
+		}).Info("failed media download")
+
+		if !flags.requeue {
+			continue
+		}
+		bootstrap.Function = "entities"
+		if err := svc.queue.SendRunnerMessage(&queue.SendMessage{
+			Bootstrap: bootstrap,
+			Message: &queue.ProcessorMessage{
+				UserID:    item.UserID,
+				TweetID:   item.TweetID,
+				EntityURL: item.EntityURL,
+				MediaType: item.MediaType,
+			},
+		}); err != nil {
+			log.WithFields(logrus.Fields{
+				"action":    "RunMediaRetries::queue::SendRunnerMessage",
+				"error":     err.Error(),
+				"entityURL": item.EntityURL,
+			}).Error("error sending message to queue")
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package media
+
+import (
+	"os"
+	"path"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/queue"
+	"github.com/rmrfslashbin/tndx/pkg/storage"
+	"github.com/rmrfslashbin/tndx/subcmds/ops/opsconfig"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Flags struct contains settings for the root command
+type Flags struct {
+	loglevel   string
+	dotenvPath string
+	requeue    bool
+	s3key      string
+	distance   int
+	cluster    string
+	reanalyze  bool
+}
+
+// service stores drivers and clients
+type services struct {
+	db      database.Database
+	queue   queue.Queue
+	storage storage.Storage
+}
+
+var (
+	flags     Flags
+	log       *logrus.Logger
+	svc       services
+	bootstrap *queue.Bootstrap
+
+	// rootCmd is the Viper root command
+	RootCmd = &cobra.Command{
+		Use:   "media",
+		Short: "inspect archived media",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// Set the log level
+			switch flags.loglevel {
+			case "error":
+				log.SetLevel(logrus.ErrorLevel)
+			case "warn":
+				log.SetLevel(logrus.WarnLevel)
+			case "info":
+				log.SetLevel(logrus.InfoLevel)
+			case "debug":
+				log.SetLevel(logrus.DebugLevel)
+			case "trace":
+				log.SetLevel(logrus.TraceLevel)
+			default:
+				log.SetLevel(logrus.InfoLevel)
+			}
+			setup()
+		},
+	}
+
+	cmdDeferred = &cobra.Command{
+		Use:   "deferred",
+		Short: "list media whose analysis was deferred by the daily budget, optionally reanalyzing it",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			cmd.Parent().PersistentPreRun(cmd.Parent(), args)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := RunMediaDeferred(); err != nil {
+				log.Fatal(err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmdFaces = &cobra.Command{
+		Use:   "faces",
+		Short: "list tweets with media showing the face cluster --cluster",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			cmd.Parent().PersistentPreRun(cmd.Parent(), args)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := RunMediaFaces(); err != nil {
+				log.Fatal(err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmdRetries = &cobra.Command{
+		Use:   "retries",
+		Short: "list failed media downloads, optionally queueing them again",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			cmd.Parent().PersistentPreRun(cmd.Parent(), args)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := RunMediaRetries(); err != nil {
+				log.Fatal(err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmdSimilar = &cobra.Command{
+		Use:   "similar",
+		Short: "list media that looks like the media stored under --s3key",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			cmd.Parent().PersistentPreRun(cmd.Parent(), args)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := RunMediaSimilar(); err != nil {
+				log.Fatal(err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	flags = Flags{}
+	log = logrus.New()
+	log.SetLevel(logrus.InfoLevel)
+	log.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+
+	RootCmd.PersistentFlags().StringVarP(&flags.loglevel, "loglevel", "", "info", "[error|warn|info|debug|trace]")
+	RootCmd.PersistentFlags().StringVarP(&flags.dotenvPath, "dotenv", "", "", "dotenv path")
+
+	cmdDeferred.Flags().BoolVarP(&flags.reanalyze, "reanalyze", "", false, "store each deferred object again so it is analyzed")
+
+	cmdFaces.Flags().StringVarP(&flags.cluster, "cluster", "", "", "face cluster id")
+	cmdFaces.MarkFlagRequired("cluster")
+
+	cmdRetries.Flags().BoolVarP(&flags.requeue, "requeue", "", false, "send an entities message for each failed download")
+
+	cmdSimilar.Flags().StringVarP(&flags.s3key, "s3key", "", "", "key of the media to compare against")
+	cmdSimilar.Flags().IntVarP(&flags.distance, "distance", "", 10, "maximum perceptual hash distance, in bits (0-64)")
+	cmdSimilar.MarkFlagRequired("s3key")
+
+	RootCmd.AddCommand(
+		cmdDeferred,
+		cmdFaces,
+		cmdRetries,
+		cmdSimilar,
+	)
+}
+
+func setup() {
+	if flags.dotenvPath == "" {
+		// get platform specific user config directory
+		configHome, err := os.UserConfigDir()
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"error": err,
+			}).Fatal("could not get user config directory and dotenv file not set")
+		}
+		viper.SetConfigName("config")
+		viper.SetConfigType("yaml")
+		viper.AddConfigPath(path.Join(configHome, "tndx"))
+		viper.AddConfigPath(".")
+	} else {
+		flags.dotenvPath = path.Clean(flags.dotenvPath)
+		viper.SetConfigFile(flags.dotenvPath)
+		if _, err := os.Stat(flags.dotenvPath); err != nil {
+			log.WithFields(logrus.Fields{
+				"path":  flags.dotenvPath,
+				"error": err,
+			}).Fatal("unable to load dotenv")
+		}
+	}
+
+	if err := viper.ReadInConfig(); err != nil {
+		log.WithFields(logrus.Fields{
+			"path": flags.dotenvPath,
+			"err":  err,
+		}).Fatal("failed to read dotenv file")
+	}
+
+	aws_region := viper.GetString("AwsRegion")
+	aws_profile := viper.GetString("AwsProfile")
+	ddb_table_prefix := opsconfig.TablePrefix(log)
+	s3_bucket := viper.GetString("S3Bucket")
+	sqs_queue_url := viper.GetString("SQSQueueUrl")
+	tweet_delivery_stream := viper.GetString("TweetDeliveryStream")
+	twitter_api_key := viper.GetString("TwitterApiKey")
+	twitter_api_secret := viper.GetString("TwitterApiSecret")
+
+	if flags.requeue && sqs_queue_url == "" {
+		log.Fatal("SQSQueueUrl not set in yaml config file")
+	}
+	if flags.reanalyze && s3_bucket == "" {
+		log.Fatal("S3Bucket not set in yaml config file")
+	}
+
+	names := []string{
+		ddb_table_prefix,
+	}
+	if flags.requeue {
+		names = append(names, sqs_queue_url)
+	}
+	if flags.reanalyze {
+		names = append(names, s3_bucket)
+	}
+	params := opsconfig.Params(log, names...)
+
+	svc.db = opsconfig.Database(log, params[ddb_table_prefix])
+
+	if flags.requeue {
+		svc.queue = queue.NewSQS(
+			queue.SetLogger(log),
+			queue.SetRegion(aws_region),
+			queue.SetProfile(aws_profile),
+			queue.SetSQSURL(params[sqs_queue_url]),
+		)
+	}
+
+	if flags.reanalyze {
+		svc.storage = storage.NewS3Storage(
+			storage.SetLogger(log),
+			storage.SetS3Bucket(params[s3_bucket]),
+			storage.SetS3Region(aws_region),
+		)
+	}
+
+	bootstrap = &queue.Bootstrap{
+		DDBTablePrefix:   ddb_table_prefix,
+		DeliveryStream:   tweet_delivery_stream,
+		SQSRunnerURL:     sqs_queue_url,
+		S3Bucket:         s3_bucket,
+		TwitterAPIKey:    twitter_api_key,
+		TwitterAPISecret: twitter_api_secret,
+	}
+}
@@ -0,0 +1,84 @@
+package media
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/phash"
+	"github.com/sirupsen/logrus"
+)
+
+// similarMedia is a media item within the distance limit of the requested one.
+type similarMedia struct {
+	item     *database.MediaItem
+	distance int
+}
+
+func RunMediaSimilar() error {
+	items, err := svc.db.GetMediaHashes()
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"action": "RunMediaSimilar::GetMediaHashes",
+			"error":  err.Error(),
+		}).Error("error getting media hashes")
+		return err
+	}
+
+	var target string
+	for _, item := range items {
+		if item.S3Key == flags.s3key {
+			target = item.PHash
+			break
+		}
+	}
+	if target == "" {
+		return fmt.Errorf("no perceptual hash recorded for %s", flags.s3key)
+	}
+	want, err := phash.Parse(target)
+	if err != nil {
+		return err
+	}
+
+	matches := []similarMedia{}
+	for _, item := range items {
+		if item.S3Key == flags.s3key {
+			continue
+		}
+		hash, err := phash.Parse(item.PHash)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"action": "RunMediaSimilar::phash::Parse",
+				"error":  err.Error(),
+				"s3key":  item.S3Key,
+			}).Warn("invalid perceptual hash")
+			continue
+		}
+		if distance := phash.Distance(want, hash); distance <= flags.distance {
+			matches = append(matches, similarMedia{item: item, distance: distance})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		if matches[i].item.S3Key != matches[j].item.S3Key {
+			return matches[i].item.S3Key < matches[j].item.S3Key
+		}
+		return matches[i].item.TweetID < matches[j].item.TweetID
+	})
+
+	if len(matches) == 0 {
+		log.WithFields(logrus.Fields{
+			"s3key":    flags.s3key,
+			"distance": flags.distance,
+		}).Info("no similar media found")
+		return nil
+	}
+
+	for _, match := range matches {
+		fmt.Printf("%2d  %s  user=%d tweet=%d\n", match.distance, match.item.S3Key, match.item.UserID, match.item.TweetID)
+	}
+	return nil
+}
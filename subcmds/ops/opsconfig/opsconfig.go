@@ -0,0 +1,100 @@
+// Package opsconfig resolves the settings shared by the ops commands from the yaml config
+// already loaded into viper. Setting BoltPath runs a command entirely locally: the embedded
+// database is used and the config values are taken as they are instead of as SSM parameter
+// names, so no AWS credentials are needed.
+package opsconfig
+
+import (
+	"github.com/rmrfslashbin/ssmparams"
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Local reports whether BoltPath selects the embedded database.
+func Local() bool {
+	return viper.GetString("BoltPath") != ""
+}
+
+// Params returns the values of the SSM parameters named. In local mode no parameters are
+// fetched and each name is returned as its own value. Empty names are skipped.
+func Params(log *logrus.Logger, names ...string) map[string]string {
+	values := map[string]string{}
+	wanted := []string{}
+	for _, name := range names {
+		if name != "" {
+			wanted = append(wanted, name)
+		}
+	}
+	if Local() {
+		for _, name := range wanted {
+			values[name] = name
+		}
+		return values
+	}
+
+	aws_region := viper.GetString("AwsRegion")
+	aws_profile := viper.GetString("AwsProfile")
+	if aws_region == "" {
+		log.Fatal("AwsRegion not set in yaml config file")
+	}
+	if aws_profile == "" {
+		log.Fatal("AwsProfile not set in yaml config file")
+	}
+
+	// Set up a new ssmparams client
+	params, err := ssmparams.New(
+		ssmparams.SetProfile(aws_profile),
+		ssmparams.SetRegion(aws_region),
+	)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err,
+		}).Fatal("error fetching ssm params")
+	}
+
+	outputs, err := params.GetParams(wanted)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(outputs.InvalidParameters) > 0 {
+		log.WithFields(logrus.Fields{
+			"InvalidParameters": outputs.InvalidParameters,
+		}).Fatal("invalid parameters")
+	}
+
+	for _, name := range wanted {
+		values[name] = outputs.Parameters[name].(string)
+	}
+	return values
+}
+
+// TablePrefix returns the DDBTablePrefix parameter name from the config. It is only
+// required outside local mode.
+func TablePrefix(log *logrus.Logger) string {
+	ddb_table_prefix := viper.GetString("DDBTablePrefix")
+	if ddb_table_prefix == "" && !Local() {
+		log.Fatal("DDBTablePrefix not set in yaml config file")
+	}
+	if Local() {
+		return ""
+	}
+	return ddb_table_prefix
+}
+
+// Database opens the bolt file at BoltPath in local mode, otherwise the DynamoDB tables
+// under tablePrefix (the resolved DDBTablePrefix parameter).
+func Database(log *logrus.Logger, tablePrefix string) database.Database {
+	if bolt_path := viper.GetString("BoltPath"); bolt_path != "" {
+		return database.NewBolt(
+			database.SetBoltLogger(log),
+			database.SetBoltPath(bolt_path),
+		)
+	}
+	return database.NewDDB(
+		database.SetDDBLogger(log),
+		database.SetDDBTablePrefix(tablePrefix),
+		database.SetDDBRegion(viper.GetString("AwsRegion")),
+	)
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/rmrfslashbin/tndx/subcmds/ops/dashboard"
 	"github.com/rmrfslashbin/tndx/subcmds/ops/ddb"
 	"github.com/rmrfslashbin/tndx/subcmds/ops/events"
+	"github.com/rmrfslashbin/tndx/subcmds/ops/local"
+	"github.com/rmrfslashbin/tndx/subcmds/ops/media"
 	"github.com/rmrfslashbin/tndx/subcmds/ops/queue"
 	"github.com/rmrfslashbin/tndx/subcmds/ops/runner"
 	"github.com/rmrfslashbin/tndx/subcmds/ops/tweets"
@@ -28,5 +30,7 @@ func init() {
 		queue.RootCmd,
 		tweets.RootCmd,
 		ddb.RootCmd,
+		local.RootCmd,
+		media.RootCmd,
 	)
 }
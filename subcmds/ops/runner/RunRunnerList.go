@@ -2,6 +2,7 @@ package runner
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/rmrfslashbin/tndx/pkg/database"
 	"github.com/rmrfslashbin/tndx/pkg/service"
@@ -50,7 +51,17 @@ func RunRunnerList() error {
 	}
 
 	for _, user := range res {
-		logrus.WithFields(logrus.Fields{
+		account, err := svc.db.GetAccountStatus(user.UserID)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"action": "RunRunnerList::GetAccountStatus",
+				"error":  err.Error(),
+				"userid": user.UserID,
+			}).Error("error getting account status")
+			return err
+		}
+
+		fields := logrus.Fields{
 			"action":      "RunRunnerList",
 			"userid":      user.UserID,
 			"flags":       user.Flags,
@@ -60,7 +71,17 @@ func RunRunnerList() error {
 			"friends":     database.Has(user.Flags, database.F_friends),
 			"timeline":    database.Has(user.Flags, database.F_timeline),
 			"user":        database.Has(user.Flags, database.F_user),
-		}).Info("flags")
+			"status":      account.Status,
+		}
+		if account.Status == "" || account.Status == database.AccountActive {
+			logrus.WithFields(fields).Info("flags")
+			continue
+		}
+
+		// The processor paused this user's flags; they come back once the account is active.
+		fields["statusSince"] = time.UnixMilli(account.StatusSince).Format(time.RFC3339)
+		fields["pausedFlagsBin"] = fmt.Sprintf("%08b", user.Paused)
+		logrus.WithFields(fields).Warn("flags; account unavailable")
 	}
 	return nil
 }
@@ -4,9 +4,9 @@ import (
 	"os"
 	"path"
 
-	"github.com/rmrfslashbin/ssmparams"
 	"github.com/rmrfslashbin/tndx/pkg/database"
 	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/rmrfslashbin/tndx/subcmds/ops/opsconfig"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -30,8 +30,8 @@ type Flags struct {
 
 // service stores drivers and clients
 type services struct {
-	twitterClient *service.Config
-	db            *database.DDBDriver
+	twitterClient service.Twitter
+	db            database.Database
 }
 
 var (
@@ -207,21 +207,10 @@ func setup() {
 		}).Fatal("failed to read dotenv file")
 	}
 
-	aws_region := viper.GetString("AwsRegion")
-	aws_profile := viper.GetString("AwsProfile")
-	ddb_table_prefix := viper.GetString("DDBTablePrefix")
+	ddb_table_prefix := opsconfig.TablePrefix(log)
 	twitter_api_key := viper.GetString("TwitterApiKey")
 	twitter_api_secret := viper.GetString("TwitterApiSecret")
 
-	if aws_region == "" {
-		log.Fatal("AwsRegion not set in yaml config file")
-	}
-	if aws_profile == "" {
-		log.Fatal("AwsProfile not set in yaml config file")
-	}
-	if ddb_table_prefix == "" {
-		log.Fatal("DDBTablePrefix not set in yaml config file")
-	}
 	if twitter_api_key == "" {
 		log.Fatal("TwitterApiKey not set in yaml config file")
 	}
@@ -229,43 +218,16 @@ func setup() {
 		log.Fatal("TwitterApiSecret not set in yaml config file")
 	}
 
-	// Set up a new ssmparams client
-	params, err := ssmparams.New(
-		ssmparams.SetProfile(aws_profile),
-		ssmparams.SetRegion(aws_region),
-	)
-	if err != nil {
-		log.WithFields(logrus.Fields{
-			"error": err,
-		}).Fatal("error fetching ssm params")
-	}
-
-	outputs, err := params.GetParams([]string{
-		ddb_table_prefix,
-		twitter_api_key,
-		twitter_api_secret,
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if len(outputs.InvalidParameters) > 0 {
-		log.WithFields(logrus.Fields{
-			"InvalidParameters": outputs.InvalidParameters,
-		}).Fatal("invalid parameters")
-	}
+	params := opsconfig.Params(log, ddb_table_prefix, twitter_api_key, twitter_api_secret)
 
 	svc.twitterClient = service.New(
-		service.SetConsumerKey(outputs.Parameters[twitter_api_key].(string)),
-		service.SetConsumerSecret(outputs.Parameters[twitter_api_secret].(string)),
+		service.SetConsumerKey(params[twitter_api_key]),
+		service.SetConsumerSecret(params[twitter_api_secret]),
+		service.SetBaseURL(viper.GetString("TwitterBaseUrl")),
 		service.SetLogger(log),
 	)
 
-	svc.db = database.NewDDB(
-		database.SetDDBLogger(log),
-		database.SetDDBTablePrefix(outputs.Parameters[ddb_table_prefix].(string)),
-		database.SetDDBRegion(aws_region),
-	)
+	svc.db = opsconfig.Database(log, params[ddb_table_prefix])
 
 	if flags.runner == "" {
 		runner := viper.GetString("Runner")
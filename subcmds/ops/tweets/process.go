@@ -5,25 +5,32 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// lookupBatchSize is the most tweet IDs a get_tweet message carries.
+const lookupBatchSize = 100
+
 func RunTweetsProcess() error {
 	bootstrap.Function = "get_tweet"
-	for _, tweetid := range flags.tweetids {
+	for start := 0; start < len(flags.tweetids); start += lookupBatchSize {
+		end := start + lookupBatchSize
+		if end > len(flags.tweetids) {
+			end = len(flags.tweetids)
+		}
 		if err := svc.queue.SendRunnerMessage(&queue.SendMessage{
 			Bootstrap: bootstrap,
 			Message: &queue.ProcessorMessage{
-				TweetID: tweetid,
+				TweetIDs: flags.tweetids[start:end],
 			},
 		}); err != nil {
 			log.WithFields(logrus.Fields{
-				"action":  "RunTweetsProcess::queue::SendRunnerMessage",
-				"error":   err.Error(),
-				"tweetId": tweetid,
+				"action":   "RunTweetsProcess::queue::SendRunnerMessage",
+				"error":    err.Error(),
+				"tweetIds": flags.tweetids[start:end],
 			}).Error("error sending message to queue")
 		} else {
 			log.WithFields(logrus.Fields{
 				"action":   "RunTweetsProcess::queue::SendRunnerMessage",
 				"function": bootstrap.Function,
-				"tweetId":  tweetid,
+				"tweetIds": flags.tweetids[start:end],
 			}).Info("message sent to queue")
 		}
 	}
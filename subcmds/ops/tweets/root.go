@@ -17,7 +17,7 @@ type Flags struct {
 	loglevel   string
 	dotenvPath string
 	runner     string
-	tweetids   []string
+	tweetids   []int64
 }
 
 // service stores drivers and clients
@@ -82,7 +82,7 @@ func init() {
 	RootCmd.PersistentFlags().StringVarP(&flags.dotenvPath, "dotenv", "", "", "dotenv path")
 	RootCmd.PersistentFlags().StringVarP(&flags.runner, "runner", "", "", "runner")
 
-	cmdProcess.Flags().StringSliceVarP(&flags.tweetids, "tweetid", "", []string{}, "tweetid")
+	cmdProcess.Flags().Int64SliceVarP(&flags.tweetids, "tweetid", "", []int64{}, "tweetid")
 	cmdProcess.MarkFlagRequired("tweetid")
 
 	RootCmd.AddCommand(
@@ -1,17 +1,21 @@
 package tweets
 
 import (
+	"errors"
+
+	"github.com/rmrfslashbin/tndx/pkg/service"
 	"github.com/sirupsen/logrus"
 )
 
 func runTweetsPing() error {
 	tweetIDs := DedupInt64Slice(flags.tweetids)
-	tweets, resp, err := svc.twitter.LookupTweets(tweetIDs)
-	if err != nil {
+	tweets, _, err := svc.twitter.LookupTweets(tweetIDs)
+	// statuses/lookup answers not found when none of the tweets exist; report that as zero tweets.
+	var notFound *service.NotFound
+	if err != nil && !errors.As(err, &notFound) {
 		log.WithFields(logrus.Fields{
 			"error":  err,
-			"code":   resp.StatusCode,
-			"status": resp.Status,
+			"kind":   service.Kind(err),
 			"tweets": tweetIDs,
 		}).Error("error looking up tweets")
 		return err
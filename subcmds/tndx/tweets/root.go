@@ -3,7 +3,10 @@ package tweets
 import (
 	"os"
 	"path"
+	"strconv"
 
+	"github.com/rmrfslashbin/tndx/pkg/database"
+	"github.com/rmrfslashbin/tndx/pkg/processor"
 	"github.com/rmrfslashbin/tndx/pkg/service"
 	"github.com/rmrfslashbin/tndx/pkg/ssmparams"
 	"github.com/rmrfslashbin/tndx/subcmds/tndx/tweets/timeline"
@@ -19,10 +22,12 @@ type Flags struct {
 	tweetids   []int64
 	json       bool
 	yaml       bool
+	depth      int
 }
 
 type Services struct {
-	twitter *service.Config
+	twitter service.Twitter
+	db      database.Database
 }
 
 var (
@@ -65,6 +70,24 @@ var (
 		},
 	}
 
+	cmdThread = &cobra.Command{
+		Use:   "thread <tweetid>",
+		Short: "print the reply thread around a tweet",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			tweetID, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				cmd.Usage()
+				log.Fatalf("invalid tweet id %s", args[0])
+			}
+			setup()
+			if err := runTweetsThread(tweetID); err != nil {
+				log.Fatal(err)
+				os.Exit(1)
+			}
+		},
+	}
+
 	cmdGet = &cobra.Command{
 		Use:   "get",
 		Short: "gets one or more live tweet",
@@ -98,9 +121,12 @@ func init() {
 	cmdGet.Flags().BoolVarP(&flags.yaml, "yaml", "y", false, "output in yaml format")
 	cmdGet.MarkFlagRequired("tweetid")
 
+	cmdThread.Flags().IntVarP(&flags.depth, "depth", "d", processor.DefaultMaxThreadDepth, "reply hops to walk up from the tweet")
+
 	RootCmd.AddCommand(
 		cmdPing,
 		cmdGet,
+		cmdThread,
 		timeline.RootCmd,
 	)
 }
@@ -137,6 +163,7 @@ func setup() {
 	}
 
 	aws_region := viper.GetString("AwsRegion")
+	ddb_table_prefix := viper.GetString("DDBTablePrefix")
 	twitter_api_key := viper.GetString("TwitterApiKey")
 	twitter_api_secret := viper.GetString("TwitterApiSecret")
 
@@ -155,10 +182,14 @@ func setup() {
 		ssmparams.SetLogger(log),
 	)
 
-	outputs, err := params.GetParams([]string{
+	names := []string{
 		twitter_api_key,
 		twitter_api_secret,
-	})
+	}
+	if ddb_table_prefix != "" {
+		names = append(names, ddb_table_prefix)
+	}
+	outputs, err := params.GetParams(names)
 
 	if err != nil {
 		log.WithFields(logrus.Fields{
@@ -176,9 +207,24 @@ func setup() {
 	svc.twitter = service.New(
 		service.SetConsumerKey(outputs.Params[twitter_api_key].(string)),
 		service.SetConsumerSecret(outputs.Params[twitter_api_secret].(string)),
+		service.SetBaseURL(viper.GetString("TwitterBaseUrl")),
 		service.SetLogger(log),
 	)
 
+	// Only thread reads the database, so it is optional for the other commands.
+	// BoltPath switches to the embedded local database instead of DynamoDB.
+	if bolt_path := viper.GetString("BoltPath"); bolt_path != "" {
+		svc.db = database.NewBolt(
+			database.SetBoltLogger(log),
+			database.SetBoltPath(bolt_path),
+		)
+	} else if ddb_table_prefix != "" {
+		svc.db = database.NewDDB(
+			database.SetDDBLogger(log),
+			database.SetDDBTablePrefix(outputs.Params[ddb_table_prefix].(string)),
+			database.SetDDBRegion(aws_region),
+		)
+	}
 }
 
 func DedupInt64Slice(intSlice []int64) []int64 {
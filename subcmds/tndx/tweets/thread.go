@@ -0,0 +1,151 @@
+package tweets
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/rmrfslashbin/tndx/pkg/service"
+	"github.com/sirupsen/logrus"
+)
+
+// lookupBatchSize is the most tweet IDs statuses/lookup accepts per call.
+const lookupBatchSize = 100
+
+// runTweetsThread prints the reply tree around tweetID. The ancestry is walked live from
+// Twitter; replies and siblings come from the conversation the processor recorded.
+func runTweetsThread(tweetID int64) error {
+	if svc.db == nil {
+		return errors.New("DDBTablePrefix or BoltPath must be set to read conversations")
+	}
+
+	tweets := map[int64]*twitter.Tweet{}
+	parents := map[int64]int64{}
+
+	// Walk up until the root, the depth limit, or a tweet already in a recorded conversation.
+	var conversationID int64
+	current := tweetID
+	for hops := 0; ; hops++ {
+		known, err := svc.db.GetConversationsByTweetId(current)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"action":  "runTweetsThread::GetConversationsByTweetId",
+				"error":   err,
+				"tweetId": current,
+			}).Error("error getting conversations")
+			return err
+		}
+		if len(known) > 0 {
+			conversationID = known[0].ConversationID
+			break
+		}
+
+		found, err := lookupTweets([]int64{current})
+		if err != nil {
+			return err
+		}
+		tweet, ok := found[current]
+		if !ok {
+			log.WithFields(logrus.Fields{
+				"tweetId": current,
+			}).Warn("tweet not returned; thread ends here")
+			conversationID = current
+			break
+		}
+		tweets[current] = tweet
+		parents[current] = tweet.InReplyToStatusID
+		if tweet.InReplyToStatusID == 0 || hops >= flags.depth {
+			conversationID = current
+			break
+		}
+		current = tweet.InReplyToStatusID
+	}
+
+	items, err := svc.db.GetConversation(conversationID)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"action":         "runTweetsThread::GetConversation",
+			"error":          err,
+			"conversationId": conversationID,
+		}).Error("error getting conversation")
+		return err
+	}
+	var missing []int64
+	for _, item := range items {
+		if _, ok := parents[item.TweetID]; !ok {
+			parents[item.TweetID] = item.ParentID
+		}
+		if _, ok := tweets[item.TweetID]; !ok {
+			missing = append(missing, item.TweetID)
+		}
+	}
+
+	for start := 0; start < len(missing); start += lookupBatchSize {
+		end := start + lookupBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		found, err := lookupTweets(missing[start:end])
+		if err != nil {
+			return err
+		}
+		for id, tweet := range found {
+			tweets[id] = tweet
+		}
+	}
+
+	children := map[int64][]int64{}
+	for id, parent := range parents {
+		if id != conversationID {
+			children[parent] = append(children[parent], id)
+		}
+	}
+	for parent := range children {
+		sort.Slice(children[parent], func(i, j int) bool { return children[parent][i] < children[parent][j] })
+	}
+
+	printThread(conversationID, 0, tweetID, tweets, children)
+	return nil
+}
+
+// lookupTweets fetches up to 100 tweets by ID. Tweets Twitter doesn't return are left out.
+func lookupTweets(tweetIDs []int64) (map[int64]*twitter.Tweet, error) {
+	tweets, _, err := svc.twitter.LookupTweets(tweetIDs)
+	var notFound *service.NotFound
+	if err != nil && !errors.As(err, &notFound) {
+		log.WithFields(logrus.Fields{
+			"error":  err,
+			"kind":   service.Kind(err),
+			"tweets": tweetIDs,
+		}).Error("error looking up tweets")
+		return nil, err
+	}
+	found := make(map[int64]*twitter.Tweet, len(tweets))
+	for t := range tweets {
+		found[tweets[t].ID] = &tweets[t]
+	}
+	return found, nil
+}
+
+// printThread prints id and its replies as an indented tree, marking the requested tweet.
+func printThread(id int64, level int, requested int64, tweets map[int64]*twitter.Tweet, children map[int64][]int64) {
+	marker := " "
+	if id == requested {
+		marker = "*"
+	}
+	line := "[unavailable]"
+	if tweet, ok := tweets[id]; ok {
+		screenName := ""
+		if tweet.User != nil {
+			screenName = tweet.User.ScreenName
+		}
+		line = fmt.Sprintf("@%s: %s", screenName, strings.ReplaceAll(tweet.Text+tweet.FullText, "\n", " "))
+	}
+	fmt.Printf("%s%s %d %s\n", strings.Repeat("  ", level), marker, id, line)
+
+	for _, child := range children[id] {
+		printThread(child, level+1, requested, tweets, children)
+	}
+}
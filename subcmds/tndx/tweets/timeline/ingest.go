@@ -11,13 +11,12 @@ import (
 
 func runTimelineIngest() error {
 	if flags.screenname != "" {
-		if user, resp, err := svc.twitter.GetUser(&service.QueryParams{ScreenName: flags.screenname}); err != nil {
+		if user, _, err := svc.twitter.GetUser(&service.QueryParams{ScreenName: flags.screenname}); err != nil {
 			log.WithFields(logrus.Fields{
-				"action":         "runTimelineIngest::svc.twitter.GetUser",
-				"err":            err,
-				"responseCode":   resp.StatusCode,
-				"responseStatus": resp.Status,
-				"screenname":     flags.screenname,
+				"action":     "runTimelineIngest::svc.twitter.GetUser",
+				"err":        err,
+				"kind":       service.Kind(err),
+				"screenname": flags.screenname,
 			}).Error("error getting user's userid from screenname")
 			return err
 		} else {
@@ -25,7 +24,7 @@ func runTimelineIngest() error {
 		}
 	}
 
-	tweets, resp, err := svc.twitter.GetUserTimeline(
+	tweets, _, err := svc.twitter.GetUserTimeline(
 		&service.QueryParams{
 			UserID:  flags.userid,
 			Count:   flags.count,
@@ -35,10 +34,9 @@ func runTimelineIngest() error {
 	)
 	if err != nil {
 		log.WithFields(logrus.Fields{
-			"action":         "runTimelineIngest::GetUserTimeline",
-			"err":            err,
-			"responseCode":   resp.StatusCode,
-			"responseStatus": resp.Status,
+			"action": "runTimelineIngest::GetUserTimeline",
+			"err":    err,
+			"kind":   service.Kind(err),
 		}).Error("error getting user's timeline")
 		return err
 	}
@@ -83,31 +81,25 @@ func runTimelineIngest() error {
 		}
 
 		// Loop through all the media entities
-		for m := range tweets[t].Entities.Media {
-			var url string
-			if tweets[t].Entities.Media[m].MediaURLHttps != "" {
-				url = tweets[t].Entities.Media[m].MediaURLHttps
-			} else if tweets[t].Entities.Media[m].MediaURL != "" {
-				url = tweets[t].Entities.Media[m].MediaURL
-			}
-			if url != "" {
-				bootstrap.Function = "entities"
-				if err := svc.queue.SendRunnerMessage(&queue.SendMessage{
-					Bootstrap: bootstrap,
-					Message: &queue.ProcessorMessage{
-						TweetID:   tweets[t].IDStr,
-						EntityURL: url,
-						UserID:    flags.userid,
-					},
-				}); err != nil {
-					logrus.WithFields(logrus.Fields{
-						"action":  "runTimelineIngest::svc.queue.SendRunnerMessage",
-						"error":   err.Error(),
-						"userid":  flags.userid,
-						"tweetId": tweets[t].ID,
-					}).Error("error sending message to queue")
-					fmt.Printf("Queued: %s\n", url)
-				}
+		for _, media := range service.TweetMedia(&tweets[t]) {
+			bootstrap.Function = "entities"
+			if err := svc.queue.SendRunnerMessage(&queue.SendMessage{
+				Bootstrap: bootstrap,
+				Message: &queue.ProcessorMessage{
+					TweetID:   tweets[t].IDStr,
+					EntityURL: media.URL,
+					MediaType: media.Type,
+					UserID:    flags.userid,
+				},
+			}); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"action":  "runTimelineIngest::svc.queue.SendRunnerMessage",
+					"error":   err.Error(),
+					"userid":  flags.userid,
+					"tweetId": tweets[t].ID,
+				}).Error("error sending message to queue")
+			} else {
+				fmt.Printf("Queued %s: %s\n", media.Type, media.URL)
 			}
 		}
 
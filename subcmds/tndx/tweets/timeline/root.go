@@ -25,7 +25,7 @@ type Flags struct {
 }
 
 type Services struct {
-	twitter *service.Config
+	twitter service.Twitter
 	kinesis *kinesis.Config
 	queue   *queue.Config
 }
@@ -206,6 +206,7 @@ func setup() {
 	svc.twitter = service.New(
 		service.SetConsumerKey(outputs.Params[twitter_api_key].(string)),
 		service.SetConsumerSecret(outputs.Params[twitter_api_secret].(string)),
+		service.SetBaseURL(viper.GetString("TwitterBaseUrl")),
 		service.SetLogger(log),
 	)
 
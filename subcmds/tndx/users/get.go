@@ -2,6 +2,7 @@ package users
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 
 	"github.com/davecgh/go-spew/spew"
@@ -30,15 +31,16 @@ type BasicData struct {
 func runUsersGet() error {
 	userIDs := DedupInt64Slice(flags.userids)
 	screenNames := DedupStringSlice(flags.screenname)
-	users, resp, err := svc.twitter.LookupUsers(&twitter.UserLookupParams{
+	users, _, err := svc.twitter.LookupUsers(&twitter.UserLookupParams{
 		UserID:     userIDs,
 		ScreenName: screenNames,
 	})
-	if err != nil {
+	// users/lookup answers not found when none of the users exist; report that as zero users.
+	var notFound *service.NotFound
+	if err != nil && !errors.As(err, &notFound) {
 		log.WithFields(logrus.Fields{
 			"error":       err,
-			"code":        resp.StatusCode,
-			"status":      resp.Status,
+			"kind":        service.Kind(err),
 			"userIDs":     userIDs,
 			"screenNames": screenNames,
 		}).Error("error looking up users")